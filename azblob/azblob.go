@@ -0,0 +1,355 @@
+package azblob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	azure "github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+
+	"github.com/sputnik-systems/backups-storage"
+)
+
+type AzBlob struct {
+	c                 *azure.Client
+	container, prefix string
+	partSize          int64
+}
+
+type FileInfo struct {
+	name  string
+	size  int64
+	mtime time.Time
+	isdir bool
+}
+
+func NewStorage(client *azure.Client, container, prefix string) storage.Storage {
+	partSize := int64(100 * 1024 * 1024)
+
+	return &AzBlob{
+		c:         client,
+		container: container,
+		prefix:    prefix,
+		partSize:  partSize,
+	}
+}
+
+func (s *AzBlob) List() ([]storage.FileInfo, error) {
+	return s.ListContext(context.Background())
+}
+
+func (s *AzBlob) ListContext(ctx context.Context) ([]storage.FileInfo, error) {
+	return s.list(ctx, s.key(""))
+}
+
+func (s *AzBlob) ListFunc(prefix string, fn func(storage.FileInfo) error) error {
+	return s.listFunc(context.Background(), s.key(prefix), fn)
+}
+
+func (s *AzBlob) listFunc(ctx context.Context, prefix string, fn func(storage.FileInfo) error) error {
+	pager := s.c.NewListBlobsFlatPager(s.container, &azure.ListBlobsFlatOptions{
+		Prefix: &prefix,
+	})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, o := range page.Segment.BlobItems {
+			if err := fn(fileInfo(s.relName(*o.Name), o.Properties)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *AzBlob) list(ctx context.Context, prefix string) ([]storage.FileInfo, error) {
+	fi := make([]storage.FileInfo, 0)
+	di := make(map[string]*FileInfo)
+	err := s.listFunc(ctx, prefix, func(f storage.FileInfo) error {
+		fi = append(fi, f)
+
+		// calc directories
+		dir := path.Dir(f.Name()) + "/"
+		if dir == "./" {
+			return nil
+		}
+
+		if d, ok := di[dir]; !ok || d.mtime.Before(f.ModTime()) {
+			di[dir] = &FileInfo{dir, int64(0), f.ModTime(), true}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fi, err
+	}
+
+	for _, d := range di {
+		fi = append(fi, d)
+	}
+
+	sort.Slice(fi, func(i, j int) bool {
+		return fi[i].Name() > fi[j].Name()
+	})
+
+	return fi, nil
+}
+
+func (s *AzBlob) Delete(name string) error {
+	return s.DeleteContext(context.Background(), name)
+}
+
+func (s *AzBlob) DeleteContext(ctx context.Context, name string) error {
+	if err := storage.ValidateName(name); err != nil {
+		return err
+	}
+
+	prefix := s.key(strings.TrimSuffix(name, "/"))
+	fi, err := s.list(ctx, prefix)
+	if err != nil {
+		return err
+	}
+
+	for _, o := range fi {
+		if o.IsDir() {
+			continue
+		}
+
+		_, err := s.c.DeleteBlob(ctx, s.container, s.key(o.Name()), nil)
+		if err != nil && !bloberror.HasCode(err, bloberror.BlobNotFound) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *AzBlob) DeleteBatch(names []string) error {
+	for _, name := range names {
+		key, err := storage.ObjectKey(s.prefix, name)
+		if err != nil {
+			return err
+		}
+
+		_, err = s.c.DeleteBlob(context.Background(), s.container, key, nil)
+		if err != nil && !bloberror.HasCode(err, bloberror.BlobNotFound) {
+			return fmt.Errorf("delete %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *AzBlob) Upload(name string, buf io.Reader) error {
+	return s.UploadContext(context.Background(), name, buf)
+}
+
+func (s *AzBlob) UploadContext(ctx context.Context, name string, buf io.Reader) error {
+	key, err := storage.ObjectKey(s.prefix, name)
+	if err != nil {
+		return err
+	}
+
+	// stream is staged as blocks of part size and committed at the end,
+	// uncommitted blocks of failed upload are garbage collected by azure
+	_, err = s.c.UploadStream(ctx, s.container, key, buf, &azure.UploadStreamOptions{
+		BlockSize: s.partSize,
+	})
+
+	return err
+}
+
+func (s *AzBlob) Download(name string, buf io.Writer) error {
+	return s.DownloadContext(context.Background(), name, buf)
+}
+
+func (s *AzBlob) DownloadContext(ctx context.Context, name string, buf io.Writer) error {
+	key, err := storage.ObjectKey(s.prefix, name)
+	if err != nil {
+		return err
+	}
+
+	o, err := s.c.DownloadStream(ctx, s.container, key, nil)
+	if err != nil {
+		if bloberror.HasCode(err, bloberror.BlobNotFound) {
+			return fmt.Errorf("%s: %w", key, storage.ErrNotFound)
+		}
+
+		return err
+	}
+	defer o.Body.Close()
+
+	_, err = io.Copy(buf, o.Body)
+
+	return err
+}
+
+func (s *AzBlob) Exists(name string) (bool, error) {
+	if _, err := s.Stat(name); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *AzBlob) Stat(name string) (storage.FileInfo, error) {
+	key, err := storage.ObjectKey(s.prefix, name)
+	if err != nil {
+		return nil, err
+	}
+
+	b := s.c.ServiceClient().NewContainerClient(s.container).NewBlobClient(key)
+	o, err := b.GetProperties(context.Background(), nil)
+	if err != nil {
+		if bloberror.HasCode(err, bloberror.BlobNotFound) {
+			return nil, fmt.Errorf("%s: %w", key, storage.ErrNotFound)
+		}
+
+		return nil, err
+	}
+
+	var size int64
+	if o.ContentLength != nil {
+		size = *o.ContentLength
+	}
+
+	var mtime time.Time
+	if o.LastModified != nil {
+		mtime = *o.LastModified
+	}
+
+	return &FileInfo{s.relName(key), size, mtime, false}, nil
+}
+
+func (s *AzBlob) Copy(src, dst string) error {
+	return s.CopyContext(context.Background(), src, dst)
+}
+
+// CopyContext copies like Copy, pending copy is aborted once ctx is done.
+func (s *AzBlob) CopyContext(ctx context.Context, src, dst string) error {
+	srcKey, err := storage.ObjectKey(s.prefix, src)
+	if err != nil {
+		return err
+	}
+
+	dstKey, err := storage.ObjectKey(s.prefix, dst)
+	if err != nil {
+		return err
+	}
+
+	cc := s.c.ServiceClient().NewContainerClient(s.container)
+	b := cc.NewBlobClient(dstKey)
+	o, err := b.StartCopyFromURL(ctx, cc.NewBlobClient(srcKey).URL(), nil)
+	if err != nil {
+		if bloberror.HasCode(err, bloberror.CannotVerifyCopySource, bloberror.BlobNotFound) {
+			return fmt.Errorf("%s: %w", srcKey, storage.ErrNotFound)
+		}
+
+		return err
+	}
+
+	// copy is asynchronous even inside single account
+	status := o.CopyStatus
+	for status != nil && *status == blob.CopyStatusTypePending {
+		t := time.NewTimer(time.Second)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+
+			// context is done, so abort needs own one
+			if o.CopyID != nil {
+				if _, aerr := b.AbortCopyFromURL(context.Background(), *o.CopyID, nil); aerr != nil {
+					return fmt.Errorf("%w (abort copy: %v)", ctx.Err(), aerr)
+				}
+			}
+
+			return ctx.Err()
+		case <-t.C:
+		}
+
+		p, err := b.GetProperties(ctx, nil)
+		if err != nil {
+			return err
+		}
+		status = p.CopyStatus
+	}
+
+	if status != nil && *status != blob.CopyStatusTypeSuccess {
+		return fmt.Errorf("copy %s to %s: %s", srcKey, dstKey, *status)
+	}
+
+	return nil
+}
+
+func (s *AzBlob) Move(src, dst string) error {
+	if err := s.Copy(src, dst); err != nil {
+		return fmt.Errorf("move %s: copy: %w", src, err)
+	}
+
+	key, err := storage.ObjectKey(s.prefix, src)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.c.DeleteBlob(context.Background(), s.container, key, nil); err != nil {
+		return fmt.Errorf("move %s: delete source: %w", src, err)
+	}
+
+	return nil
+}
+
+func fileInfo(name string, p *container.BlobProperties) *FileInfo {
+	f := &FileInfo{name: name}
+	if p == nil {
+		return f
+	}
+
+	if p.ContentLength != nil {
+		f.size = *p.ContentLength
+	}
+
+	if p.LastModified != nil {
+		f.mtime = *p.LastModified
+	}
+
+	return f
+}
+
+// key joins name with the prefix keeping trailing slash, so it can be used
+// as list prefix.
+func (s *AzBlob) key(name string) string {
+	if s.prefix == "" {
+		return name
+	}
+
+	return strings.TrimSuffix(s.prefix, "/") + "/" + name
+}
+
+// relName strips the prefix from object key.
+func (s *AzBlob) relName(key string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(key, strings.TrimSuffix(s.prefix, "/")), "/")
+}
+
+func (f *FileInfo) Name() string { return f.name }
+
+func (f *FileInfo) Size() int64 { return f.size }
+
+func (f *FileInfo) ModTime() time.Time { return f.mtime }
+
+func (f *FileInfo) IsDir() bool { return f.isdir }
@@ -0,0 +1,144 @@
+//go:build integration
+
+package azblob
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	azure "github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+
+	"github.com/sputnik-systems/backups-storage"
+)
+
+// Run with Azurite, e.g. docker run -p 10000:10000
+// mcr.microsoft.com/azure-storage/azurite azurite-blob --blobHost 0.0.0.0,
+// and go test -tags integration ./azblob.
+
+// azuriteConnectionString is the well known account of the emulator.
+const azuriteConnectionString = "DefaultEndpointsProtocol=http;AccountName=devstoreaccount1;" +
+	"AccountKey=Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw==;" +
+	"BlobEndpoint=http://127.0.0.1:10000/devstoreaccount1;"
+
+func newAzuriteStorage(t *testing.T) *AzBlob {
+	cs := os.Getenv("AZURITE_CONNECTION_STRING")
+	if cs == "" {
+		cs = azuriteConnectionString
+	}
+
+	c, err := azure.NewClientFromConnectionString(cs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	container := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	if _, err := c.CreateContainer(context.Background(), container, nil); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		c.DeleteContainer(context.Background(), container, nil)
+	})
+
+	return NewStorage(c, container, "backups").(*AzBlob)
+}
+
+func TestAzuriteRoundTrip(t *testing.T) {
+	s := newAzuriteStorage(t)
+	// small blocks make the stream staged in several of them
+	s.partSize = 1 << 20
+
+	data := bytes.Repeat([]byte("0123456789"), 300000)
+	if err := s.Upload("db/dump.sql", bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := s.Download("db/dump.sql", &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Fatalf("downloaded %d bytes, want %d", buf.Len(), len(data))
+	}
+
+	fi, err := s.Stat("db/dump.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fi.Name() != "db/dump.sql" || fi.Size() != int64(len(data)) {
+		t.Errorf("stat %s of %d bytes", fi.Name(), fi.Size())
+	}
+
+	list, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, f := range list {
+		names = append(names, f.Name())
+	}
+
+	if got := strings.Join(names, " "); got != "db/dump.sql db/" {
+		t.Errorf("listed %s, want db/dump.sql db/", got)
+	}
+}
+
+func TestAzuriteCopyAndDelete(t *testing.T) {
+	s := newAzuriteStorage(t)
+
+	if err := s.Upload("a", strings.NewReader("data")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.CopyContext(context.Background(), "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Move("b", "c"); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := s.Exists("b"); err != nil || ok {
+		t.Errorf("moved source exists %v, %v", ok, err)
+	}
+
+	var buf bytes.Buffer
+	if err := s.Download("c", &buf); err != nil || buf.String() != "data" {
+		t.Errorf("downloaded copy %q, %v", buf.String(), err)
+	}
+
+	if err := s.Copy("missing", "d"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("copy of missing object: %v", err)
+	}
+
+	if err := s.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Download("a", &buf); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("download of deleted object: %v", err)
+	}
+}
+
+func TestAzuriteCopyCanceled(t *testing.T) {
+	s := newAzuriteStorage(t)
+
+	if err := s.Upload("a", strings.NewReader("data")); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := s.CopyContext(ctx, "a", "b"); !errors.Is(err, context.Canceled) {
+		t.Errorf("copy with canceled context: %v", err)
+	}
+}
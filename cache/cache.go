@@ -0,0 +1,186 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sputnik-systems/backups-storage"
+)
+
+// Results of Stat, Exists and List are kept for ttl, writes through the
+// cache invalidate entries they affect, writes by other clients are seen
+// once entries expire. ListFunc is not cached.
+
+type Cache struct {
+	storage.Storage
+	ttl time.Duration
+
+	mu    sync.Mutex
+	stats map[string]statEntry
+	list  *listEntry
+	// gen is incremented by invalidations, results fetched across one
+	// may be stale and are not stored
+	gen uint64
+}
+
+type statEntry struct {
+	fi      storage.FileInfo
+	err     error
+	expires time.Time
+}
+
+type listEntry struct {
+	fi      []storage.FileInfo
+	expires time.Time
+}
+
+func NewStorage(s storage.Storage, ttl time.Duration) storage.Storage {
+	return &Cache{
+		Storage: s,
+		ttl:     ttl,
+		stats:   make(map[string]statEntry),
+	}
+}
+
+func (s *Cache) List() ([]storage.FileInfo, error) {
+	return s.ListContext(context.Background())
+}
+
+func (s *Cache) ListContext(ctx context.Context) ([]storage.FileInfo, error) {
+	s.mu.Lock()
+	l, gen := s.list, s.gen
+	s.mu.Unlock()
+
+	if l != nil && time.Now().Before(l.expires) {
+		return append([]storage.FileInfo(nil), l.fi...), nil
+	}
+
+	fi, err := s.Storage.ListContext(ctx)
+	if err != nil {
+		return fi, err
+	}
+
+	s.mu.Lock()
+	if s.gen == gen {
+		s.list = &listEntry{fi, time.Now().Add(s.ttl)}
+	}
+	s.mu.Unlock()
+
+	return append([]storage.FileInfo(nil), fi...), nil
+}
+
+func (s *Cache) Stat(name string) (storage.FileInfo, error) {
+	s.mu.Lock()
+	e, ok := s.stats[name]
+	gen := s.gen
+	s.mu.Unlock()
+
+	if ok && time.Now().Before(e.expires) {
+		return e.fi, e.err
+	}
+
+	fi, err := s.Storage.Stat(name)
+	// missing objects are cached too, other errors are not
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return fi, err
+	}
+
+	s.mu.Lock()
+	if s.gen == gen {
+		s.stats[name] = statEntry{fi, err, time.Now().Add(s.ttl)}
+	}
+	s.mu.Unlock()
+
+	return fi, err
+}
+
+func (s *Cache) Exists(name string) (bool, error) {
+	if _, err := s.Stat(name); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *Cache) Delete(name string) error {
+	return s.DeleteContext(context.Background(), name)
+}
+
+func (s *Cache) DeleteContext(ctx context.Context, name string) error {
+	// delete removes objects by prefix, so may affect any of names under it
+	defer s.invalidatePrefix(strings.TrimSuffix(name, "/"))
+
+	return s.Storage.DeleteContext(ctx, name)
+}
+
+func (s *Cache) DeleteBatch(names []string) error {
+	defer s.invalidate(names...)
+
+	return s.Storage.DeleteBatch(names)
+}
+
+func (s *Cache) Upload(name string, buf io.Reader) error {
+	return s.UploadContext(context.Background(), name, buf)
+}
+
+func (s *Cache) UploadContext(ctx context.Context, name string, buf io.Reader) error {
+	defer s.invalidate(name)
+
+	return s.Storage.UploadContext(ctx, name, buf)
+}
+
+func (s *Cache) Copy(src, dst string) error {
+	defer s.invalidate(dst)
+
+	return s.Storage.Copy(src, dst)
+}
+
+func (s *Cache) Move(src, dst string) error {
+	defer s.invalidate(src, dst)
+
+	return s.Storage.Move(src, dst)
+}
+
+// Invalidate drops all cached results.
+func (s *Cache) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats = make(map[string]statEntry)
+	s.list = nil
+	s.gen++
+}
+
+// invalidate drops results of names and the list, failed writes invalidate
+// too as they may leave storage changed.
+func (s *Cache) invalidate(names ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, name := range names {
+		delete(s.stats, name)
+	}
+	s.list = nil
+	s.gen++
+}
+
+func (s *Cache) invalidatePrefix(prefix string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name := range s.stats {
+		if strings.HasPrefix(name, prefix) {
+			delete(s.stats, name)
+		}
+	}
+	s.list = nil
+	s.gen++
+}
@@ -0,0 +1,201 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sputnik-systems/backups-storage"
+	"github.com/sputnik-systems/backups-storage/memory"
+)
+
+// counting counts Stat and List requests reaching the backend, Stat is
+// held by hold until it is closed when set.
+type counting struct {
+	*memory.Memory
+	stats, lists atomic.Int64
+	hold         chan struct{}
+}
+
+func (s *counting) Stat(name string) (storage.FileInfo, error) {
+	s.stats.Add(1)
+	if s.hold != nil {
+		<-s.hold
+	}
+
+	return s.Memory.Stat(name)
+}
+
+func (s *counting) ListContext(ctx context.Context) ([]storage.FileInfo, error) {
+	s.lists.Add(1)
+
+	return s.Memory.ListContext(ctx)
+}
+
+func newCounting() *counting {
+	m := memory.NewStorage()
+	m.Seed("db/a.sql", []byte("a"), time.Now())
+	m.Seed("db/b.sql", []byte("b"), time.Now())
+
+	return &counting{Memory: m}
+}
+
+func TestCacheHits(t *testing.T) {
+	b := newCounting()
+	s := NewStorage(b, time.Hour)
+
+	for i := 0; i < 3; i++ {
+		if fi, err := s.Stat("db/a.sql"); err != nil || fi.Size() != 1 {
+			t.Fatalf("stat %v, %v", fi, err)
+		}
+
+		if ok, err := s.Exists("db/a.sql"); err != nil || !ok {
+			t.Fatalf("exists %v, %v", ok, err)
+		}
+
+		// missing objects are cached too
+		if ok, err := s.Exists("missing"); err != nil || ok {
+			t.Fatalf("missing exists %v, %v", ok, err)
+		}
+
+		if fi, err := s.List(); err != nil || len(fi) != 3 {
+			t.Fatalf("listed %d entries, %v", len(fi), err)
+		}
+	}
+
+	if n := b.stats.Load(); n != 2 {
+		t.Errorf("%d stat requests, want 2", n)
+	}
+
+	if n := b.lists.Load(); n != 1 {
+		t.Errorf("%d list requests, want 1", n)
+	}
+
+	// changing returned list does not change the cached one
+	fi, _ := s.List()
+	fi[0] = nil
+	if fi, _ := s.List(); fi[0] == nil {
+		t.Error("cached list is shared with callers")
+	}
+}
+
+func TestCacheExpiry(t *testing.T) {
+	b := newCounting()
+	s := NewStorage(b, 50*time.Millisecond)
+
+	s.Stat("db/a.sql")
+	s.List()
+	time.Sleep(100 * time.Millisecond)
+	s.Stat("db/a.sql")
+	s.List()
+
+	if n, m := b.stats.Load(), b.lists.Load(); n != 2 || m != 2 {
+		t.Errorf("%d stat and %d list requests after expiry, want 2 and 2", n, m)
+	}
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	b := newCounting()
+	s := NewStorage(b, time.Hour)
+
+	s.Stat("db/a.sql")
+	s.Stat("db/b.sql")
+	s.List()
+
+	if err := s.Delete("db/"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"db/a.sql", "db/b.sql"} {
+		if ok, err := s.Exists(name); err != nil || ok {
+			t.Errorf("%s exists after delete %v, %v", name, ok, err)
+		}
+	}
+
+	if fi, err := s.List(); err != nil || len(fi) != 0 {
+		t.Errorf("listed %d entries after delete, %v", len(fi), err)
+	}
+
+	if err := s.Upload("db/a.sql", strings.NewReader("new")); err != nil {
+		t.Fatal(err)
+	}
+
+	if fi, err := s.Stat("db/a.sql"); err != nil || fi.Size() != 3 {
+		t.Errorf("stat after upload %v, %v", fi, err)
+	}
+
+	if err := s.Move("db/a.sql", "db/c.sql"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Stat("db/a.sql"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("stat of moved source: %v", err)
+	}
+
+	if ok, _ := s.Exists("db/c.sql"); !ok {
+		t.Error("moved object is missing")
+	}
+
+	s.(*Cache).Invalidate()
+	before := b.stats.Load()
+	s.Stat("db/c.sql")
+	if b.stats.Load() != before+1 {
+		t.Error("stat is cached after invalidate")
+	}
+}
+
+func TestCacheStaleResult(t *testing.T) {
+	b := newCounting()
+	b.hold = make(chan struct{})
+	s := NewStorage(b, time.Hour)
+
+	// stat started before upload returns the old object, which is not
+	// cached
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.Stat("db/a.sql")
+	}()
+
+	for b.stats.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := s.Upload("db/other.sql", strings.NewReader("x")); err != nil {
+		t.Fatal(err)
+	}
+	close(b.hold)
+	wg.Wait()
+
+	before := b.stats.Load()
+	s.Stat("db/a.sql")
+	if b.stats.Load() != before+1 {
+		t.Error("result fetched across invalidation is cached")
+	}
+}
+
+func TestCacheConcurrent(t *testing.T) {
+	s := NewStorage(newCounting(), time.Millisecond)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				s.Stat("db/a.sql")
+				s.List()
+				if i%2 == 0 {
+					s.Upload("db/a.sql", strings.NewReader("a"))
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
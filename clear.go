@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// names deleted by a single DeleteBatch call of Clear
+const clearBatchSize = 1000
+
+// Clear deletes every object with the name prefix. Empty prefix clears the
+// whole storage, so it is refused unless all is set. Failed batches do not
+// stop the rest, the returned error joins their errors.
+func Clear(ctx context.Context, s Storage, prefix string, all bool) error {
+	if prefix == "" && !all {
+		return fmt.Errorf("refusing to clear the whole storage without explicit confirmation")
+	}
+
+	fi, err := listPrefix(ctx, s, prefix)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for len(fi) > 0 {
+		if err := ctx.Err(); err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+
+		n := min(len(fi), clearBatchSize)
+		names := make([]string, 0, n)
+		for _, f := range fi[:n] {
+			names = append(names, f.Name())
+		}
+
+		if err := s.DeleteBatch(names); err != nil {
+			errs = append(errs, fmt.Errorf("delete %s..%s: %w", names[0], names[n-1], err))
+		}
+
+		fi = fi[n:]
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,101 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sputnik-systems/backups-storage"
+	"github.com/sputnik-systems/backups-storage/memory"
+)
+
+// batches records sizes of DeleteBatch calls and fails ones with name.
+type batches struct {
+	*memory.Memory
+	sizes []int
+	fail  string
+}
+
+var errDenied = errors.New("access denied")
+
+func (s *batches) DeleteBatch(names []string) error {
+	s.sizes = append(s.sizes, len(names))
+	if slices.Contains(names, s.fail) {
+		return errDenied
+	}
+
+	return s.Memory.DeleteBatch(names)
+}
+
+func seedMany(s *memory.Memory, prefix string, n int) {
+	for i := 0; i < n; i++ {
+		s.Seed(fmt.Sprintf("%s%04d.sql", prefix, i), nil, day)
+	}
+}
+
+func TestClear(t *testing.T) {
+	s := &batches{Memory: memory.NewStorage()}
+	seedMany(s.Memory, "db/", 2500)
+	seedMany(s.Memory, "dbx/", 10)
+	s.Seed("other.sql", nil, day)
+
+	if err := storage.Clear(context.Background(), s, "db/", false); err != nil {
+		t.Fatal(err)
+	}
+
+	if fmt.Sprint(s.sizes) != "[1000 1000 500]" {
+		t.Errorf("deleted by batches of %v", s.sizes)
+	}
+
+	if got := contents(t, s.Memory); len(got) != 11 {
+		t.Errorf("left %d objects, want 11", len(got))
+	}
+}
+
+func TestClearFailedBatch(t *testing.T) {
+	s := &batches{Memory: memory.NewStorage(), fail: "db/1500.sql"}
+	seedMany(s.Memory, "db/", 2500)
+
+	// failed batch does not stop the rest
+	err := storage.Clear(context.Background(), s, "db/", false)
+	if !errors.Is(err, errDenied) {
+		t.Fatalf("got %v, want %v", err, errDenied)
+	}
+
+	if len(s.sizes) != 3 {
+		t.Errorf("deleted by batches of %v", s.sizes)
+	}
+
+	left := contents(t, s.Memory)
+	if len(left) != 1000 {
+		t.Errorf("left %d objects, want 1000", len(left))
+	}
+
+	if _, ok := left["db/1500.sql"]; !ok || !strings.Contains(err.Error(), "db/1000.sql..db/1999.sql") {
+		t.Errorf("error %q does not tell failed batch", err)
+	}
+}
+
+func TestClearEmptyPrefix(t *testing.T) {
+	s := &batches{Memory: memory.NewStorage()}
+	seedMany(s.Memory, "db/", 10)
+
+	if err := storage.Clear(context.Background(), s, "", false); err == nil {
+		t.Fatal("whole storage cleared without confirmation")
+	}
+
+	if len(s.sizes) != 0 || len(contents(t, s.Memory)) != 10 {
+		t.Errorf("deleted by batches of %v", s.sizes)
+	}
+
+	if err := storage.Clear(context.Background(), s, "", true); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := contents(t, s.Memory); len(got) != 0 {
+		t.Errorf("left %d objects", len(got))
+	}
+}
@@ -0,0 +1,157 @@
+package compress
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/sputnik-systems/backups-storage"
+)
+
+// Objects keep their logical names and start with header of the codec,
+// Download decodes only objects with the header, so gzip and zstd storages
+// read objects of each other and objects uploaded otherwise are passed as
+// is, whatever their content.
+
+// header is magic followed by the codec byte.
+var magic = []byte("\x00bsc")
+
+const (
+	codecGzip byte = 1
+	codecZstd byte = 2
+)
+
+type Compress struct {
+	storage.Storage
+	codec      byte
+	compressor func(io.Writer) (io.WriteCloser, error)
+}
+
+func NewStorage(s storage.Storage, level int) (storage.Storage, error) {
+	// validate level before the first upload
+	if _, err := gzip.NewWriterLevel(io.Discard, level); err != nil {
+		return nil, err
+	}
+
+	return &Compress{
+		Storage: s,
+		codec:   codecGzip,
+		compressor: func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriterLevel(w, level)
+		},
+	}, nil
+}
+
+func NewZstdStorage(s storage.Storage, level zstd.EncoderLevel) (storage.Storage, error) {
+	if _, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(level)); err != nil {
+		return nil, err
+	}
+
+	return &Compress{
+		Storage: s,
+		codec:   codecZstd,
+		compressor: func(w io.Writer) (io.WriteCloser, error) {
+			return zstd.NewWriter(w, zstd.WithEncoderLevel(level))
+		},
+	}, nil
+}
+
+func (s *Compress) Upload(name string, buf io.Reader) error {
+	return s.UploadContext(context.Background(), name, buf)
+}
+
+func (s *Compress) UploadContext(ctx context.Context, name string, buf io.Reader) error {
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := pw.Write(append(bytes.Clone(magic), s.codec))
+		var w io.WriteCloser
+		if err == nil {
+			w, err = s.compressor(pw)
+		}
+		if err == nil {
+			_, err = io.Copy(w, buf)
+		}
+		if err == nil {
+			err = w.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	err := s.Storage.UploadContext(ctx, name, pr)
+	// unblock compressing goroutine if upload stopped early
+	pr.CloseWithError(err)
+
+	return err
+}
+
+func (s *Compress) Download(name string, buf io.Writer) error {
+	return s.DownloadContext(context.Background(), name, buf)
+}
+
+func (s *Compress) DownloadContext(ctx context.Context, name string, buf io.Writer) error {
+	pr, pw := io.Pipe()
+	errc := make(chan error, 1)
+	go func() {
+		err := s.Storage.DownloadContext(ctx, name, pw)
+		pw.CloseWithError(err)
+		errc <- err
+	}()
+
+	err := decompress(pr, buf)
+	// unblock download if decompression stopped early
+	pr.CloseWithError(err)
+
+	if derr := <-errc; derr != nil {
+		return derr
+	}
+
+	return err
+}
+
+func decompress(r io.Reader, w io.Writer) error {
+	br := bufio.NewReader(r)
+	h, err := br.Peek(len(magic) + 1)
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	if len(h) < len(magic)+1 || !bytes.Equal(h[:len(magic)], magic) {
+		_, err = io.Copy(w, br)
+
+		return err
+	}
+
+	if _, err := br.Discard(len(h)); err != nil {
+		return err
+	}
+
+	var zr io.ReadCloser
+	switch h[len(magic)] {
+	case codecZstd:
+		d, err := zstd.NewReader(br)
+		if err != nil {
+			return err
+		}
+		zr = d.IOReadCloser()
+	case codecGzip:
+		zr, err = gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown codec %d", h[len(magic)])
+	}
+
+	if _, err = io.Copy(w, zr); err != nil {
+		zr.Close()
+
+		return err
+	}
+
+	return zr.Close()
+}
@@ -0,0 +1,177 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/sputnik-systems/backups-storage"
+	"github.com/sputnik-systems/backups-storage/memory"
+)
+
+// dump returns text resembling sql dump of n rows.
+func dump(n int) []byte {
+	var b bytes.Buffer
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "INSERT INTO users (id, name, created) VALUES (%d, 'user%d', '2021-10-%02d');\n", i, i%97, i%28+1)
+	}
+
+	return b.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	random := make([]byte, 1<<20)
+	rand.Read(random)
+
+	for name, data := range map[string][]byte{
+		"compressible":   dump(20000),
+		"incompressible": random,
+		"empty":          {},
+	} {
+		m := memory.NewStorage()
+		s, err := NewStorage(m, gzip.BestSpeed)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.Upload("db.sql", bytes.NewReader(data)); err != nil {
+			t.Fatal(err)
+		}
+
+		stored, _ := m.Bytes("db.sql")
+		if name == "compressible" && len(stored) >= len(data)/2 {
+			t.Errorf("%s: stored %d bytes of %d", name, len(stored), len(data))
+		}
+
+		var buf bytes.Buffer
+		if err := s.Download("db.sql", &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(buf.Bytes(), data) {
+			t.Errorf("%s: downloaded %d bytes, want %d", name, buf.Len(), len(data))
+		}
+
+		fi, err := s.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(fi) != 1 || fi[0].Name() != "db.sql" {
+			t.Errorf("%s: listed %v", name, fi)
+		}
+	}
+}
+
+func TestDownloadUncompressed(t *testing.T) {
+	m := memory.NewStorage()
+	s, err := NewStorage(m, gzip.DefaultCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// objects uploaded without the wrapper, even gzipped ones, are as is
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte("data"))
+	w.Close()
+
+	for name, data := range map[string][]byte{"plain": []byte("data"), "gzip": gz.Bytes(), "short": {0}} {
+		if err := m.Upload(name, bytes.NewReader(data)); err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		if err := s.Download(name, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(buf.Bytes(), data) {
+			t.Errorf("%s: downloaded %q, want %q", name, buf.Bytes(), data)
+		}
+	}
+}
+
+func TestInvalidLevel(t *testing.T) {
+	if _, err := NewStorage(memory.NewStorage(), 10); err == nil {
+		t.Error("level 10 accepted")
+	}
+}
+
+func TestCodecsInterchangeable(t *testing.T) {
+	m := memory.NewStorage()
+	gz, err := NewStorage(m, gzip.BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zs, err := NewZstdStorage(m, zstd.SpeedFastest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := dump(1000)
+	for _, tc := range []struct {
+		name     string
+		up, down storage.Storage
+	}{
+		{"gzip by zstd", gz, zs},
+		{"zstd by gzip", zs, gz},
+	} {
+		if err := tc.up.Upload("db.sql", bytes.NewReader(data)); err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		if err := tc.down.Download("db.sql", &buf); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		if !bytes.Equal(buf.Bytes(), data) {
+			t.Errorf("%s: downloaded %d bytes, want %d", tc.name, buf.Len(), len(data))
+		}
+	}
+
+	// unknown codec of newer version is not passed as is
+	m.Seed("future", append(bytes.Clone(magic), 9, 'x'), time.Time{})
+	if err := gz.Download("future", &bytes.Buffer{}); err == nil {
+		t.Error("object of unknown codec downloaded")
+	}
+}
+
+func BenchmarkUpload(b *testing.B) {
+	data := dump(100000)
+
+	for _, bc := range []struct {
+		name string
+		new  func(storage.Storage) (storage.Storage, error)
+	}{
+		{"gzip", func(s storage.Storage) (storage.Storage, error) { return NewStorage(s, gzip.DefaultCompression) }},
+		{"gzip-fastest", func(s storage.Storage) (storage.Storage, error) { return NewStorage(s, gzip.BestSpeed) }},
+		{"zstd", func(s storage.Storage) (storage.Storage, error) { return NewZstdStorage(s, zstd.SpeedDefault) }},
+		{"zstd-fastest", func(s storage.Storage) (storage.Storage, error) { return NewZstdStorage(s, zstd.SpeedFastest) }},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			m := memory.NewStorage()
+			s, err := bc.new(m)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				if err := s.Upload("db.sql", bytes.NewReader(data)); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			stored, _ := m.Bytes("db.sql")
+			b.ReportMetric(float64(len(data))/float64(len(stored)), "ratio")
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package storage
+
+import (
+	"context"
+	"io"
+)
+
+// CopyObject streams srcName of src into dstName of dst without buffering
+// it whole, src and dst may be different backends.
+func CopyObject(ctx context.Context, src Storage, srcName string, dst Storage, dstName string) error {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(src.DownloadContext(ctx, srcName, pw))
+	}()
+
+	err := dst.UploadContext(ctx, dstName, pr)
+	// unblock download if upload stopped early
+	pr.CloseWithError(err)
+
+	return err
+}
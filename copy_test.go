@@ -0,0 +1,82 @@
+package storage_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sputnik-systems/backups-storage"
+	"github.com/sputnik-systems/backups-storage/memory"
+)
+
+// rejectingUpload fails uploads after reading the first bytes.
+type rejectingUpload struct {
+	*memory.Memory
+}
+
+var errRejected = errors.New("quota exceeded")
+
+func (s rejectingUpload) UploadContext(ctx context.Context, name string, r io.Reader) error {
+	if _, err := r.Read(make([]byte, 10)); err != nil {
+		return err
+	}
+
+	return errRejected
+}
+
+func TestCopyObject(t *testing.T) {
+	src, dst := memory.NewStorage(), memory.NewStorage()
+	data := bytes.Repeat([]byte("0123456789"), 100000)
+	src.Seed("db/dump.sql", data, time.Now())
+
+	if err := storage.CopyObject(context.Background(), src, "db/dump.sql", dst, "copy/dump.sql"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, ok := dst.Bytes("copy/dump.sql"); !ok || !bytes.Equal(got, data) {
+		t.Errorf("copied %d bytes, want %d", len(got), len(data))
+	}
+
+	if err := storage.CopyObject(context.Background(), src, "missing", dst, "missing"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("copy of missing object: %v", err)
+	}
+
+	if _, ok := dst.Bytes("missing"); ok {
+		t.Error("copy of missing object stored")
+	}
+}
+
+func TestCopyObjectFailure(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 100000)
+
+	broken := brokenDownload{memory.NewStorage()}
+	broken.Seed("db.sql", data, time.Now())
+	dst := memory.NewStorage()
+	if err := storage.CopyObject(context.Background(), broken, "db.sql", dst, "db.sql"); !errors.Is(err, errBroken) {
+		t.Errorf("copy with broken download: got %v, want %v", err, errBroken)
+	}
+
+	if _, ok := dst.Bytes("db.sql"); ok {
+		t.Error("broken copy stored")
+	}
+
+	// download blocked on the pipe is released by failed upload
+	src := memory.NewStorage()
+	src.Seed("db.sql", data, time.Now())
+	done := make(chan error)
+	go func() {
+		done <- storage.CopyObject(context.Background(), src, "db.sql", rejectingUpload{memory.NewStorage()}, "db.sql")
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, errRejected) {
+			t.Errorf("copy with rejected upload: got %v, want %v", err, errRejected)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("copy with rejected upload hangs")
+	}
+}
@@ -0,0 +1,283 @@
+package crypto
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/sputnik-systems/backups-storage"
+)
+
+// Objects are stored as a header followed by segments of plaintext sealed
+// with AES-256-GCM under a random per object data key:
+//
+//	magic | master nonce | data key sealed with master key | nonce prefix |
+//	segment 0 | ... | segment n
+//
+// Segment nonce is the prefix, big endian segment counter and a flag set
+// for the last one, so reordered, truncated or extended streams are
+// rejected.
+
+const (
+	keySize        = 32
+	noncePrefixLen = 7
+	segmentSize    = 64 * 1024
+)
+
+var magic = [4]byte{'B', 'S', 'E', 1}
+
+var (
+	ErrInvalidKey = errors.New("crypto: invalid master key or corrupted header")
+	ErrTampered   = errors.New("crypto: message authentication failed")
+)
+
+type Crypto struct {
+	storage.Storage
+	master cipher.AEAD
+}
+
+type encryptReader struct {
+	src     io.Reader
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	buf     []byte // plaintext carried over to the next segment
+	out     []byte // sealed data not yet returned
+	done    bool
+}
+
+type decryptWriter struct {
+	dst     io.Writer
+	master  cipher.AEAD
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	buf     []byte
+}
+
+func NewStorage(s storage.Storage, masterKey []byte) (storage.Storage, error) {
+	master, err := newAEAD(masterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Crypto{
+		Storage: s,
+		master:  master,
+	}, nil
+}
+
+func (s *Crypto) Upload(name string, buf io.Reader) error {
+	return s.UploadContext(context.Background(), name, buf)
+}
+
+func (s *Crypto) UploadContext(ctx context.Context, name string, buf io.Reader) error {
+	r, err := s.encrypt(buf)
+	if err != nil {
+		return err
+	}
+
+	return s.Storage.UploadContext(ctx, name, r)
+}
+
+func (s *Crypto) Download(name string, buf io.Writer) error {
+	return s.DownloadContext(context.Background(), name, buf)
+}
+
+func (s *Crypto) DownloadContext(ctx context.Context, name string, buf io.Writer) error {
+	w := &decryptWriter{
+		dst:    buf,
+		master: s.master,
+	}
+
+	if err := s.Storage.DownloadContext(ctx, name, w); err != nil {
+		return err
+	}
+
+	return w.Close()
+}
+
+func (s *Crypto) encrypt(src io.Reader) (io.Reader, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, s.master.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	prefix := make([]byte, noncePrefixLen)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+
+	header := append([]byte(nil), magic[:]...)
+	header = append(header, nonce...)
+	header = s.master.Seal(header, nonce, key, magic[:])
+	header = append(header, prefix...)
+
+	return &encryptReader{
+		src:    src,
+		aead:   aead,
+		prefix: prefix,
+		buf:    make([]byte, 0, segmentSize+1),
+		out:    header,
+	}, nil
+}
+
+func (r *encryptReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+
+		if err := r.seal(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+
+	return n, nil
+}
+
+// seal reads one byte past the segment to find out whether it is the last.
+func (r *encryptReader) seal() error {
+	n, err := io.ReadFull(r.src, r.buf[len(r.buf):segmentSize+1])
+	r.buf = r.buf[:len(r.buf)+n]
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+
+	last := err != nil
+	size := len(r.buf)
+	if !last {
+		size = segmentSize
+	}
+
+	out := make([]byte, 0, size+r.aead.Overhead())
+	r.out = r.aead.Seal(out, segmentNonce(r.prefix, r.counter, last), r.buf[:size], nil)
+	r.counter++
+
+	r.buf = append(r.buf[:0], r.buf[size:]...)
+	r.done = last
+
+	return nil
+}
+
+func (w *decryptWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+
+	if w.aead == nil {
+		if err := w.header(); err != nil || w.aead == nil {
+			return len(p), err
+		}
+	}
+
+	// the last segment is not known until the stream is closed
+	sealed := segmentSize + w.aead.Overhead()
+	off := 0
+	for len(w.buf)-off > sealed {
+		if err := w.open(w.buf[off:off+sealed], false); err != nil {
+			return len(p), err
+		}
+		off += sealed
+	}
+	w.buf = append(w.buf[:0], w.buf[off:]...)
+
+	return len(p), nil
+}
+
+func (w *decryptWriter) Close() error {
+	if w.aead == nil {
+		if err := w.header(); err != nil {
+			return err
+		}
+
+		if w.aead == nil {
+			return fmt.Errorf("%w: truncated header", ErrTampered)
+		}
+	}
+
+	return w.open(w.buf, true)
+}
+
+func (w *decryptWriter) header() error {
+	nonceSize := w.master.NonceSize()
+	sealedKey := keySize + w.master.Overhead()
+	size := len(magic) + nonceSize + sealedKey + noncePrefixLen
+	if len(w.buf) < size {
+		return nil
+	}
+
+	if string(w.buf[:len(magic)]) != string(magic[:]) {
+		return fmt.Errorf("%w: not an encrypted object", ErrTampered)
+	}
+
+	nonce := w.buf[len(magic) : len(magic)+nonceSize]
+	sealed := w.buf[len(magic)+nonceSize : len(magic)+nonceSize+sealedKey]
+	key, err := w.master.Open(nil, nonce, sealed, magic[:])
+	if err != nil {
+		return ErrInvalidKey
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+
+	w.aead = aead
+	w.prefix = append([]byte(nil), w.buf[size-noncePrefixLen:size]...)
+	w.buf = append(w.buf[:0], w.buf[size:]...)
+
+	return nil
+}
+
+func (w *decryptWriter) open(sealed []byte, last bool) error {
+	b, err := w.aead.Open(nil, segmentNonce(w.prefix, w.counter, last), sealed, nil)
+	if err != nil {
+		return ErrTampered
+	}
+	w.counter++
+
+	_, err = w.dst.Write(b)
+
+	return err
+}
+
+func segmentNonce(prefix []byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, noncePrefixLen+5)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[noncePrefixLen:], counter)
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+
+	return nonce
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != keySize {
+		return nil, fmt.Errorf("invalid key length %d, must be %d bytes", len(key), keySize)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,132 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sputnik-systems/backups-storage/memory"
+)
+
+func key(t *testing.T) []byte {
+	k := make([]byte, keySize)
+	if _, err := rand.Read(k); err != nil {
+		t.Fatal(err)
+	}
+
+	return k
+}
+
+func TestRoundTrip(t *testing.T) {
+	m := memory.NewStorage()
+	s, err := NewStorage(m, key(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// empty, within a segment, exactly segments and past them
+	for _, size := range []int{0, 1, segmentSize - 1, segmentSize, 2 * segmentSize, 3*segmentSize + 17} {
+		data := make([]byte, size)
+		rand.Read(data)
+
+		if err := s.Upload("db.sql", bytes.NewReader(data)); err != nil {
+			t.Fatal(err)
+		}
+
+		stored, _ := m.Bytes("db.sql")
+		// short plaintext may occur in ciphertext by chance
+		if size > 16 && bytes.Contains(stored, data) {
+			t.Errorf("%d bytes: plaintext stored", size)
+		}
+
+		var buf bytes.Buffer
+		if err := s.Download("db.sql", &buf); err != nil {
+			t.Fatalf("%d bytes: %v", size, err)
+		}
+
+		if !bytes.Equal(buf.Bytes(), data) {
+			t.Errorf("%d bytes: downloaded %d bytes", size, buf.Len())
+		}
+	}
+}
+
+func TestTampered(t *testing.T) {
+	m := memory.NewStorage()
+	s, err := NewStorage(m, key(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := bytes.Repeat([]byte("x"), 2*segmentSize+100)
+	if err := s.Upload("db.sql", bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := m.Bytes("db.sql")
+
+	// gcm nonce is 12 bytes and tag is 16
+	header := len(magic) + 12 + keySize + 16 + noncePrefixLen
+	sealed := segmentSize + 16
+
+	for name, change := range map[string]func([]byte) []byte{
+		"flipped byte": func(b []byte) []byte {
+			b[header+10] ^= 1
+
+			return b
+		},
+		"truncated": func(b []byte) []byte {
+			return b[:header+sealed]
+		},
+		"segments swapped": func(b []byte) []byte {
+			out := append([]byte(nil), b[:header]...)
+			out = append(out, b[header+sealed:header+2*sealed]...)
+			out = append(out, b[header:header+sealed]...)
+
+			return append(out, b[header+2*sealed:]...)
+		},
+		"extended": func(b []byte) []byte {
+			return append(b, 0)
+		},
+		"truncated header": func(b []byte) []byte {
+			return b[:header-1]
+		},
+	} {
+		m.Seed("tampered", change(bytes.Clone(stored)), time.Time{})
+
+		err := s.Download("tampered", &bytes.Buffer{})
+		if !errors.Is(err, ErrTampered) {
+			t.Errorf("%s: got %v, want %v", name, err, ErrTampered)
+		}
+	}
+}
+
+func TestWrongKey(t *testing.T) {
+	m := memory.NewStorage()
+	s, err := NewStorage(m, key(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Upload("db.sql", bytes.NewReader([]byte("data"))); err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := NewStorage(m, key(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := other.Download("db.sql", &buf); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("got %v, want %v", err, ErrInvalidKey)
+	}
+
+	if buf.Len() != 0 {
+		t.Errorf("wrote %d bytes with wrong key", buf.Len())
+	}
+
+	if _, err := NewStorage(m, []byte("short")); err == nil {
+		t.Error("master key of 5 bytes accepted")
+	}
+}
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// UploadFile uploads local file as name. File is passed unbuffered, so
+// storages can detect its size.
+func UploadFile(ctx context.Context, s Storage, name, localPath string) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return s.UploadContext(ctx, name, f)
+}
+
+// DownloadTo downloads name once into all writers, e.g. a file and a hash.
+// The first writer error stops the download and is returned.
+func DownloadTo(ctx context.Context, s Storage, name string, writers ...io.Writer) error {
+	return s.DownloadContext(ctx, name, io.MultiWriter(writers...))
+}
+
+// DownloadFile downloads name into local file. Object is written into a
+// temporary file renamed on success, so failed restores leave no partial
+// file behind.
+func DownloadFile(ctx context.Context, s Storage, name, localPath string) (err error) {
+	f, err := os.CreateTemp(filepath.Dir(localPath), "."+filepath.Base(localPath)+".*")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	w := bufio.NewWriterSize(f, 1024*1024)
+	if err = s.DownloadContext(ctx, name, w); err != nil {
+		return err
+	}
+
+	if err = w.Flush(); err != nil {
+		return err
+	}
+
+	if err = f.Sync(); err != nil {
+		return err
+	}
+
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), localPath)
+}
+
+// DownloadAll downloads names into destDir keeping their directories, at
+// most concurrency at a time. Failed downloads do not stop the others, the
+// returned error joins errors of every failed name.
+func DownloadAll(ctx context.Context, s Storage, names []string, destDir string, concurrency int) error {
+	if concurrency < 1 {
+		return fmt.Errorf("invalid concurrency %d", concurrency)
+	}
+
+	var mu sync.Mutex
+	var errs []error
+
+	var wg sync.WaitGroup
+	queue := make(chan string)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for name := range queue {
+				if err := downloadInto(ctx, s, name, destDir); err != nil {
+					mu.Lock()
+					errs = append(errs, fmt.Errorf("download %s: %w", name, err))
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	for _, name := range names {
+		queue <- name
+	}
+	close(queue)
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+func downloadInto(ctx context.Context, s Storage, name, destDir string) error {
+	// canceled downloads are reported per name as well
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// object names are not trusted to stay inside destination
+	rel := filepath.FromSlash(name)
+	if !filepath.IsLocal(rel) {
+		return fmt.Errorf("name escapes %s", destDir)
+	}
+	localPath := filepath.Join(destDir, rel)
+
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return err
+	}
+
+	return DownloadFile(ctx, s, name, localPath)
+}
@@ -0,0 +1,196 @@
+package storage_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sputnik-systems/backups-storage"
+	"github.com/sputnik-systems/backups-storage/memory"
+)
+
+// brokenDownload writes the first half of objects and fails.
+type brokenDownload struct {
+	*memory.Memory
+}
+
+var errBroken = errors.New("connection reset")
+
+func (s brokenDownload) DownloadContext(ctx context.Context, name string, w io.Writer) error {
+	data, ok := s.Bytes(name)
+	if !ok {
+		return storage.ErrNotFound
+	}
+
+	if _, err := w.Write(data[:len(data)/2]); err != nil {
+		return err
+	}
+
+	return errBroken
+}
+
+func TestFileRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	s := memory.NewStorage()
+
+	data := bytes.Repeat([]byte("0123456789"), 300000)
+	src := filepath.Join(dir, "dump.sql")
+	if err := os.WriteFile(src, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := storage.UploadFile(context.Background(), s, "db/dump.sql", src); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(dir, "restored.sql")
+	if err := storage.DownloadFile(context.Background(), s, "db/dump.sql", dst); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := os.ReadFile(dst); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("restored %d bytes, %v, want %d", len(got), err, len(data))
+	}
+
+	if err := storage.UploadFile(context.Background(), s, "missing", filepath.Join(dir, "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("upload of missing file: %v", err)
+	}
+}
+
+func TestDownloadFileFailure(t *testing.T) {
+	dir := t.TempDir()
+	s := brokenDownload{memory.NewStorage()}
+	s.Seed("db/dump.sql", bytes.Repeat([]byte("x"), 1000), time.Now())
+
+	// target of a previous restore is kept
+	dst := filepath.Join(dir, "restored.sql")
+	if err := os.WriteFile(dst, []byte("previous"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := storage.DownloadFile(context.Background(), s, "db/dump.sql", dst); !errors.Is(err, errBroken) {
+		t.Fatalf("got %v, want %v", err, errBroken)
+	}
+
+	if got, err := os.ReadFile(dst); err != nil || string(got) != "previous" {
+		t.Errorf("target is %q, %v", got, err)
+	}
+
+	if err := storage.DownloadFile(context.Background(), s, "db/dump.sql", filepath.Join(dir, "new.sql")); err == nil {
+		t.Fatal("broken download succeeded")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || entries[0].Name() != "restored.sql" {
+		for _, e := range entries {
+			t.Errorf("left %s", e.Name())
+		}
+	}
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+var errWrite = errors.New("disk full")
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errWrite }
+
+func TestDownloadTo(t *testing.T) {
+	s := memory.NewStorage()
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	s.Seed("db.sql", data, time.Now())
+
+	var a, b bytes.Buffer
+	if err := storage.DownloadTo(context.Background(), s, "db.sql", &a, &b); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(a.Bytes(), data) || !bytes.Equal(b.Bytes(), data) {
+		t.Errorf("downloaded %d and %d bytes, want %d", a.Len(), b.Len(), len(data))
+	}
+
+	// writers after the failed one get nothing
+	a.Reset()
+	b.Reset()
+	if err := storage.DownloadTo(context.Background(), s, "db.sql", &a, failingWriter{}, &b); !errors.Is(err, errWrite) {
+		t.Errorf("got %v, want %v", err, errWrite)
+	}
+
+	if b.Len() != 0 {
+		t.Errorf("wrote %d bytes after failed writer", b.Len())
+	}
+}
+
+func TestDownloadAll(t *testing.T) {
+	dir := t.TempDir()
+	s := memory.NewStorage()
+
+	var names []string
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("db/%d/dump.sql", i)
+		s.Seed(name, []byte(name), time.Now())
+		names = append(names, name)
+	}
+
+	if err := storage.DownloadAll(context.Background(), s, names, dir, 4); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range names {
+		if got, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name))); err != nil || string(got) != name {
+			t.Errorf("%s: restored %q, %v", name, got, err)
+		}
+	}
+
+	if err := storage.DownloadAll(context.Background(), s, names, dir, 0); err == nil {
+		t.Error("download with no workers succeeded")
+	}
+}
+
+func TestDownloadAllFailures(t *testing.T) {
+	dir := t.TempDir()
+	s := memory.NewStorage()
+	s.Seed("a.sql", []byte("a"), time.Now())
+	s.Seed("b.sql", []byte("b"), time.Now())
+
+	err := storage.DownloadAll(context.Background(), s, []string{"a.sql", "missing.sql", "../escape.sql", "b.sql"}, dir, 2)
+	if !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("got %v, want %v", err, storage.ErrNotFound)
+	}
+
+	for _, name := range []string{"missing.sql", "../escape.sql"} {
+		if !strings.Contains(err.Error(), "download "+name) {
+			t.Errorf("error %q does not mention %s", err, name)
+		}
+	}
+
+	// failures do not stop the other downloads
+	for _, name := range []string{"a.sql", "b.sql"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "..", "escape.sql")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("escaped destination: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = storage.DownloadAll(ctx, s, []string{"a.sql", "b.sql"}, t.TempDir(), 2)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled download: %v", err)
+	}
+}
@@ -0,0 +1,340 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sputnik-systems/backups-storage"
+)
+
+type FS struct {
+	root string
+}
+
+type FileInfo struct {
+	name  string
+	size  int64
+	mtime time.Time
+	isdir bool
+}
+
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func NewStorage(root string) storage.Storage {
+	return &FS{
+		root: root,
+	}
+}
+
+func (s *FS) List() ([]storage.FileInfo, error) {
+	return s.ListContext(context.Background())
+}
+
+func (s *FS) ListContext(ctx context.Context) ([]storage.FileInfo, error) {
+	fi := make([]storage.FileInfo, 0)
+	err := filepath.WalkDir(s.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if p == s.root {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return err
+		}
+
+		name := filepath.ToSlash(rel)
+		if isTemp(name) {
+			return nil
+		}
+
+		if d.IsDir() {
+			fi = append(fi, &FileInfo{name + "/", int64(0), info.ModTime(), true})
+		} else {
+			fi = append(fi, &FileInfo{name, info.Size(), info.ModTime(), false})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fi, err
+	}
+
+	sort.Slice(fi, func(i, j int) bool {
+		return fi[i].Name() > fi[j].Name()
+	})
+
+	return fi, nil
+}
+
+func (s *FS) ListFunc(prefix string, fn func(storage.FileInfo) error) error {
+	return filepath.WalkDir(s.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if p == s.root {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return err
+		}
+
+		name := filepath.ToSlash(rel)
+		if d.IsDir() {
+			// skip directories which can not contain matching files
+			if !strings.HasPrefix(name+"/", prefix) && !strings.HasPrefix(prefix, name+"/") {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !strings.HasPrefix(name, prefix) || isTemp(name) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		return fn(&FileInfo{name, info.Size(), info.ModTime(), false})
+	})
+}
+
+func (s *FS) Delete(name string) error {
+	return s.DeleteContext(context.Background(), name)
+}
+
+func (s *FS) DeleteContext(ctx context.Context, name string) error {
+	p, err := s.path(name)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(p)
+}
+
+func (s *FS) DeleteBatch(names []string) error {
+	for _, name := range names {
+		p, err := s.path(name)
+		if err != nil {
+			return err
+		}
+
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *FS) Upload(name string, buf io.Reader) error {
+	return s.UploadContext(context.Background(), name, buf)
+}
+
+func (s *FS) UploadContext(ctx context.Context, name string, buf io.Reader) (err error) {
+	p, err := s.path(name)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+
+	// write into temporary file, so partial uploads are never visible under name
+	f, err := os.CreateTemp(filepath.Dir(p), "."+filepath.Base(p)+".upload-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	if _, err = io.Copy(f, &ctxReader{ctx, buf}); err != nil {
+		return err
+	}
+
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), p)
+}
+
+func (s *FS) Download(name string, buf io.Writer) error {
+	return s.DownloadContext(context.Background(), name, buf)
+}
+
+func (s *FS) DownloadContext(ctx context.Context, name string, buf io.Writer) error {
+	p, err := s.path(name)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%s: %w", name, storage.ErrNotFound)
+		}
+
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(buf, &ctxReader{ctx, f})
+
+	return err
+}
+
+func (s *FS) Exists(name string) (bool, error) {
+	p, err := s.path(name)
+	if err != nil {
+		return false, err
+	}
+
+	info, err := os.Stat(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return !info.IsDir(), nil
+}
+
+func (s *FS) Stat(name string) (storage.FileInfo, error) {
+	p, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%s: %w", name, storage.ErrNotFound)
+		}
+
+		return nil, err
+	}
+
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s: %w", name, storage.ErrNotFound)
+	}
+
+	return &FileInfo{name, info.Size(), info.ModTime(), false}, nil
+}
+
+func (s *FS) Copy(src, dst string) error {
+	p, err := s.path(src)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%s: %w", src, storage.ErrNotFound)
+		}
+
+		return err
+	}
+	defer f.Close()
+
+	return s.Upload(dst, f)
+}
+
+func (s *FS) Move(src, dst string) error {
+	sp, err := s.path(src)
+	if err != nil {
+		return err
+	}
+
+	dp, err := s.path(dst)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dp), 0755); err != nil {
+		return err
+	}
+
+	if err := os.Rename(sp, dp); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%s: %w", src, storage.ErrNotFound)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// path returns file path of the name, names escaping root are rejected.
+func (s *FS) path(name string) (string, error) {
+	if err := storage.ValidateName(name); err != nil {
+		return "", err
+	}
+
+	return filepath.Join(s.root, filepath.FromSlash(name)), nil
+}
+
+// isTemp reports whether name is temporary file of upload in progress.
+func isTemp(name string) bool {
+	base := path.Base(name)
+
+	return strings.HasPrefix(base, ".") && strings.Contains(base, ".upload-")
+}
+
+func (r *ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return r.r.Read(p)
+}
+
+func (f *FileInfo) Name() string { return f.name }
+
+func (f *FileInfo) Size() int64 { return f.size }
+
+func (f *FileInfo) ModTime() time.Time { return f.mtime }
+
+func (f *FileInfo) IsDir() bool { return f.isdir }
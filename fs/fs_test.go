@@ -0,0 +1,134 @@
+package fs
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sputnik-systems/backups-storage"
+)
+
+// names returns names of listed entries in order.
+func names(fi []storage.FileInfo) []string {
+	n := make([]string, 0, len(fi))
+	for _, f := range fi {
+		n = append(n, f.Name())
+	}
+
+	return n
+}
+
+func TestNestedDirectories(t *testing.T) {
+	root := t.TempDir()
+	s := NewStorage(root)
+
+	for _, name := range []string{"a/b/c.sql", "a/d.sql", "e.sql"} {
+		if err := s.Upload(name, strings.NewReader(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fi, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "e.sql a/d.sql a/b/c.sql a/b/ a/"
+	if got := strings.Join(names(fi), " "); got != want {
+		t.Errorf("listed %s, want %s", got, want)
+	}
+
+	for _, f := range fi {
+		if f.IsDir() != strings.HasSuffix(f.Name(), "/") {
+			t.Errorf("%s: directory %v", f.Name(), f.IsDir())
+		}
+
+		if !f.IsDir() && f.Size() != int64(len(f.Name())) {
+			t.Errorf("%s: size %d, want %d", f.Name(), f.Size(), len(f.Name()))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := s.Download("a/b/c.sql", &buf); err != nil || buf.String() != "a/b/c.sql" {
+		t.Errorf("downloaded %q, %v", buf.String(), err)
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "a", "b", "c.sql")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	s := NewStorage(t.TempDir())
+
+	for _, name := range []string{"a/b/c.sql", "a/d.sql", "e.sql"} {
+		if err := s.Upload(name, strings.NewReader(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// single object
+	if err := s.Delete("e.sql"); err != nil {
+		t.Fatal(err)
+	}
+
+	// whole subtree
+	if err := s.Delete("a/b"); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(names(fi), " "); got != "a/d.sql a/" {
+		t.Errorf("left %s, want a/d.sql a/", got)
+	}
+
+	if err := s.Download("e.sql", &bytes.Buffer{}); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("download of deleted object: %v", err)
+	}
+}
+
+func TestListSkipsUploadsInProgress(t *testing.T) {
+	root := t.TempDir()
+	s := NewStorage(root)
+
+	if err := s.Upload("a/b.sql", strings.NewReader("x")); err != nil {
+		t.Fatal(err)
+	}
+
+	// temporary file of upload of a/c.sql
+	tmp, err := os.CreateTemp(filepath.Join(root, "a"), ".c.sql.upload-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp.Close()
+
+	fi, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(names(fi), " "); got != "a/b.sql a/" {
+		t.Errorf("listed %s, want a/b.sql a/", got)
+	}
+
+	var listed []string
+	err = s.ListFunc("a/", func(f storage.FileInfo) error {
+		listed = append(listed, f.Name())
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(listed, " "); got != "a/b.sql" {
+		t.Errorf("listed %s, want a/b.sql", got)
+	}
+}
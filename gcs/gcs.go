@@ -0,0 +1,293 @@
+package gcs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	gstorage "cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+
+	"github.com/sputnik-systems/backups-storage"
+)
+
+type GCS struct {
+	c              *gstorage.Client
+	bucket, prefix string
+}
+
+type FileInfo struct {
+	name  string
+	size  int64
+	mtime time.Time
+	isdir bool
+}
+
+func NewStorage(client *gstorage.Client, bucket, prefix string) storage.Storage {
+	return &GCS{
+		c:      client,
+		bucket: bucket,
+		prefix: prefix,
+	}
+}
+
+func (s *GCS) List() ([]storage.FileInfo, error) {
+	return s.ListContext(context.Background())
+}
+
+func (s *GCS) ListContext(ctx context.Context) ([]storage.FileInfo, error) {
+	return s.list(ctx, s.key(""))
+}
+
+func (s *GCS) ListFunc(prefix string, fn func(storage.FileInfo) error) error {
+	return s.listFunc(context.Background(), s.key(prefix), fn)
+}
+
+func (s *GCS) listFunc(ctx context.Context, prefix string, fn func(storage.FileInfo) error) error {
+	it := s.c.Bucket(s.bucket).Objects(ctx, &gstorage.Query{Prefix: prefix})
+	for {
+		o, err := it.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := fn(&FileInfo{s.relName(o.Name), o.Size, o.Updated, false}); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *GCS) list(ctx context.Context, prefix string) ([]storage.FileInfo, error) {
+	fi := make([]storage.FileInfo, 0)
+	di := make(map[string]*FileInfo)
+	err := s.listFunc(ctx, prefix, func(f storage.FileInfo) error {
+		fi = append(fi, f)
+
+		// calc directories
+		dir := path.Dir(f.Name()) + "/"
+		if dir == "./" {
+			return nil
+		}
+
+		if d, ok := di[dir]; !ok || d.mtime.Before(f.ModTime()) {
+			di[dir] = &FileInfo{dir, int64(0), f.ModTime(), true}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fi, err
+	}
+
+	for _, d := range di {
+		fi = append(fi, d)
+	}
+
+	sort.Slice(fi, func(i, j int) bool {
+		return fi[i].Name() > fi[j].Name()
+	})
+
+	return fi, nil
+}
+
+func (s *GCS) Delete(name string) error {
+	return s.DeleteContext(context.Background(), name)
+}
+
+func (s *GCS) DeleteContext(ctx context.Context, name string) error {
+	if err := storage.ValidateName(name); err != nil {
+		return err
+	}
+
+	prefix := s.key(strings.TrimSuffix(name, "/"))
+	fi, err := s.list(ctx, prefix)
+	if err != nil {
+		return err
+	}
+
+	for _, o := range fi {
+		if o.IsDir() {
+			continue
+		}
+
+		err := s.c.Bucket(s.bucket).Object(s.key(o.Name())).Delete(ctx)
+		if err != nil && !errors.Is(err, gstorage.ErrObjectNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *GCS) DeleteBatch(names []string) error {
+	for _, name := range names {
+		key, err := storage.ObjectKey(s.prefix, name)
+		if err != nil {
+			return err
+		}
+
+		err = s.c.Bucket(s.bucket).Object(key).Delete(context.Background())
+		if err != nil && !errors.Is(err, gstorage.ErrObjectNotExist) {
+			return fmt.Errorf("delete %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *GCS) Upload(name string, buf io.Reader) error {
+	return s.UploadContext(context.Background(), name, buf)
+}
+
+func (s *GCS) UploadContext(ctx context.Context, name string, buf io.Reader) error {
+	key, err := storage.ObjectKey(s.prefix, name)
+	if err != nil {
+		return err
+	}
+
+	// canceling writer context is the only way to abort resumable upload
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	w := s.c.Bucket(s.bucket).Object(key).NewWriter(ctx)
+	if _, err := io.Copy(w, buf); err != nil {
+		cancel()
+		w.Close()
+
+		return err
+	}
+
+	return w.Close()
+}
+
+func (s *GCS) Download(name string, buf io.Writer) error {
+	return s.DownloadContext(context.Background(), name, buf)
+}
+
+func (s *GCS) DownloadContext(ctx context.Context, name string, buf io.Writer) error {
+	key, err := storage.ObjectKey(s.prefix, name)
+	if err != nil {
+		return err
+	}
+
+	r, err := s.c.Bucket(s.bucket).Object(key).NewReader(ctx)
+	if err != nil {
+		if errors.Is(err, gstorage.ErrObjectNotExist) {
+			return fmt.Errorf("%s: %w", key, storage.ErrNotFound)
+		}
+
+		return err
+	}
+	defer r.Close()
+
+	_, err = io.Copy(buf, r)
+
+	return err
+}
+
+func (s *GCS) Exists(name string) (bool, error) {
+	key, err := storage.ObjectKey(s.prefix, name)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := s.c.Bucket(s.bucket).Object(key).Attrs(context.Background()); err != nil {
+		if errors.Is(err, gstorage.ErrObjectNotExist) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *GCS) Stat(name string) (storage.FileInfo, error) {
+	key, err := storage.ObjectKey(s.prefix, name)
+	if err != nil {
+		return nil, err
+	}
+
+	o, err := s.c.Bucket(s.bucket).Object(key).Attrs(context.Background())
+	if err != nil {
+		if errors.Is(err, gstorage.ErrObjectNotExist) {
+			return nil, fmt.Errorf("%s: %w", key, storage.ErrNotFound)
+		}
+
+		return nil, err
+	}
+
+	return &FileInfo{s.relName(key), o.Size, o.Updated, false}, nil
+}
+
+func (s *GCS) Copy(src, dst string) error {
+	bkt := s.c.Bucket(s.bucket)
+	srcKey, err := storage.ObjectKey(s.prefix, src)
+	if err != nil {
+		return err
+	}
+
+	dstKey, err := storage.ObjectKey(s.prefix, dst)
+	if err != nil {
+		return err
+	}
+
+	// copier rewrites large objects in several calls on its own
+	if _, err := bkt.Object(dstKey).CopierFrom(bkt.Object(srcKey)).Run(context.Background()); err != nil {
+		if errors.Is(err, gstorage.ErrObjectNotExist) {
+			return fmt.Errorf("%s: %w", srcKey, storage.ErrNotFound)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (s *GCS) Move(src, dst string) error {
+	if err := s.Copy(src, dst); err != nil {
+		return fmt.Errorf("move %s: copy: %w", src, err)
+	}
+
+	key, err := storage.ObjectKey(s.prefix, src)
+	if err != nil {
+		return err
+	}
+
+	if err := s.c.Bucket(s.bucket).Object(key).Delete(context.Background()); err != nil {
+		return fmt.Errorf("move %s: delete source: %w", src, err)
+	}
+
+	return nil
+}
+
+// key joins name with the prefix keeping trailing slash, so it can be used
+// as list prefix.
+func (s *GCS) key(name string) string {
+	if s.prefix == "" {
+		return name
+	}
+
+	return strings.TrimSuffix(s.prefix, "/") + "/" + name
+}
+
+// relName strips the prefix from object key.
+func (s *GCS) relName(key string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(key, strings.TrimSuffix(s.prefix, "/")), "/")
+}
+
+func (f *FileInfo) Name() string { return f.name }
+
+func (f *FileInfo) Size() int64 { return f.size }
+
+func (f *FileInfo) ModTime() time.Time { return f.mtime }
+
+func (f *FileInfo) IsDir() bool { return f.isdir }
@@ -0,0 +1,249 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FSFileInfo adapts f to fs.FileInfo. Its Name is the base name as io/fs
+// expects, Sys returns f.
+func FSFileInfo(f FileInfo) fs.FileInfo {
+	return fileInfo{f}
+}
+
+type fileInfo struct {
+	FileInfo
+}
+
+func (f fileInfo) Name() string {
+	return path.Base(strings.TrimSuffix(f.FileInfo.Name(), "/"))
+}
+
+// Mode reports read only permissions, as storages have no notion of them.
+func (f fileInfo) Mode() fs.FileMode {
+	if f.IsDir() {
+		return fs.ModeDir | 0555
+	}
+
+	return 0444
+}
+
+func (f fileInfo) Sys() interface{} { return f.FileInfo }
+
+// FS returns read only file system view of s. Files are streamed from
+// Download as they are read, directories are synthesized from object
+// names like in List.
+func FS(s Storage) fs.FS {
+	return &fsys{s}
+}
+
+type fsys struct {
+	s Storage
+}
+
+func (fsys *fsys) Open(name string) (fs.File, error) {
+	fi, err := fsys.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if fi.IsDir() {
+		return &dirFile{fsys: fsys, name: name, info: fi}, nil
+	}
+
+	return &file{s: fsys.s, name: name, info: fi}, nil
+}
+
+func (fsys *fsys) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if name == "." {
+		return dirInfo{".", time.Time{}}, nil
+	}
+
+	f, err := fsys.s.Stat(name)
+	if err == nil {
+		return FSFileInfo(f), nil
+	}
+
+	if !errors.Is(err, ErrNotFound) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+
+	// directories exist as long as they have objects
+	var mtime time.Time
+	found := false
+	err = fsys.s.ListFunc(name+"/", func(f FileInfo) error {
+		found = true
+		if f.ModTime().After(mtime) {
+			mtime = f.ModTime()
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+
+	if !found {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return dirInfo{path.Base(name), mtime}, nil
+}
+
+// ReadDir returns entries directly under name sorted by file name.
+func (fsys *fsys) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	prefix := ""
+	if name != "." {
+		prefix = name + "/"
+	}
+
+	files := make(map[string]fs.FileInfo)
+	dirs := make(map[string]time.Time)
+	err := fsys.s.ListFunc(prefix, func(f FileInfo) error {
+		rel := strings.TrimPrefix(f.Name(), prefix)
+		if rel == "" {
+			return nil
+		}
+
+		if i := strings.Index(rel, "/"); i >= 0 {
+			d := rel[:i]
+			if mtime, ok := dirs[d]; !ok || f.ModTime().After(mtime) {
+				dirs[d] = f.ModTime()
+			}
+
+			return nil
+		}
+
+		files[rel] = FSFileInfo(f)
+
+		return nil
+	})
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+
+	if name != "." && len(files) == 0 && len(dirs) == 0 {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	entries := make([]fs.DirEntry, 0, len(files)+len(dirs))
+	for _, f := range files {
+		entries = append(entries, fs.FileInfoToDirEntry(f))
+	}
+
+	for d, mtime := range dirs {
+		entries = append(entries, fs.FileInfoToDirEntry(dirInfo{d, mtime}))
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return entries, nil
+}
+
+// file downloads object on the first Read.
+type file struct {
+	s    Storage
+	name string
+	info fs.FileInfo
+	pr   *io.PipeReader
+}
+
+func (f *file) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+func (f *file) Read(p []byte) (int, error) {
+	if f.pr == nil {
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(f.s.Download(f.name, pw))
+		}()
+		f.pr = pr
+	}
+
+	return f.pr.Read(p)
+}
+
+func (f *file) Close() error {
+	// stop download of partially read file
+	if f.pr != nil {
+		f.pr.CloseWithError(fs.ErrClosed)
+	}
+
+	return nil
+}
+
+type dirFile struct {
+	fsys    *fsys
+	name    string
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	read    bool
+}
+
+func (d *dirFile) Stat() (fs.FileInfo, error) { return d.info, nil }
+
+func (d *dirFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: fs.ErrInvalid}
+}
+
+func (d *dirFile) Close() error { return nil }
+
+func (d *dirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.read {
+		entries, err := d.fsys.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+
+		d.entries = entries
+		d.read = true
+	}
+
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+
+		return entries, nil
+	}
+
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	n = min(n, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+
+	return entries, nil
+}
+
+type dirInfo struct {
+	name  string
+	mtime time.Time
+}
+
+func (d dirInfo) Name() string { return d.name }
+
+func (d dirInfo) Size() int64 { return 0 }
+
+func (d dirInfo) Mode() fs.FileMode { return fs.ModeDir | 0555 }
+
+func (d dirInfo) ModTime() time.Time { return d.mtime }
+
+func (d dirInfo) IsDir() bool { return true }
+
+func (d dirInfo) Sys() interface{} { return nil }
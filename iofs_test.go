@@ -0,0 +1,134 @@
+package storage_test
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/sputnik-systems/backups-storage"
+	"github.com/sputnik-systems/backups-storage/memory"
+)
+
+func TestFSFileInfo(t *testing.T) {
+	m := memory.NewStorage()
+	m.Seed("db/nested/dump.sql", make([]byte, 10), day)
+
+	fi, err := m.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range fi {
+		info := storage.FSFileInfo(f)
+		if info.Sys() != f {
+			t.Errorf("%s: sys %v", f.Name(), info.Sys())
+		}
+
+		if info.Size() != f.Size() || !info.ModTime().Equal(day) || info.IsDir() != f.IsDir() {
+			t.Errorf("%s: size %d, mtime %s, directory %v", f.Name(), info.Size(), info.ModTime(), info.IsDir())
+		}
+
+		want, mode := "dump.sql", fs.FileMode(0444)
+		if f.IsDir() {
+			want, mode = "nested", fs.ModeDir|0555
+		}
+
+		if info.Name() != want || info.Mode() != mode || info.Mode().IsDir() != f.IsDir() {
+			t.Errorf("%s: name %s, mode %s, want %s, %s", f.Name(), info.Name(), info.Mode(), want, mode)
+		}
+
+		if e := fs.FileInfoToDirEntry(info); e.Name() != want || e.Type() != mode.Type() {
+			t.Errorf("%s: entry %s of type %s", f.Name(), e.Name(), e.Type())
+		}
+	}
+
+	if len(fi) != 2 {
+		t.Errorf("listed %d entries, want 2", len(fi))
+	}
+}
+
+func TestFS(t *testing.T) {
+	m := memory.NewStorage()
+	m.Seed("db/a.sql", []byte("a"), day)
+	m.Seed("db/nested/b.sql", []byte("bb"), day.Add(time.Hour))
+	m.Seed("db/nested/deep/c.sql", []byte("ccc"), day)
+	m.Seed("top.sql", []byte("top"), day)
+	fsys := storage.FS(m)
+
+	if err := fstest.TestFS(fsys, "db/a.sql", "db/nested/b.sql", "db/nested/deep/c.sql", "top.sql"); err != nil {
+		t.Fatal(err)
+	}
+
+	if b, err := fs.ReadFile(fsys, "db/nested/b.sql"); err != nil || string(b) != "bb" {
+		t.Errorf("read %q, %v", b, err)
+	}
+
+	entries, err := fs.ReadDir(fsys, "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, e := range entries {
+		got = append(got, fmt.Sprintf("%s:%v", e.Name(), e.IsDir()))
+	}
+
+	if s := strings.Join(got, " "); s != "a.sql:false nested:true" {
+		t.Errorf("read dir %s", s)
+	}
+
+	// directory is as new as its newest object
+	if fi, err := fs.Stat(fsys, "db/nested"); err != nil || !fi.IsDir() || !fi.ModTime().Equal(day.Add(time.Hour)) {
+		t.Errorf("stat of directory %v, %v", fi, err)
+	}
+
+	matches, err := fs.Glob(fsys, "db/*/*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := strings.Join(matches, " "); s != "db/nested/b.sql" {
+		t.Errorf("glob matched %s", s)
+	}
+
+	var walked []string
+	err = fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		walked = append(walked, p)
+
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := ". db db/a.sql db/nested db/nested/b.sql db/nested/deep db/nested/deep/c.sql top.sql"
+	if s := strings.Join(walked, " "); s != want {
+		t.Errorf("walked %s, want %s", s, want)
+	}
+}
+
+func TestFSErrors(t *testing.T) {
+	m := memory.NewStorage()
+	m.Seed("db/a.sql", []byte("a"), day)
+	fsys := storage.FS(m)
+
+	for _, name := range []string{"missing", "db/missing", "d"} {
+		if _, err := fsys.Open(name); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("open %s: %v", name, err)
+		}
+	}
+
+	for _, name := range []string{"/db/a.sql", "db/../db/a.sql", "db/"} {
+		if _, err := fsys.Open(name); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("open %s: %v", name, err)
+		}
+	}
+
+	if _, err := fs.ReadDir(fsys, "missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("read dir of missing: %v", err)
+	}
+}
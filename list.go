@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// ListBySize returns objects with the name prefix of size within
+// [minSize, maxSize], maxSize <= 0 stands for no upper bound, e.g. to find
+// truncated backups.
+func ListBySize(ctx context.Context, s Storage, prefix string, minSize, maxSize int64) ([]FileInfo, error) {
+	return listFilter(ctx, s, prefix, func(f FileInfo) bool {
+		return f.Size() >= minSize && (maxSize <= 0 || f.Size() <= maxSize)
+	})
+}
+
+// ListByTime returns objects with the name prefix modified within [from, to],
+// zero from or to stands for open bound.
+func ListByTime(ctx context.Context, s Storage, prefix string, from, to time.Time) ([]FileInfo, error) {
+	return listFilter(ctx, s, prefix, func(f FileInfo) bool {
+		return (from.IsZero() || !f.ModTime().Before(from)) && (to.IsZero() || !f.ModTime().After(to))
+	})
+}
+
+// SortField is the field objects are ordered by in ListSorted.
+type SortField int
+
+const (
+	SortByName SortField = iota
+	SortBySize
+	SortByModTime
+)
+
+// ListSorted returns objects with the name prefix ordered by the field,
+// objects equal by it are ordered by name.
+func ListSorted(ctx context.Context, s Storage, prefix string, by SortField, desc bool) ([]FileInfo, error) {
+	fi, err := listFilter(ctx, s, prefix, func(FileInfo) bool { return true })
+	if err != nil {
+		return fi, err
+	}
+
+	less := func(a, b FileInfo) bool {
+		switch by {
+		case SortBySize:
+			if a.Size() != b.Size() {
+				return a.Size() < b.Size()
+			}
+		case SortByModTime:
+			if !a.ModTime().Equal(b.ModTime()) {
+				return a.ModTime().Before(b.ModTime())
+			}
+		}
+
+		return a.Name() < b.Name()
+	}
+
+	sort.Slice(fi, func(i, j int) bool {
+		if desc {
+			return less(fi[j], fi[i])
+		}
+
+		return less(fi[i], fi[j])
+	})
+
+	return fi, nil
+}
+
+// listFilter returns objects with the name prefix accepted by fn sorted
+// like List does.
+func listFilter(ctx context.Context, s Storage, prefix string, fn func(FileInfo) bool) ([]FileInfo, error) {
+	fi := make([]FileInfo, 0)
+	err := s.ListFunc(prefix, func(f FileInfo) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if !f.IsDir() && fn(f) {
+			fi = append(fi, f)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fi, err
+	}
+
+	sort.Slice(fi, func(i, j int) bool {
+		return fi[i].Name() > fi[j].Name()
+	})
+
+	return fi, nil
+}
@@ -0,0 +1,114 @@
+package storage_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sputnik-systems/backups-storage"
+	"github.com/sputnik-systems/backups-storage/memory"
+)
+
+// listed returns names of listed objects in order.
+func listed(fi []storage.FileInfo) string {
+	n := make([]string, 0, len(fi))
+	for _, f := range fi {
+		n = append(n, f.Name())
+	}
+
+	return strings.Join(n, " ")
+}
+
+func TestListBySize(t *testing.T) {
+	m := memory.NewStorage()
+	for name, size := range map[string]int{"db/empty.sql": 0, "db/small.sql": 10, "db/mid.sql": 100, "db/huge.sql": 1000, "other.sql": 100} {
+		m.Seed(name, make([]byte, size), day)
+	}
+	s := listingDirs{m}
+
+	for _, tc := range []struct {
+		min, max int64
+		want     string
+	}{
+		{10, 100, "db/small.sql db/mid.sql"},
+		{11, 99, ""},
+		{0, 0, "db/small.sql db/mid.sql db/huge.sql db/empty.sql"},
+		{100, 0, "db/mid.sql db/huge.sql"},
+		{100, -1, "db/mid.sql db/huge.sql"},
+		{0, 9, "db/empty.sql"},
+	} {
+		fi, err := storage.ListBySize(context.Background(), s, "db/", tc.min, tc.max)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := listed(fi); got != tc.want {
+			t.Errorf("[%d, %d]: listed %q, want %q", tc.min, tc.max, got, tc.want)
+		}
+	}
+}
+
+func TestListByTime(t *testing.T) {
+	m := memory.NewStorage()
+	for i, name := range []string{"db/1.sql", "db/2.sql", "db/3.sql", "db/4.sql", "db/5.sql"} {
+		m.Seed(name, nil, day.AddDate(0, 0, i))
+	}
+	m.Seed("other.sql", nil, day.AddDate(0, 0, 2))
+	s := listingDirs{m}
+
+	var zero time.Time
+	for _, tc := range []struct {
+		from, to time.Time
+		want     string
+	}{
+		{day.AddDate(0, 0, 1), day.AddDate(0, 0, 3), "db/4.sql db/3.sql db/2.sql"},
+		{day.AddDate(0, 0, 1).Add(time.Second), day.AddDate(0, 0, 3).Add(-time.Second), "db/3.sql"},
+		{zero, day.AddDate(0, 0, 1), "db/2.sql db/1.sql"},
+		{day.AddDate(0, 0, 3), zero, "db/5.sql db/4.sql"},
+		{zero, zero, "db/5.sql db/4.sql db/3.sql db/2.sql db/1.sql"},
+		{day.AddDate(0, 0, 10), zero, ""},
+	} {
+		fi, err := storage.ListByTime(context.Background(), s, "db/", tc.from, tc.to)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := listed(fi); got != tc.want {
+			t.Errorf("[%s, %s]: listed %q, want %q", tc.from, tc.to, got, tc.want)
+		}
+	}
+}
+
+func TestListSorted(t *testing.T) {
+	m := memory.NewStorage()
+	m.Seed("db/b.sql", make([]byte, 30), day.Add(time.Hour))
+	m.Seed("db/a.sql", make([]byte, 10), day.Add(2*time.Hour))
+	m.Seed("db/c.sql", make([]byte, 20), day)
+	// ties are ordered by name
+	m.Seed("db/d.sql", make([]byte, 20), day)
+	m.Seed("other.sql", nil, day)
+	s := listingDirs{m}
+
+	for _, tc := range []struct {
+		by   storage.SortField
+		desc bool
+		want string
+	}{
+		{storage.SortByName, false, "db/a.sql db/b.sql db/c.sql db/d.sql"},
+		{storage.SortByName, true, "db/d.sql db/c.sql db/b.sql db/a.sql"},
+		{storage.SortBySize, false, "db/a.sql db/c.sql db/d.sql db/b.sql"},
+		{storage.SortBySize, true, "db/b.sql db/d.sql db/c.sql db/a.sql"},
+		{storage.SortByModTime, false, "db/c.sql db/d.sql db/b.sql db/a.sql"},
+		{storage.SortByModTime, true, "db/a.sql db/b.sql db/d.sql db/c.sql"},
+	} {
+		fi, err := storage.ListSorted(context.Background(), s, "db/", tc.by, tc.desc)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := listed(fi); got != tc.want {
+			t.Errorf("by %d, desc %v: listed %s, want %s", tc.by, tc.desc, got, tc.want)
+		}
+	}
+}
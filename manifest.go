@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Manifest is inventory of objects with a name prefix, see WriteManifest.
+type Manifest struct {
+	Prefix  string          `json:"prefix"`
+	Created time.Time       `json:"created"`
+	Objects []ManifestEntry `json:"objects"`
+}
+
+type ManifestEntry struct {
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mtime"`
+	Checksum string    `json:"sha256,omitempty"`
+}
+
+// WriteManifest stores JSON manifest of objects with the name prefix as
+// manifestName. Checksums are included for storages keeping them, see
+// Checksummer.
+func WriteManifest(ctx context.Context, s Storage, prefix, manifestName string) error {
+	m := Manifest{
+		Prefix:  prefix,
+		Created: time.Now().UTC(),
+		Objects: make([]ManifestEntry, 0),
+	}
+
+	fi, err := listPrefix(ctx, s, prefix)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range fi {
+		// manifest may be stored under the prefix it describes
+		if f.Name() == manifestName {
+			continue
+		}
+
+		// listed objects may lack checksums, which are returned by Stat
+		sum, err := checksum(s, f.Name())
+		if err != nil {
+			return fmt.Errorf("stat %s: %w", f.Name(), err)
+		}
+
+		m.Objects = append(m.Objects, ManifestEntry{f.Name(), f.Size(), f.ModTime().UTC(), sum})
+	}
+
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return s.UploadContext(ctx, manifestName, bytes.NewReader(b))
+}
+
+// ReadManifest reads manifest stored by WriteManifest.
+func ReadManifest(ctx context.Context, s Storage, manifestName string) (Manifest, error) {
+	var m Manifest
+
+	var b bytes.Buffer
+	if err := s.DownloadContext(ctx, manifestName, &b); err != nil {
+		return m, err
+	}
+
+	if err := json.Unmarshal(b.Bytes(), &m); err != nil {
+		return m, fmt.Errorf("parse manifest %s: %w", manifestName, err)
+	}
+
+	return m, nil
+}
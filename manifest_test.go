@@ -0,0 +1,79 @@
+package storage_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sputnik-systems/backups-storage"
+	"github.com/sputnik-systems/backups-storage/memory"
+)
+
+func TestManifestRoundTrip(t *testing.T) {
+	m := memory.NewStorage()
+	m.Seed("db/a.sql", []byte("a"), day)
+	m.Seed("db/nested/b.sql", []byte("bb"), day.Add(time.Hour))
+	m.Seed("other.sql", []byte("other"), day)
+	s := checksummed{m}
+
+	// manifest under the prefix does not list itself
+	if err := storage.WriteManifest(context.Background(), s, "db/", "db/manifest.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := storage.ReadManifest(context.Background(), s, "db/manifest.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Prefix != "db/" || time.Since(got.Created) > time.Minute {
+		t.Errorf("manifest of %q created at %s", got.Prefix, got.Created)
+	}
+
+	want := []storage.ManifestEntry{
+		{"db/a.sql", 1, day, fmt.Sprintf("%x", sha256.Sum256([]byte("a")))},
+		{"db/nested/b.sql", 2, day.Add(time.Hour), fmt.Sprintf("%x", sha256.Sum256([]byte("bb")))},
+	}
+	if len(got.Objects) != len(want) {
+		t.Fatalf("manifest lists %v, want %v", got.Objects, want)
+	}
+
+	for i, e := range want {
+		o := got.Objects[i]
+		if o.Name != e.Name || o.Size != e.Size || !o.ModTime.Equal(e.ModTime) || o.Checksum != e.Checksum {
+			t.Errorf("entry %d: %+v, want %+v", i, o, e)
+		}
+	}
+
+	// storages without checksums
+	if err := storage.WriteManifest(context.Background(), m, "db/", "manifest.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err = storage.ReadManifest(context.Background(), m, "manifest.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, o := range got.Objects {
+		if o.Checksum != "" {
+			t.Errorf("%s: checksum %s", o.Name, o.Checksum)
+		}
+	}
+}
+
+func TestReadManifestErrors(t *testing.T) {
+	m := memory.NewStorage()
+	m.Seed("broken.json", []byte("{"), day)
+
+	if _, err := storage.ReadManifest(context.Background(), m, "missing.json"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("read of missing manifest: %v", err)
+	}
+
+	if _, err := storage.ReadManifest(context.Background(), m, "broken.json"); err == nil {
+		t.Error("broken manifest parsed")
+	}
+}
@@ -0,0 +1,329 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sputnik-systems/backups-storage"
+)
+
+type Memory struct {
+	mu      sync.RWMutex
+	objects map[string]object
+}
+
+type FileInfo struct {
+	name  string
+	size  int64
+	mtime time.Time
+	isdir bool
+}
+
+type object struct {
+	data  []byte
+	mtime time.Time
+}
+
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func NewStorage() *Memory {
+	return &Memory{
+		objects: make(map[string]object),
+	}
+}
+
+// Seed stores a copy of data under name as if it was uploaded at mtime.
+func (s *Memory) Seed(name string, data []byte, mtime time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.objects[path.Clean(name)] = object{append([]byte(nil), data...), mtime}
+}
+
+// Bytes returns a copy of the object stored under name.
+func (s *Memory) Bytes(name string) ([]byte, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	o, ok := s.objects[path.Clean(name)]
+	if !ok {
+		return nil, false
+	}
+
+	return append([]byte(nil), o.data...), true
+}
+
+func (s *Memory) List() ([]storage.FileInfo, error) {
+	return s.ListContext(context.Background())
+}
+
+func (s *Memory) ListContext(ctx context.Context) ([]storage.FileInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	fi := make([]storage.FileInfo, 0, len(s.objects))
+	di := make(map[string]*FileInfo)
+	for name, o := range s.objects {
+		fi = append(fi, &FileInfo{name, int64(len(o.data)), o.mtime, false})
+
+		// directories are synthesized the same way as for s3
+		dir := path.Dir(name) + "/"
+		if dir == "./" {
+			continue
+		}
+
+		if d, ok := di[dir]; !ok || d.mtime.Before(o.mtime) {
+			di[dir] = &FileInfo{dir, int64(0), o.mtime, true}
+		}
+	}
+
+	for _, d := range di {
+		fi = append(fi, d)
+	}
+
+	sort.Slice(fi, func(i, j int) bool {
+		return fi[i].Name() > fi[j].Name()
+	})
+
+	return fi, nil
+}
+
+func (s *Memory) ListFunc(prefix string, fn func(storage.FileInfo) error) error {
+	s.mu.RLock()
+	fi := make([]storage.FileInfo, 0)
+	for name, o := range s.objects {
+		if strings.HasPrefix(name, prefix) {
+			fi = append(fi, &FileInfo{name, int64(len(o.data)), o.mtime, false})
+		}
+	}
+	s.mu.RUnlock()
+
+	// keep s3 order, lock is released so fn may use storage
+	sort.Slice(fi, func(i, j int) bool {
+		return fi[i].Name() < fi[j].Name()
+	})
+
+	for _, f := range fi {
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Memory) Delete(name string) error {
+	return s.DeleteContext(context.Background(), name)
+}
+
+func (s *Memory) DeleteContext(ctx context.Context, name string) error {
+	if err := storage.ValidateName(name); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// same prefix semantic as s3 delete
+	prefix := path.Clean(name)
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			delete(s.objects, key)
+		}
+	}
+
+	return nil
+}
+
+func (s *Memory) DeleteBatch(names []string) error {
+	keys := make([]string, 0, len(names))
+	for _, name := range names {
+		k, err := key(name)
+		if err != nil {
+			return err
+		}
+
+		keys = append(keys, k)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, k := range keys {
+		delete(s.objects, k)
+	}
+
+	return nil
+}
+
+func (s *Memory) Upload(name string, buf io.Reader) error {
+	return s.UploadContext(context.Background(), name, buf)
+}
+
+func (s *Memory) UploadContext(ctx context.Context, name string, buf io.Reader) error {
+	k, err := key(name)
+	if err != nil {
+		return err
+	}
+
+	b, err := io.ReadAll(&ctxReader{ctx, buf})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.objects[k] = object{b, time.Now()}
+
+	return nil
+}
+
+func (s *Memory) Download(name string, buf io.Writer) error {
+	return s.DownloadContext(context.Background(), name, buf)
+}
+
+func (s *Memory) DownloadContext(ctx context.Context, name string, buf io.Writer) error {
+	if err := storage.ValidateName(name); err != nil {
+		return err
+	}
+
+	b, ok := s.Bytes(name)
+	if !ok {
+		return fmt.Errorf("%s: %w", name, storage.ErrNotFound)
+	}
+
+	_, err := io.Copy(buf, &ctxReader{ctx, bytes.NewReader(b)})
+
+	return err
+}
+
+func (s *Memory) Exists(name string) (bool, error) {
+	k, err := key(name)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.objects[k]
+
+	return ok, nil
+}
+
+func (s *Memory) Stat(name string) (storage.FileInfo, error) {
+	k, err := key(name)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	o, ok := s.objects[k]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", name, storage.ErrNotFound)
+	}
+
+	return &FileInfo{k, int64(len(o.data)), o.mtime, false}, nil
+}
+
+func (s *Memory) Copy(src, dst string) error {
+	srcKey, dstKey, err := keys(src, dst)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, ok := s.objects[srcKey]
+	if !ok {
+		return fmt.Errorf("%s: %w", src, storage.ErrNotFound)
+	}
+
+	// stored bytes are never modified in place, so they can be shared
+	s.objects[dstKey] = object{o.data, time.Now()}
+
+	return nil
+}
+
+func (s *Memory) Move(src, dst string) error {
+	srcKey, dstKey, err := keys(src, dst)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, ok := s.objects[srcKey]
+	if !ok {
+		return fmt.Errorf("%s: %w", src, storage.ErrNotFound)
+	}
+
+	delete(s.objects, srcKey)
+	s.objects[dstKey] = o
+
+	return nil
+}
+
+// key returns key of object of the name, names escaping the storage are
+// rejected like by other backends.
+func key(name string) (string, error) {
+	if err := storage.ValidateName(name); err != nil {
+		return "", err
+	}
+
+	return path.Clean(name), nil
+}
+
+// keys returns keys of source and destination of copy.
+func keys(src, dst string) (string, string, error) {
+	srcKey, err := key(src)
+	if err != nil {
+		return "", "", err
+	}
+
+	dstKey, err := key(dst)
+	if err != nil {
+		return "", "", err
+	}
+
+	return srcKey, dstKey, nil
+}
+
+func (r *ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return r.r.Read(p)
+}
+
+func (f *FileInfo) Name() string { return f.name }
+
+func (f *FileInfo) Size() int64 { return f.size }
+
+func (f *FileInfo) ModTime() time.Time { return f.mtime }
+
+func (f *FileInfo) IsDir() bool { return f.isdir }
@@ -0,0 +1,50 @@
+package memory
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sputnik-systems/backups-storage"
+)
+
+func TestNameTraversal(t *testing.T) {
+	s := NewStorage()
+	s.Seed("a", []byte("a"), time.Time{})
+
+	for _, name := range []string{"", "..", "../x", "a/../../x"} {
+		for op, err := range map[string]error{
+			"upload":       s.Upload(name, strings.NewReader("x")),
+			"download":     s.Download(name, &bytes.Buffer{}),
+			"delete":       s.Delete(name),
+			"delete batch": s.DeleteBatch([]string{"a", name}),
+			"exists":       errOf(s.Exists(name)),
+			"stat":         errOf(s.Stat(name)),
+			"copy from":    s.Copy(name, "b"),
+			"copy to":      s.Copy("a", name),
+			"move from":    s.Move(name, "b"),
+			"move to":      s.Move("a", name),
+		} {
+			if !errors.Is(err, storage.ErrInvalidName) {
+				t.Errorf("%s %q: got %v, want %v", op, name, err, storage.ErrInvalidName)
+			}
+		}
+	}
+
+	// nothing is changed by rejected calls
+	fi, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fi) != 1 || fi[0].Name() != "a" {
+		t.Errorf("left %d objects", len(fi))
+	}
+}
+
+// errOf returns error of call returning a value as well.
+func errOf[T any](_ T, err error) error {
+	return err
+}
@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sputnik-systems/backups-storage"
+)
+
+type Metrics struct {
+	storage.Storage
+
+	operations  *prometheus.CounterVec
+	duration    *prometheus.HistogramVec
+	transferred *prometheus.CounterVec
+}
+
+type countReader struct {
+	r io.Reader
+	n *int64
+}
+
+// countReadSeeker keeps reader seekable, so storages can detect its size.
+type countReadSeeker struct {
+	countReader
+	s io.Seeker
+}
+
+type countWriter struct {
+	w io.Writer
+	n *int64
+}
+
+// NewStorage returns storage exporting counters of operations by result,
+// their duration and transferred bytes into reg.
+func NewStorage(s storage.Storage, reg prometheus.Registerer) (storage.Storage, error) {
+	m := &Metrics{
+		Storage: s,
+		operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "backup_storage_operations_total",
+			Help: "Number of storage operations by type and result.",
+		}, []string{"operation", "result"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "backup_storage_operation_duration_seconds",
+			Help:    "Duration of storage operations.",
+			Buckets: prometheus.ExponentialBuckets(0.01, 4, 10),
+		}, []string{"operation"}),
+		transferred: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "backup_storage_transferred_bytes_total",
+			Help: "Number of bytes uploaded and downloaded.",
+		}, []string{"direction"}),
+	}
+
+	for _, c := range []prometheus.Collector{m.operations, m.duration, m.transferred} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+func (s *Metrics) List() ([]storage.FileInfo, error) {
+	return s.ListContext(context.Background())
+}
+
+func (s *Metrics) ListContext(ctx context.Context) (fi []storage.FileInfo, err error) {
+	defer s.observe("list", time.Now(), &err)
+
+	return s.Storage.ListContext(ctx)
+}
+
+func (s *Metrics) Delete(name string) error {
+	return s.DeleteContext(context.Background(), name)
+}
+
+func (s *Metrics) DeleteContext(ctx context.Context, name string) (err error) {
+	defer s.observe("delete", time.Now(), &err)
+
+	return s.Storage.DeleteContext(ctx, name)
+}
+
+func (s *Metrics) Upload(name string, buf io.Reader) error {
+	return s.UploadContext(context.Background(), name, buf)
+}
+
+func (s *Metrics) UploadContext(ctx context.Context, name string, buf io.Reader) (err error) {
+	defer s.observe("upload", time.Now(), &err)
+
+	var n int64
+	defer func() { s.transferred.WithLabelValues("upload").Add(float64(n)) }()
+
+	var r io.Reader = &countReader{buf, &n}
+	if seeker, ok := buf.(io.Seeker); ok {
+		r = &countReadSeeker{countReader{buf, &n}, seeker}
+	}
+
+	return s.Storage.UploadContext(ctx, name, r)
+}
+
+func (s *Metrics) Download(name string, buf io.Writer) error {
+	return s.DownloadContext(context.Background(), name, buf)
+}
+
+func (s *Metrics) DownloadContext(ctx context.Context, name string, buf io.Writer) (err error) {
+	defer s.observe("download", time.Now(), &err)
+
+	var n int64
+	defer func() { s.transferred.WithLabelValues("download").Add(float64(n)) }()
+
+	return s.Storage.DownloadContext(ctx, name, &countWriter{buf, &n})
+}
+
+func (s *Metrics) observe(operation string, start time.Time, err *error) {
+	result := "success"
+	if *err != nil {
+		result = "error"
+	}
+
+	s.operations.WithLabelValues(operation, result).Inc()
+	s.duration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+func (r *countReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	*r.n += int64(n)
+
+	return n, err
+}
+
+func (r *countReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	return r.s.Seek(offset, whence)
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	*w.n += int64(n)
+
+	return n, err
+}
@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/sputnik-systems/backups-storage/memory"
+)
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	st, err := NewStorage(memory.NewStorage(), reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := st.(*Metrics)
+
+	if err := s.Upload("a.sql", strings.NewReader("data")); err != nil {
+		t.Fatal(err)
+	}
+
+	// seekable readers are counted too
+	if err := s.Upload("b.sql", bytes.NewReader([]byte("longer data"))); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := s.Download("a.sql", &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Download("missing", &buf); err == nil {
+		t.Fatal("download of missing object succeeded")
+	}
+
+	if _, err := s.List(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Delete("a.sql"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		labels []string
+		want   float64
+	}{
+		{[]string{"upload", "success"}, 2},
+		{[]string{"download", "success"}, 1},
+		{[]string{"download", "error"}, 1},
+		{[]string{"list", "success"}, 1},
+		{[]string{"delete", "success"}, 1},
+		{[]string{"delete", "error"}, 0},
+	} {
+		if got := testutil.ToFloat64(s.operations.WithLabelValues(tc.labels...)); got != tc.want {
+			t.Errorf("%v operations %v, want %v", tc.labels, got, tc.want)
+		}
+	}
+
+	if got := testutil.ToFloat64(s.transferred.WithLabelValues("upload")); got != 15 {
+		t.Errorf("uploaded %v bytes, want 15", got)
+	}
+
+	if got := testutil.ToFloat64(s.transferred.WithLabelValues("download")); got != 4 {
+		t.Errorf("downloaded %v bytes, want 4", got)
+	}
+
+	if n := testutil.CollectAndCount(s.duration); n != 4 {
+		t.Errorf("%d duration histograms, want 4", n)
+	}
+
+	if _, err := testutil.GatherAndLint(reg); err != nil {
+		t.Error(err)
+	}
+
+	// second storage can not share the registry
+	if _, err := NewStorage(memory.NewStorage(), reg); err == nil {
+		t.Error("metrics registered twice")
+	}
+}
@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ValidateName returns ErrInvalidName for names which do not name anything
+// under the storage prefix: empty ones and those escaping it with ".."
+// segments, e.g. "../other/x", which path joining would resolve outside.
+func ValidateName(name string) error {
+	// leading slashes are dropped by joining with prefix
+	c := path.Clean(strings.TrimLeft(name, "/"))
+	if name == "" || c == "." || c == ".." || strings.HasPrefix(c, "../") {
+		return fmt.Errorf("%q: %w", name, ErrInvalidName)
+	}
+
+	return nil
+}
+
+// ObjectKey joins storage prefix and the name into key of object, names
+// not valid by ValidateName are rejected.
+func ObjectKey(prefix, name string) (string, error) {
+	if err := ValidateName(name); err != nil {
+		return "", err
+	}
+
+	return path.Join(prefix, name), nil
+}
@@ -0,0 +1,31 @@
+package storage_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sputnik-systems/backups-storage"
+)
+
+func TestObjectKey(t *testing.T) {
+	for _, tc := range []struct {
+		prefix, name, key string
+	}{
+		{"backups", "db.sql", "backups/db.sql"},
+		{"backups", "db/../db.sql", "backups/db.sql"},
+		{"backups", "/db.sql", "backups/db.sql"},
+		{"backups", "a/./b//c.sql", "backups/a/b/c.sql"},
+		{"backups", "..db.sql", "backups/..db.sql"},
+		{"", "db.sql", "db.sql"},
+	} {
+		if key, err := storage.ObjectKey(tc.prefix, tc.name); err != nil || key != tc.key {
+			t.Errorf("%s in %s: key %q, %v, want %q", tc.name, tc.prefix, key, err, tc.key)
+		}
+	}
+
+	for _, name := range []string{"", ".", "/", "..", "../other/x", "a/../../x", "/../x", "a/b/../../.."} {
+		if key, err := storage.ObjectKey("backups", name); !errors.Is(err, storage.ErrInvalidName) {
+			t.Errorf("%q: key %q, %v, want %v", name, key, err, storage.ErrInvalidName)
+		}
+	}
+}
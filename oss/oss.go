@@ -0,0 +1,442 @@
+package oss
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+
+	"github.com/sputnik-systems/backups-storage"
+)
+
+const (
+	maxDeleteKeys = 1000
+	// larger objects can not be copied by a single request
+	maxCopySize = int64(1024 * 1024 * 1024)
+)
+
+type OSS struct {
+	b        *oss.Bucket
+	prefix   string
+	partSize int64
+}
+
+type FileInfo struct {
+	name     string
+	size     int64
+	mtime    time.Time
+	isdir    bool
+	metadata map[string]string
+}
+
+func NewStorage(client *oss.Client, bucket, prefix string) (storage.Storage, error) {
+	partSize := int64(100 * 1024 * 1024)
+
+	b, err := client.Bucket(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OSS{
+		b:        b,
+		prefix:   prefix,
+		partSize: partSize,
+	}, nil
+}
+
+// Endpoint returns public endpoint of region like cn-hangzhou, internal one
+// is free of traffic charges inside the region.
+func Endpoint(region string, internal bool) string {
+	if internal {
+		return fmt.Sprintf("https://oss-%s-internal.aliyuncs.com", region)
+	}
+
+	return fmt.Sprintf("https://oss-%s.aliyuncs.com", region)
+}
+
+func (s *OSS) List() ([]storage.FileInfo, error) {
+	return s.ListContext(context.Background())
+}
+
+func (s *OSS) ListContext(ctx context.Context) ([]storage.FileInfo, error) {
+	return s.list(ctx, s.key(""))
+}
+
+func (s *OSS) ListFunc(prefix string, fn func(storage.FileInfo) error) error {
+	return s.listFunc(context.Background(), s.key(prefix), fn)
+}
+
+func (s *OSS) listFunc(ctx context.Context, prefix string, fn func(storage.FileInfo) error) error {
+	token := ""
+	for {
+		page, err := s.b.ListObjectsV2(oss.Prefix(prefix), oss.ContinuationToken(token), oss.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+
+		for _, o := range page.Objects {
+			if err := fn(&FileInfo{s.relName(o.Key), o.Size, o.LastModified, false, nil}); err != nil {
+				return err
+			}
+		}
+
+		if !page.IsTruncated {
+			return nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+func (s *OSS) list(ctx context.Context, prefix string) ([]storage.FileInfo, error) {
+	fi := make([]storage.FileInfo, 0)
+	di := make(map[string]*FileInfo)
+	err := s.listFunc(ctx, prefix, func(f storage.FileInfo) error {
+		fi = append(fi, f)
+
+		// calc directories
+		dir := path.Dir(f.Name()) + "/"
+		if dir == "./" {
+			return nil
+		}
+
+		if d, ok := di[dir]; !ok || d.mtime.Before(f.ModTime()) {
+			di[dir] = &FileInfo{dir, int64(0), f.ModTime(), true, nil}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fi, err
+	}
+
+	for _, d := range di {
+		fi = append(fi, d)
+	}
+
+	sort.Slice(fi, func(i, j int) bool {
+		return fi[i].Name() > fi[j].Name()
+	})
+
+	return fi, nil
+}
+
+func (s *OSS) Delete(name string) error {
+	return s.DeleteContext(context.Background(), name)
+}
+
+func (s *OSS) DeleteContext(ctx context.Context, name string) error {
+	if err := storage.ValidateName(name); err != nil {
+		return err
+	}
+
+	prefix := s.key(strings.TrimSuffix(name, "/"))
+	fi, err := s.list(ctx, prefix)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(fi))
+	for _, o := range fi {
+		if !o.IsDir() {
+			keys = append(keys, s.key(o.Name()))
+		}
+	}
+
+	return s.deleteKeys(ctx, keys)
+}
+
+func (s *OSS) DeleteBatch(names []string) error {
+	keys := make([]string, 0, len(names))
+	for _, name := range names {
+		key, err := storage.ObjectKey(s.prefix, name)
+		if err != nil {
+			return err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return s.deleteKeys(context.Background(), keys)
+}
+
+func (s *OSS) deleteKeys(ctx context.Context, keys []string) error {
+	for len(keys) > 0 {
+		n := min(len(keys), maxDeleteKeys)
+
+		// quiet mode does not list deleted keys in response
+		if _, err := s.b.DeleteObjects(keys[:n], oss.DeleteObjectsQuiet(true), oss.WithContext(ctx)); err != nil {
+			return err
+		}
+
+		keys = keys[n:]
+	}
+
+	return nil
+}
+
+func (s *OSS) Upload(name string, buf io.Reader) error {
+	return s.UploadContext(context.Background(), name, buf)
+}
+
+func (s *OSS) UploadContext(ctx context.Context, name string, buf io.Reader) (err error) {
+	key, err := storage.ObjectKey(s.prefix, name)
+	if err != nil {
+		return err
+	}
+
+	var imur *oss.InitiateMultipartUploadResult
+	var parts []oss.UploadPart
+
+	// do not leave orphaned parts of a failed upload in bucket
+	defer func() {
+		if err != nil && imur != nil {
+			if aerr := s.b.AbortMultipartUpload(*imur); aerr != nil {
+				err = fmt.Errorf("%w (abort multipart upload: %v)", err, aerr)
+			}
+		}
+	}()
+
+	b := make([]byte, s.partSize)
+	for partNumber := 1; ; partNumber++ {
+		n, rerr := io.ReadFull(buf, b)
+		if rerr != nil && rerr != io.EOF && rerr != io.ErrUnexpectedEOF {
+			return rerr
+		}
+		last := rerr != nil
+
+		// objects up to part size are put at once
+		if imur == nil && last {
+			return s.b.PutObject(key, bytes.NewReader(b[:n]), oss.WithContext(ctx))
+		}
+
+		if imur == nil {
+			out, err := s.b.InitiateMultipartUpload(key, oss.WithContext(ctx))
+			if err != nil {
+				return err
+			}
+			imur = &out
+		}
+
+		// stream size may be a multiple of part size, so the last read can be empty
+		if n == 0 {
+			break
+		}
+
+		part, err := s.b.UploadPart(*imur, bytes.NewReader(b[:n]), int64(n), partNumber, oss.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		parts = append(parts, part)
+
+		if last {
+			break
+		}
+	}
+
+	if _, err = s.b.CompleteMultipartUpload(*imur, parts, oss.WithContext(ctx)); err != nil {
+		return err
+	}
+	imur = nil
+
+	return nil
+}
+
+func (s *OSS) Download(name string, buf io.Writer) error {
+	return s.DownloadContext(context.Background(), name, buf)
+}
+
+func (s *OSS) DownloadContext(ctx context.Context, name string, buf io.Writer) error {
+	key, err := storage.ObjectKey(s.prefix, name)
+	if err != nil {
+		return err
+	}
+
+	return s.download(ctx, key, buf, oss.WithContext(ctx))
+}
+
+// DownloadRange downloads length bytes of object from offset, non-positive
+// length means up to the end of object.
+func (s *OSS) DownloadRange(name string, offset, length int64, buf io.Writer) error {
+	if offset < 0 {
+		return fmt.Errorf("invalid range offset %d", offset)
+	}
+
+	key, err := storage.ObjectKey(s.prefix, name)
+	if err != nil {
+		return err
+	}
+
+	r := fmt.Sprintf("%d-", offset)
+	if length > 0 {
+		r = fmt.Sprintf("%d-%d", offset, offset+length-1)
+	}
+
+	ctx := context.Background()
+
+	return s.download(ctx, key, buf, oss.NormalizedRange(r), oss.WithContext(ctx))
+}
+
+func (s *OSS) download(ctx context.Context, key string, buf io.Writer, opts ...oss.Option) error {
+	body, err := s.b.GetObject(key, opts...)
+	if err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("%s: %w", key, storage.ErrNotFound)
+		}
+
+		return err
+	}
+	defer body.Close()
+
+	_, err = io.Copy(buf, body)
+
+	return err
+}
+
+func (s *OSS) Exists(name string) (bool, error) {
+	if _, err := s.Stat(name); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *OSS) Stat(name string) (storage.FileInfo, error) {
+	key, err := storage.ObjectKey(s.prefix, name)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := s.b.GetObjectDetailedMeta(key)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, fmt.Errorf("%s: %w", key, storage.ErrNotFound)
+		}
+
+		return nil, err
+	}
+
+	size, _ := strconv.ParseInt(h.Get(oss.HTTPHeaderContentLength), 10, 64)
+	mtime, _ := http.ParseTime(h.Get(oss.HTTPHeaderLastModified))
+
+	metadata := make(map[string]string)
+	for k := range h {
+		if strings.HasPrefix(k, oss.HTTPHeaderOssMetaPrefix) {
+			metadata[strings.ToLower(strings.TrimPrefix(k, oss.HTTPHeaderOssMetaPrefix))] = h.Get(k)
+		}
+	}
+
+	return &FileInfo{s.relName(key), size, mtime, false, metadata}, nil
+}
+
+func (s *OSS) Copy(src, dst string) error {
+	srcKey, err := storage.ObjectKey(s.prefix, src)
+	if err != nil {
+		return err
+	}
+
+	dstKey, err := storage.ObjectKey(s.prefix, dst)
+	if err != nil {
+		return err
+	}
+
+	f, err := s.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	if f.Size() <= maxCopySize {
+		_, err := s.b.CopyObject(srcKey, dstKey)
+
+		return err
+	}
+
+	return s.copyMultipart(srcKey, dstKey, f.Size())
+}
+
+func (s *OSS) copyMultipart(srcKey, dstKey string, size int64) (err error) {
+	imur, err := s.b.InitiateMultipartUpload(dstKey)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			if aerr := s.b.AbortMultipartUpload(imur); aerr != nil {
+				err = fmt.Errorf("%w (abort multipart upload: %v)", err, aerr)
+			}
+		}
+	}()
+
+	parts := make([]oss.UploadPart, 0)
+	for offset := int64(0); offset < size; offset += s.partSize {
+		n := min(s.partSize, size-offset)
+
+		part, err := s.b.UploadPartCopy(imur, s.b.BucketName, srcKey, offset, n, len(parts)+1)
+		if err != nil {
+			return err
+		}
+		parts = append(parts, part)
+	}
+
+	_, err = s.b.CompleteMultipartUpload(imur, parts)
+
+	return err
+}
+
+func (s *OSS) Move(src, dst string) error {
+	if err := s.Copy(src, dst); err != nil {
+		return fmt.Errorf("move %s: copy: %w", src, err)
+	}
+
+	if err := s.b.DeleteObject(path.Join(s.prefix, src)); err != nil {
+		return fmt.Errorf("move %s: delete source: %w", src, err)
+	}
+
+	return nil
+}
+
+// key joins name with the prefix keeping trailing slash, so it can be used
+// as list prefix.
+func (s *OSS) key(name string) string {
+	if s.prefix == "" {
+		return name
+	}
+
+	return strings.TrimSuffix(s.prefix, "/") + "/" + name
+}
+
+// relName strips the prefix from object key.
+func (s *OSS) relName(key string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(key, strings.TrimSuffix(s.prefix, "/")), "/")
+}
+
+func isNotFound(err error) bool {
+	var serr oss.ServiceError
+
+	return errors.As(err, &serr) && serr.StatusCode == http.StatusNotFound
+}
+
+func (f *FileInfo) Name() string { return f.name }
+
+func (f *FileInfo) Size() int64 { return f.size }
+
+func (f *FileInfo) ModTime() time.Time { return f.mtime }
+
+func (f *FileInfo) IsDir() bool { return f.isdir }
+
+// Metadata returns user metadata (x-oss-meta-*) with lower cased keys, it
+// is available only for objects returned by Stat.
+func (f *FileInfo) Metadata() map[string]string { return f.metadata }
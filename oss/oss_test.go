@@ -0,0 +1,128 @@
+package oss
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+
+	"github.com/sputnik-systems/backups-storage"
+)
+
+func TestEndpoint(t *testing.T) {
+	if got := Endpoint("cn-hangzhou", false); got != "https://oss-cn-hangzhou.aliyuncs.com" {
+		t.Errorf("public endpoint %s", got)
+	}
+
+	if got := Endpoint("ap-southeast-1", true); got != "https://oss-ap-southeast-1-internal.aliyuncs.com" {
+		t.Errorf("internal endpoint %s", got)
+	}
+}
+
+func TestNewStorage(t *testing.T) {
+	c, err := oss.New(Endpoint("cn-hangzhou", false), "id", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := NewStorage(c, "backups-bucket", "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := st.(*OSS)
+	if s.b.BucketName != "backups-bucket" || s.b.Client.Config.Endpoint != "https://oss-cn-hangzhou.aliyuncs.com" {
+		t.Errorf("bucket %s at %s", s.b.BucketName, s.b.Client.Config.Endpoint)
+	}
+
+	if _, err := NewStorage(c, "Invalid_Bucket", "db"); err == nil {
+		t.Error("storage of invalid bucket name")
+	}
+}
+
+func TestKey(t *testing.T) {
+	for _, tc := range []struct {
+		prefix, name, key string
+	}{
+		{"", "db.sql", "db.sql"},
+		{"", "", ""},
+		{"backups", "db.sql", "backups/db.sql"},
+		{"backups/", "db.sql", "backups/db.sql"},
+		{"backups", "", "backups/"},
+		{"backups", "db/", "backups/db/"},
+		{"a/b", "c/d.sql", "a/b/c/d.sql"},
+	} {
+		s := &OSS{prefix: tc.prefix}
+		if got := s.key(tc.name); got != tc.key {
+			t.Errorf("prefix %q: key of %q is %q, want %q", tc.prefix, tc.name, got, tc.key)
+		}
+
+		if got := s.relName(tc.key); got != tc.name {
+			t.Errorf("prefix %q: name of %q is %q, want %q", tc.prefix, tc.key, got, tc.name)
+		}
+	}
+}
+
+func TestStat(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+
+		if r.URL.Path != "/bucket/backups/db.sql" {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, "<Error><Code>NoSuchKey</Code><Message>missing</Message></Error>")
+
+			return
+		}
+
+		w.Header().Set("Content-Length", "4")
+		w.Header().Set("Last-Modified", "Sun, 10 Oct 2021 03:00:00 GMT")
+		w.Header().Set("X-Oss-Meta-Sha256", "sum")
+	}))
+	defer srv.Close()
+
+	// ip endpoints are addressed path style
+	c, err := oss.New(srv.URL, "id", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := NewStorage(c, "bucket", "backups")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := st.Stat("db.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mtime := time.Date(2021, 10, 10, 3, 0, 0, 0, time.UTC)
+	if fi.Name() != "db.sql" || fi.Size() != 4 || !fi.ModTime().Equal(mtime) || fi.(*FileInfo).Metadata()["sha256"] != "sum" {
+		t.Errorf("stat %s of %d bytes at %s, metadata %v", fi.Name(), fi.Size(), fi.ModTime(), fi.(*FileInfo).Metadata())
+	}
+
+	if _, err := st.Stat("missing"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("stat of missing object: %v", err)
+	}
+
+	if ok, err := st.Exists("missing"); err != nil || ok {
+		t.Errorf("missing object exists %v, %v", ok, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(paths) != 3 || paths[1] != "/bucket/backups/missing" {
+		t.Errorf("requested %v", paths)
+	}
+}
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+type pruneOptions struct {
+	dryRun bool
+}
+
+type PruneOption func(*pruneOptions)
+
+// WithDryRun makes prune helpers return names of objects they would delete
+// without deleting them.
+func WithDryRun() PruneOption {
+	return func(o *pruneOptions) {
+		o.dryRun = true
+	}
+}
+
+// PruneKeep deletes all objects with the name prefix except keep newest
+// ones and returns names of deleted objects. Keep must be positive, so a
+// mistake in configuration never wipes all backups.
+func PruneKeep(ctx context.Context, s Storage, prefix string, keep int, opts ...PruneOption) ([]string, error) {
+	if keep <= 0 {
+		return nil, fmt.Errorf("invalid number of backups to keep %d", keep)
+	}
+
+	fi, err := listPrefix(ctx, s, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fi) <= keep {
+		return nil, nil
+	}
+
+	sort.Slice(fi, func(i, j int) bool {
+		return fi[i].ModTime().After(fi[j].ModTime())
+	})
+
+	return deleteObjects(s, fi[keep:], opts)
+}
+
+// PruneOlderThan deletes all objects with the name prefix modified more
+// than age ago and returns names of deleted objects.
+func PruneOlderThan(ctx context.Context, s Storage, prefix string, age time.Duration, opts ...PruneOption) ([]string, error) {
+	if age <= 0 {
+		return nil, fmt.Errorf("invalid age %s", age)
+	}
+
+	fi, err := listPrefix(ctx, s, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(-age)
+	expired := make([]FileInfo, 0)
+	for _, f := range fi {
+		if f.ModTime().Before(deadline) {
+			expired = append(expired, f)
+		}
+	}
+
+	if len(expired) == 0 {
+		return nil, nil
+	}
+
+	return deleteObjects(s, expired, opts)
+}
+
+// GFSPolicy is grandfather-father-son retention policy, it keeps the newest
+// object of each of Daily last days, Weekly last weeks and Monthly last
+// months having objects. Periods are calculated in UTC, weeks are ISO ones.
+type GFSPolicy struct {
+	Daily   int
+	Weekly  int
+	Monthly int
+}
+
+// GFSPrune deletes all objects with the name prefix not retained by the
+// policy and returns names of deleted objects.
+func GFSPrune(ctx context.Context, s Storage, prefix string, policy GFSPolicy, opts ...PruneOption) ([]string, error) {
+	if policy.Daily < 0 || policy.Weekly < 0 || policy.Monthly < 0 {
+		return nil, fmt.Errorf("invalid retention policy %+v", policy)
+	}
+
+	if policy.Daily+policy.Weekly+policy.Monthly == 0 {
+		return nil, fmt.Errorf("retention policy keeps nothing")
+	}
+
+	fi, err := listPrefix(ctx, s, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(fi, func(i, j int) bool {
+		return fi[i].ModTime().After(fi[j].ModTime())
+	})
+
+	keep := make(map[string]struct{})
+	retain := func(count int, period func(time.Time) string) {
+		periods := make(map[string]struct{})
+		for _, f := range fi {
+			if len(periods) == count {
+				return
+			}
+
+			// objects are sorted, so the first one is the newest of period
+			p := period(f.ModTime().UTC())
+			if _, ok := periods[p]; !ok {
+				periods[p] = struct{}{}
+				keep[f.Name()] = struct{}{}
+			}
+		}
+	}
+
+	retain(policy.Daily, func(t time.Time) string {
+		return t.Format("2006-01-02")
+	})
+	retain(policy.Weekly, func(t time.Time) string {
+		// iso week year differs from calendar one around new year
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	})
+	retain(policy.Monthly, func(t time.Time) string {
+		return t.Format("2006-01")
+	})
+
+	expired := make([]FileInfo, 0)
+	for _, f := range fi {
+		if _, ok := keep[f.Name()]; !ok {
+			expired = append(expired, f)
+		}
+	}
+
+	if len(expired) == 0 {
+		return nil, nil
+	}
+
+	return deleteObjects(s, expired, opts)
+}
+
+// listPrefix returns objects with the name prefix, without directories.
+func listPrefix(ctx context.Context, s Storage, prefix string) ([]FileInfo, error) {
+	fi := make([]FileInfo, 0)
+	err := s.ListFunc(prefix, func(f FileInfo) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if !f.IsDir() {
+			fi = append(fi, f)
+		}
+
+		return nil
+	})
+
+	return fi, err
+}
+
+func deleteObjects(s Storage, fi []FileInfo, opts []PruneOption) ([]string, error) {
+	var o pruneOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	names := make([]string, 0, len(fi))
+	for _, f := range fi {
+		names = append(names, f.Name())
+	}
+
+	if o.dryRun {
+		return names, nil
+	}
+
+	if err := s.DeleteBatch(names); err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
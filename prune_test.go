@@ -0,0 +1,177 @@
+package storage_test
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sputnik-systems/backups-storage"
+	"github.com/sputnik-systems/backups-storage/memory"
+)
+
+var day = time.Date(2021, 10, 10, 3, 0, 0, 0, time.UTC)
+
+// left returns sorted names of objects left in s.
+func left(t *testing.T, s *memory.Memory) string {
+	t.Helper()
+
+	got := contents(t, s)
+	n := make([]string, 0, len(got))
+	for name := range got {
+		n = append(n, name)
+	}
+	sort.Strings(n)
+
+	return strings.Join(n, " ")
+}
+
+func TestPruneKeep(t *testing.T) {
+	s := memory.NewStorage()
+	for i := 0; i < 10; i++ {
+		s.Seed(fmt.Sprintf("db/%d.sql", i), nil, day.Add(time.Duration(i)*time.Hour))
+	}
+	s.Seed("other/0.sql", nil, day.Add(-time.Hour))
+
+	deleted, err := storage.PruneKeep(context.Background(), s, "db/", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(deleted)
+	if got := strings.Join(deleted, " "); got != "db/0.sql db/1.sql db/2.sql" {
+		t.Errorf("deleted %s, want the oldest 3", got)
+	}
+
+	if got := left(t, s); got != "db/3.sql db/4.sql db/5.sql db/6.sql db/7.sql db/8.sql db/9.sql other/0.sql" {
+		t.Errorf("left %s", got)
+	}
+
+	// nothing to delete
+	if deleted, err := storage.PruneKeep(context.Background(), s, "db/", 7); err != nil || len(deleted) != 0 {
+		t.Errorf("deleted %v, %v", deleted, err)
+	}
+
+	for _, keep := range []int{0, -1} {
+		if _, err := storage.PruneKeep(context.Background(), s, "db/", keep); err == nil {
+			t.Errorf("keep %d accepted", keep)
+		}
+	}
+}
+
+func TestPruneOlderThan(t *testing.T) {
+	now := time.Now()
+
+	s := memory.NewStorage()
+	for _, age := range []int{1, 30, 89, 91, 120, 365} {
+		s.Seed(fmt.Sprintf("db/%03d.sql", age), nil, now.Add(-time.Duration(age)*24*time.Hour))
+	}
+	s.Seed("other/365.sql", nil, now.Add(-365*24*time.Hour))
+
+	deleted, err := storage.PruneOlderThan(context.Background(), s, "db/", 90*24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(deleted)
+	if got := strings.Join(deleted, " "); got != "db/091.sql db/120.sql db/365.sql" {
+		t.Errorf("deleted %s", got)
+	}
+
+	if got := left(t, s); got != "db/001.sql db/030.sql db/089.sql other/365.sql" {
+		t.Errorf("left %s", got)
+	}
+
+	if _, err := storage.PruneOlderThan(context.Background(), s, "db/", 0); err == nil {
+		t.Error("age 0 accepted")
+	}
+}
+
+// seedDaily stores a backup at 03:00 UTC of every day from first to last.
+func seedDaily(s *memory.Memory, first, last string) {
+	start, _ := time.Parse("2006-01-02", first)
+	end, _ := time.Parse("2006-01-02", last)
+
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		s.Seed(d.Format("db/2006-01-02.sql"), nil, d.Add(3*time.Hour))
+	}
+}
+
+func TestGFSPrune(t *testing.T) {
+	s := memory.NewStorage()
+	seedDaily(s, "2021-08-01", "2021-10-10")
+
+	deleted, err := storage.GFSPrune(context.Background(), s, "db/", storage.GFSPolicy{Daily: 7, Weekly: 4, Monthly: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2021-10-10 is sunday, so the weeks end at 10-10, 10-03, 09-26 and
+	// 09-19, the months at 10-10, 09-30 and 08-31
+	want := "db/2021-08-31.sql db/2021-09-19.sql db/2021-09-26.sql db/2021-09-30.sql " +
+		"db/2021-10-03.sql db/2021-10-04.sql db/2021-10-05.sql db/2021-10-06.sql " +
+		"db/2021-10-07.sql db/2021-10-08.sql db/2021-10-09.sql db/2021-10-10.sql"
+	if got := left(t, s); got != want {
+		t.Errorf("left %s, want %s", got, want)
+	}
+
+	if len(deleted) != 71-12 {
+		t.Errorf("deleted %d objects, want %d", len(deleted), 71-12)
+	}
+}
+
+func TestGFSPruneYearBoundary(t *testing.T) {
+	s := memory.NewStorage()
+	seedDaily(s, "2020-12-20", "2021-01-10")
+
+	// iso week 53 of 2020 lasts until 2021-01-03
+	if _, err := storage.GFSPrune(context.Background(), s, "db/", storage.GFSPolicy{Weekly: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := left(t, s); got != "db/2020-12-27.sql db/2021-01-03.sql db/2021-01-10.sql" {
+		t.Errorf("left %s", got)
+	}
+}
+
+func TestGFSPruneInvalidPolicy(t *testing.T) {
+	for _, policy := range []storage.GFSPolicy{{}, {Daily: -1, Weekly: 2}} {
+		if _, err := storage.GFSPrune(context.Background(), memory.NewStorage(), "", policy); err == nil {
+			t.Errorf("policy %+v accepted", policy)
+		}
+	}
+}
+
+func TestPruneDryRun(t *testing.T) {
+	s := memory.NewStorage()
+	for i := 0; i < 5; i++ {
+		s.Seed(fmt.Sprintf("db/%d.sql", i), nil, day.Add(time.Duration(i)*time.Hour))
+	}
+
+	would, err := storage.PruneKeep(context.Background(), s, "db/", 2, storage.WithDryRun())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(would)
+	if got := strings.Join(would, " "); got != "db/0.sql db/1.sql db/2.sql" {
+		t.Errorf("would delete %s", got)
+	}
+
+	if got := left(t, s); got != "db/0.sql db/1.sql db/2.sql db/3.sql db/4.sql" {
+		t.Errorf("left %s", got)
+	}
+
+	// the same objects are deleted for real
+	deleted, err := storage.PruneKeep(context.Background(), s, "db/", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(deleted)
+	if strings.Join(deleted, " ") != strings.Join(would, " ") {
+		t.Errorf("deleted %v, dry run reported %v", deleted, would)
+	}
+}
@@ -0,0 +1,177 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/sputnik-systems/backups-storage"
+)
+
+// Append appends data of r to the object, creating it when absent. S3 has
+// no native append, so object is rebuilt as multipart upload: existing data
+// is copied server side as the first parts, which is possible only for
+// objects of at least 5 MiB, smaller ones are downloaded and uploaded again
+// along with r. Appended object has no checksum in metadata.
+func (s *S3) Append(name string, r io.Reader) (err error) {
+	ctx := context.Background()
+	key, err := storage.ObjectKey(s.prefix, name)
+	if err != nil {
+		return err
+	}
+
+	o, err := s.c.HeadObjectWithContext(ctx, s.headObjectInput(key))
+	if err != nil {
+		if isNotFound(err) {
+			return s.UploadContext(ctx, name, r)
+		}
+
+		return s.sseCustomerKeyError(key, err)
+	}
+
+	size := aws.Int64Value(o.ContentLength)
+	if size < minPartSize {
+		body, err := s.Open(name)
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+
+		// object is replaced on purpose, so WithNoOverwrite does not apply
+		u := *s
+		u.noOverwrite = false
+
+		return u.UploadContext(ctx, name, io.MultiReader(body, r))
+	}
+
+	rsize, err := readerSize(r)
+	if err != nil {
+		return err
+	}
+
+	partSize, err := s.partSizeFor(size + max(rsize, 0))
+	if err != nil {
+		return err
+	}
+
+	ranges := copyRanges(size, partSize)
+
+	// part limit is checked before anything is copied, stream of unknown
+	// size takes at least one part
+	parts := int64(len(ranges)) + 1
+	if rsize > 0 {
+		parts = int64(len(ranges)) + (rsize+partSize-1)/partSize
+	}
+
+	if parts > maxParts {
+		return fmt.Errorf("append to %s exceeds %d parts of %d bytes, increase part size", key, maxParts, partSize)
+	}
+
+	// nothing to append, so object is left intact
+	b := make([]byte, partSize)
+	n, rerr := io.ReadFull(r, b)
+	if rerr != nil && rerr != io.EOF && rerr != io.ErrUnexpectedEOF {
+		return rerr
+	}
+
+	if n == 0 {
+		return nil
+	}
+
+	in := s.createMultipartUploadInput(key)
+	in.ContentType = o.ContentType
+
+	mupload, err := s.c.CreateMultipartUploadWithContext(ctx, in)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			if aerr := s.abortUpload(context.Background(), key, mupload.UploadId); aerr != nil {
+				err = fmt.Errorf("%w (abort multipart upload: %v)", err, aerr)
+			}
+		}
+	}()
+
+	mparts := make([]*s3.CompletedPart, 0)
+	source := copySource(s.bucket, key)
+
+	for _, rg := range ranges {
+		partNumber := int64(len(mparts) + 1)
+		pi := s.uploadPartCopyInput(key, mupload.UploadId, partNumber, source)
+		pi.CopySourceRange = aws.String(fmt.Sprintf("bytes=%d-%d", rg[0], rg[1]-1))
+
+		res, err := s.c.UploadPartCopyWithContext(ctx, pi)
+		if err != nil {
+			return err
+		}
+
+		mparts = append(mparts, &s3.CompletedPart{
+			ETag:       res.CopyPartResult.ETag,
+			PartNumber: aws.Int64(partNumber),
+		})
+	}
+
+	for n > 0 {
+		partNumber := int64(len(mparts) + 1)
+		if partNumber > maxParts {
+			return fmt.Errorf("append to %s exceeds %d parts of %d bytes, increase part size", key, maxParts, partSize)
+		}
+
+		part, _, err := s.uploadPart(ctx, key, mupload.UploadId, partNumber, b[:n])
+		if err != nil {
+			return err
+		}
+		mparts = append(mparts, part)
+
+		if rerr != nil {
+			break
+		}
+
+		b = make([]byte, partSize)
+		n, rerr = io.ReadFull(r, b)
+		if rerr != nil && rerr != io.EOF && rerr != io.ErrUnexpectedEOF {
+			return rerr
+		}
+	}
+
+	ci := &s3.CompleteMultipartUploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(key),
+		UploadId: mupload.UploadId,
+		MultipartUpload: &s3.CompletedMultipartUpload{
+			Parts: mparts,
+		},
+	}
+
+	if _, err = s.c.CompleteMultipartUploadWithContext(ctx, ci); err != nil {
+		return s.writeError(key, err)
+	}
+
+	return nil
+}
+
+// copyRanges splits size bytes into [start, end) ranges of part size to be
+// copied as parts. Copied parts are not the last ones, so short tail is
+// merged into the previous range to keep every part above the minimum, or
+// shares it evenly when the merged one would exceed the maximum part size.
+func copyRanges(size, partSize int64) [][2]int64 {
+	ranges := make([][2]int64, 0)
+	for offset := int64(0); offset < size; {
+		end := min(offset+partSize, size)
+		if tail := size - end; tail > 0 && tail < minPartSize {
+			end = size
+			if size-offset > maxPartSize {
+				end = offset + (size-offset)/2
+			}
+		}
+
+		ranges = append(ranges, [2]int64{offset, end})
+		offset = end
+	}
+
+	return ranges
+}
@@ -0,0 +1,42 @@
+package s3
+
+import "testing"
+
+func TestCopyRanges(t *testing.T) {
+	for _, tc := range []struct {
+		size, partSize int64
+		want           [][2]int64
+	}{
+		{minPartSize, minPartSize, [][2]int64{{0, minPartSize}}},
+		{3 * minPartSize, minPartSize, [][2]int64{{0, minPartSize}, {minPartSize, 2 * minPartSize}, {2 * minPartSize, 3 * minPartSize}}},
+		// short tail is merged into the previous range
+		{2*minPartSize + 1, minPartSize, [][2]int64{{0, minPartSize}, {minPartSize, 2*minPartSize + 1}}},
+		{minPartSize + 1, minPartSize, [][2]int64{{0, minPartSize + 1}}},
+		// unless it exceeds the maximum part size
+		{maxPartSize + 1, maxPartSize, [][2]int64{{0, maxPartSize / 2}, {maxPartSize / 2, maxPartSize + 1}}},
+		{2*maxPartSize + minPartSize - 1, maxPartSize, [][2]int64{
+			{0, maxPartSize},
+			{maxPartSize, maxPartSize + (maxPartSize+minPartSize-1)/2},
+			{maxPartSize + (maxPartSize+minPartSize-1)/2, 2*maxPartSize + minPartSize - 1},
+		}},
+		// tail of the minimum size is a range of its own
+		{maxPartSize + minPartSize, maxPartSize, [][2]int64{{0, maxPartSize}, {maxPartSize, maxPartSize + minPartSize}}},
+	} {
+		got := copyRanges(tc.size, tc.partSize)
+		if len(got) != len(tc.want) {
+			t.Errorf("size %d: ranges %v, want %v", tc.size, got, tc.want)
+
+			continue
+		}
+
+		for i, rg := range got {
+			if rg != tc.want[i] {
+				t.Errorf("size %d: range %d is %v, want %v", tc.size, i, rg, tc.want[i])
+			}
+
+			if n := rg[1] - rg[0]; n < minPartSize || n > maxPartSize {
+				t.Errorf("size %d: range %d of %d bytes", tc.size, i, n)
+			}
+		}
+	}
+}
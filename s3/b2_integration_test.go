@@ -0,0 +1,94 @@
+//go:build integration
+
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sputnik-systems/backups-storage"
+)
+
+// Run against a B2 bucket with application key allowed to write it, e.g.
+// B2_REGION=us-west-004 B2_KEY_ID=... B2_KEY=... B2_BUCKET=...
+// go test -tags integration -run B2 ./s3.
+
+func newB2Storage(t *testing.T, opts ...Option) *S3 {
+	region, bucket := os.Getenv("B2_REGION"), os.Getenv("B2_BUCKET")
+	if region == "" || bucket == "" {
+		t.Skip("B2_REGION and B2_BUCKET are not set")
+	}
+
+	prefix := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	s, err := NewB2Storage(region, os.Getenv("B2_KEY_ID"), os.Getenv("B2_KEY"), bucket, prefix, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		storage.Clear(context.Background(), s, "", true)
+	})
+
+	return s.(*S3)
+}
+
+func TestB2RoundTrip(t *testing.T) {
+	// options b2 rejects are dropped by the preset
+	s := newB2Storage(t, WithPartSize(minPartSize), WithStorageClass("STANDARD_IA"), WithTags(map[string]string{"env": "test"}))
+
+	for name, data := range map[string][]byte{
+		"small.sql": []byte("data"),
+		"large.sql": bytes.Repeat([]byte("0123456789"), int(2*minPartSize/10+1)),
+	} {
+		if err := s.Upload(name, bytes.NewReader(data)); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		var buf bytes.Buffer
+		if err := s.Download(name, &buf); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if !bytes.Equal(buf.Bytes(), data) {
+			t.Errorf("%s: downloaded %d bytes, want %d", name, buf.Len(), len(data))
+		}
+	}
+
+	if err := s.Copy("large.sql", "copy.sql"); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := s.Stat("copy.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fi.Size() != 2*minPartSize+10 {
+		t.Errorf("copy of %d bytes, want %d", fi.Size(), 2*minPartSize+10)
+	}
+
+	if err := s.Delete("copy.sql"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Stat("copy.sql"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("stat of deleted object: %v", err)
+	}
+}
+
+func TestB2ServerSideEncryption(t *testing.T) {
+	s := newB2Storage(t, WithSSES3())
+
+	if err := s.Upload("encrypted.sql", bytes.NewReader([]byte("data"))); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := s.Download("encrypted.sql", &buf); err != nil || buf.String() != "data" {
+		t.Errorf("downloaded %q, %v", buf.String(), err)
+	}
+}
@@ -0,0 +1,61 @@
+package s3
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// getBuffer returns buffer of size bytes, reusing one released by
+// putBuffer when it is large enough.
+func (s *S3) getBuffer(size int64) []byte {
+	if b, ok := s.buffers.Get().(*[]byte); ok && int64(cap(*b)) >= size {
+		return (*b)[:size]
+	}
+
+	return make([]byte, size)
+}
+
+// putBuffer releases buffer for reuse, requests reading it must have been
+// sent with bodyReader and released.
+func (s *S3) putBuffer(b []byte) {
+	s.buffers.Put(&b)
+}
+
+// bodyReader is request body of pooled buffer. Transport may still read
+// request body after response is returned, so the buffer is detached from
+// it by release before reuse.
+type bodyReader struct {
+	mu       sync.Mutex
+	r        *bytes.Reader
+	released bool
+}
+
+func newBodyReader(b []byte) *bodyReader {
+	return &bodyReader{r: bytes.NewReader(b)}
+}
+
+func (b *bodyReader) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.released {
+		return 0, io.EOF
+	}
+
+	return b.r.Read(p)
+}
+
+func (b *bodyReader) Seek(offset int64, whence int) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.r.Seek(offset, whence)
+}
+
+func (b *bodyReader) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.released = true
+}
@@ -0,0 +1,92 @@
+package s3
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"testing"
+)
+
+// discardParts answers multipart uploads without storing parts, so
+// allocations of the fake do not add up to the uploaded ones.
+func discardParts(w http.ResponseWriter, r *http.Request, op string) bool {
+	switch op {
+	case "UploadPart":
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("ETag", `"part"`)
+	case "CompleteMultipartUpload":
+		fmt.Fprint(w, `<CompleteMultipartUploadResult></CompleteMultipartUploadResult>`)
+	default:
+		return false
+	}
+
+	return true
+}
+
+// zeroStream is stream of unknown size, which is uploaded in parts.
+type zeroStream struct {
+	n int64
+}
+
+func (z *zeroStream) Read(p []byte) (int, error) {
+	if z.n <= 0 {
+		return 0, io.EOF
+	}
+
+	n := min(int64(len(p)), z.n)
+	clear(p[:n])
+	z.n -= n
+
+	return int(n), nil
+}
+
+func TestUploadReusesBuffers(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool does not keep buffers with race detector")
+	}
+
+	const concurrency, parts = 4, 32
+	s, f := newTestStorage(t, WithPartSize(minPartSize), WithConcurrency(concurrency))
+	f.setHook(discardParts)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	if err := s.Upload("db.sql", &zeroStream{parts * minPartSize}); err != nil {
+		t.Fatal(err)
+	}
+
+	runtime.ReadMemStats(&after)
+
+	if n := len(f.calls("UploadPart")); n != parts {
+		t.Fatalf("%d parts, want %d", n, parts)
+	}
+
+	// parts in flight and the one being read, any more are reallocations
+	alloc := after.TotalAlloc - before.TotalAlloc
+	if limit := uint64(2 * (concurrency + 1) * minPartSize); alloc > limit {
+		t.Errorf("allocated %d MiB for %d MiB upload, want at most %d MiB", alloc>>20, parts*minPartSize>>20, limit>>20)
+	}
+}
+
+func BenchmarkUploadParts(b *testing.B) {
+	const parts = 16
+	f := newFakeS3(b)
+	f.setHook(discardParts)
+
+	for _, concurrency := range []int{1, 4} {
+		b.Run(fmt.Sprintf("concurrency-%d", concurrency), func(b *testing.B) {
+			s := f.storage(WithPartSize(minPartSize), WithConcurrency(concurrency))
+			b.SetBytes(parts * minPartSize)
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				if err := s.Upload("db.sql", &zeroStream{parts * minPartSize}); err != nil {
+					b.Fatal(err)
+				}
+				f.reset()
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package s3
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+
+	"github.com/sputnik-systems/backups-storage"
+)
+
+// Config describes connection to S3 compatible storages like MinIO or Ceph
+// RGW. Empty credentials fall back to the default AWS credentials chain.
+type Config struct {
+	Endpoint       string
+	Region         string
+	ForcePathStyle bool
+
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	Bucket string
+	Prefix string
+}
+
+func NewStorageWithConfig(cfg Config, opts ...Option) (storage.Storage, error) {
+	sess, err := cfg.session()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewStorage(sess, cfg.Bucket, cfg.Prefix, opts...)
+}
+
+func (cfg Config) session() (*session.Session, error) {
+	c := aws.NewConfig().WithS3ForcePathStyle(cfg.ForcePathStyle)
+
+	if cfg.Endpoint != "" {
+		u, err := url.Parse(cfg.Endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid endpoint %q: %w", cfg.Endpoint, err)
+		}
+
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid endpoint %q, must be http(s)://host[:port]", cfg.Endpoint)
+		}
+
+		c = c.WithEndpoint(cfg.Endpoint)
+	}
+
+	if cfg.Region != "" {
+		c = c.WithRegion(cfg.Region)
+	}
+
+	if cfg.AccessKeyID != "" || cfg.SecretAccessKey != "" {
+		c = c.WithCredentials(credentials.NewStaticCredentials(cfg.AccessKeyID, cfg.SecretAccessKey, cfg.SessionToken))
+	}
+
+	return session.NewSession(c)
+}
@@ -0,0 +1,57 @@
+package s3
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func TestNewStorageWithConfig(t *testing.T) {
+	for _, tc := range []struct {
+		pathStyle  bool
+		host, path string
+	}{
+		{true, "minio.local:9000", "/bucket/key"},
+		{false, "bucket.minio.local:9000", "/key"},
+	} {
+		st, err := NewStorageWithConfig(Config{
+			Endpoint:        "http://minio.local:9000",
+			Region:          "us-east-1",
+			ForcePathStyle:  tc.pathStyle,
+			AccessKeyID:     "id",
+			SecretAccessKey: "secret",
+			Bucket:          "bucket",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c := st.(*S3).c
+		if aws.BoolValue(c.Config.S3ForcePathStyle) != tc.pathStyle {
+			t.Errorf("path style %v, want %v", aws.BoolValue(c.Config.S3ForcePathStyle), tc.pathStyle)
+		}
+
+		req, _ := c.GetObjectRequest(&s3.GetObjectInput{Bucket: aws.String("bucket"), Key: aws.String("key")})
+		if err := req.Build(); err != nil {
+			t.Fatal(err)
+		}
+
+		if u := req.HTTPRequest.URL; u.Host != tc.host || u.Path != tc.path {
+			t.Errorf("path style %v: request to %s%s, want %s%s", tc.pathStyle, u.Host, u.Path, tc.host, tc.path)
+		}
+
+		v, err := c.Config.Credentials.Get()
+		if err != nil || v.AccessKeyID != "id" {
+			t.Errorf("credentials %s, %v", v.AccessKeyID, err)
+		}
+	}
+}
+
+func TestNewStorageWithConfigEndpoint(t *testing.T) {
+	for _, endpoint := range []string{"minio.local:9000", "ftp://minio.local", "http://", "http://[::1"} {
+		if _, err := NewStorageWithConfig(Config{Endpoint: endpoint, Region: "us-east-1", Bucket: "bucket"}); err == nil {
+			t.Errorf("endpoint %q accepted", endpoint)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package s3
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// httpClientFor returns client of S3 requests. Client of session is kept
+// unless it is the sdk default one, which has no timeouts.
+func (s *S3) httpClientFor(sess *session.Session) *http.Client {
+	if s.httpClient != nil {
+		return s.httpClient
+	}
+
+	if sess.Config != nil && sess.Config.HTTPClient != nil && sess.Config.HTTPClient != http.DefaultClient {
+		return sess.Config.HTTPClient
+	}
+
+	return defaultHTTPClient(s.concurrency)
+}
+
+// defaultHTTPClient returns client which does not hang on unreachable or
+// stalled endpoints. There is no overall timeout, as transfers of large
+// parts and objects may take long.
+func defaultHTTPClient(conns int) *http.Client {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	t.ResponseHeaderTimeout = time.Minute
+	// keep connections of concurrent part uploads between parts
+	t.MaxIdleConnsPerHost = max(conns, http.DefaultMaxIdleConnsPerHost)
+
+	return &http.Client{Transport: t}
+}
@@ -0,0 +1,78 @@
+package s3
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// countingTransport counts requests sent through it.
+type countingTransport struct {
+	http.RoundTripper
+	n atomic.Int64
+}
+
+func (c *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	c.n.Add(1)
+
+	return c.RoundTripper.RoundTrip(r)
+}
+
+func TestWithHTTPClient(t *testing.T) {
+	f := newFakeS3(t)
+	transport := &countingTransport{RoundTripper: f.client.Transport}
+	s := f.storage(WithHTTPClient(&http.Client{Transport: transport}))
+
+	if err := s.Upload("a", strings.NewReader("data")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Stat("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := transport.n.Load(); n != 2 {
+		t.Errorf("%d requests sent by the client, want 2", n)
+	}
+
+	if _, err := NewStorage(f.sess, testBucket, "", WithHTTPClient(nil)); err == nil {
+		t.Error("nil http client accepted")
+	}
+}
+
+func TestHTTPClientOfSession(t *testing.T) {
+	f := newFakeS3(t)
+
+	if c := f.storage().c.Config.HTTPClient; c != f.client {
+		t.Error("client of session is replaced")
+	}
+
+	// session without client gets the sdk default one
+	sess := session.Must(session.NewSession(&aws.Config{Region: aws.String("us-east-1")}))
+	st, err := NewStorage(sess, testBucket, "", WithConcurrency(200))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := st.(*S3).c.Config.HTTPClient
+	if c == http.DefaultClient {
+		t.Fatal("sdk default client is kept")
+	}
+
+	tr, ok := c.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("default client transport %T", c.Transport)
+	}
+
+	if tr.ResponseHeaderTimeout == 0 || tr.DialContext == nil {
+		t.Error("default client has no timeouts")
+	}
+
+	if tr.MaxIdleConnsPerHost < 200 {
+		t.Errorf("%d idle connections per host, want at least 200", tr.MaxIdleConnsPerHost)
+	}
+}
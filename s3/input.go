@@ -0,0 +1,217 @@
+package s3
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// inputs of object requests, which carry the same settings whatever
+// upload or download path is taken
+
+func (s *S3) putObjectInput(key string) *s3.PutObjectInput {
+	in := &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}
+
+	if s.sse != "" {
+		in.ServerSideEncryption = aws.String(s.sse)
+		if s.sseKMSKeyID != "" {
+			in.SSEKMSKeyId = aws.String(s.sseKMSKeyID)
+		}
+	}
+
+	if s.sseCustomerKey != "" {
+		in.SSECustomerAlgorithm = aws.String(s3.ServerSideEncryptionAes256)
+		in.SSECustomerKey = aws.String(s.sseCustomerKey)
+		in.SSECustomerKeyMD5 = aws.String(s.sseCustomerKeyMD5)
+	}
+
+	if s.storageClass != "" {
+		in.StorageClass = aws.String(s.storageClass)
+	}
+
+	if s.tagging != "" {
+		in.Tagging = aws.String(s.tagging)
+	}
+
+	if len(s.metadata) > 0 {
+		in.Metadata = make(map[string]*string, len(s.metadata))
+		for k, v := range s.metadata {
+			in.Metadata[k] = aws.String(v)
+		}
+	}
+
+	if s.lockMode != "" {
+		in.ObjectLockMode = aws.String(s.lockMode)
+	}
+
+	if !s.lockRetainUntil.IsZero() {
+		in.ObjectLockRetainUntilDate = aws.Time(s.lockRetainUntil)
+	}
+
+	if s.acl != "" {
+		in.ACL = aws.String(s.acl)
+	}
+
+	return in
+}
+
+func (s *S3) createMultipartUploadInput(key string) *s3.CreateMultipartUploadInput {
+	in := &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}
+
+	if s.sse != "" {
+		in.ServerSideEncryption = aws.String(s.sse)
+		if s.sseKMSKeyID != "" {
+			in.SSEKMSKeyId = aws.String(s.sseKMSKeyID)
+		}
+	}
+
+	if s.sseCustomerKey != "" {
+		in.SSECustomerAlgorithm = aws.String(s3.ServerSideEncryptionAes256)
+		in.SSECustomerKey = aws.String(s.sseCustomerKey)
+		in.SSECustomerKeyMD5 = aws.String(s.sseCustomerKeyMD5)
+	}
+
+	if s.storageClass != "" {
+		in.StorageClass = aws.String(s.storageClass)
+	}
+
+	if s.tagging != "" {
+		in.Tagging = aws.String(s.tagging)
+	}
+
+	if len(s.metadata) > 0 {
+		in.Metadata = make(map[string]*string, len(s.metadata))
+		for k, v := range s.metadata {
+			in.Metadata[k] = aws.String(v)
+		}
+	}
+
+	if s.lockMode != "" {
+		in.ObjectLockMode = aws.String(s.lockMode)
+	}
+
+	if !s.lockRetainUntil.IsZero() {
+		in.ObjectLockRetainUntilDate = aws.Time(s.lockRetainUntil)
+	}
+
+	if s.acl != "" {
+		in.ACL = aws.String(s.acl)
+	}
+
+	return in
+}
+
+func (s *S3) uploadPartInput(key string, uploadId *string, partNumber int64) *s3.UploadPartInput {
+	in := &s3.UploadPartInput{
+		Bucket:     aws.String(s.bucket),
+		Key:        aws.String(key),
+		UploadId:   uploadId,
+		PartNumber: aws.Int64(partNumber),
+	}
+
+	if s.sseCustomerKey != "" {
+		in.SSECustomerAlgorithm = aws.String(s3.ServerSideEncryptionAes256)
+		in.SSECustomerKey = aws.String(s.sseCustomerKey)
+		in.SSECustomerKeyMD5 = aws.String(s.sseCustomerKeyMD5)
+	}
+
+	return in
+}
+
+func (s *S3) copyObjectInput(key, source string) *s3.CopyObjectInput {
+	in := &s3.CopyObjectInput{
+		Bucket:     aws.String(s.bucket),
+		Key:        aws.String(key),
+		CopySource: aws.String(source),
+	}
+
+	if s.sse != "" {
+		in.ServerSideEncryption = aws.String(s.sse)
+		if s.sseKMSKeyID != "" {
+			in.SSEKMSKeyId = aws.String(s.sseKMSKeyID)
+		}
+	}
+
+	if s.sseCustomerKey != "" {
+		in.SSECustomerAlgorithm = aws.String(s3.ServerSideEncryptionAes256)
+		in.SSECustomerKey = aws.String(s.sseCustomerKey)
+		in.SSECustomerKeyMD5 = aws.String(s.sseCustomerKeyMD5)
+		in.CopySourceSSECustomerAlgorithm = aws.String(s3.ServerSideEncryptionAes256)
+		in.CopySourceSSECustomerKey = aws.String(s.sseCustomerKey)
+		in.CopySourceSSECustomerKeyMD5 = aws.String(s.sseCustomerKeyMD5)
+	}
+
+	if s.storageClass != "" {
+		in.StorageClass = aws.String(s.storageClass)
+	}
+
+	if s.lockMode != "" {
+		in.ObjectLockMode = aws.String(s.lockMode)
+	}
+
+	if !s.lockRetainUntil.IsZero() {
+		in.ObjectLockRetainUntilDate = aws.Time(s.lockRetainUntil)
+	}
+
+	if s.acl != "" {
+		in.ACL = aws.String(s.acl)
+	}
+
+	return in
+}
+
+func (s *S3) uploadPartCopyInput(key string, uploadId *string, partNumber int64, source string) *s3.UploadPartCopyInput {
+	in := &s3.UploadPartCopyInput{
+		Bucket:     aws.String(s.bucket),
+		Key:        aws.String(key),
+		UploadId:   uploadId,
+		PartNumber: aws.Int64(partNumber),
+		CopySource: aws.String(source),
+	}
+
+	if s.sseCustomerKey != "" {
+		in.SSECustomerAlgorithm = aws.String(s3.ServerSideEncryptionAes256)
+		in.SSECustomerKey = aws.String(s.sseCustomerKey)
+		in.SSECustomerKeyMD5 = aws.String(s.sseCustomerKeyMD5)
+		in.CopySourceSSECustomerAlgorithm = aws.String(s3.ServerSideEncryptionAes256)
+		in.CopySourceSSECustomerKey = aws.String(s.sseCustomerKey)
+		in.CopySourceSSECustomerKeyMD5 = aws.String(s.sseCustomerKeyMD5)
+	}
+
+	return in
+}
+
+func (s *S3) getObjectInput(key string) *s3.GetObjectInput {
+	in := &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}
+
+	if s.sseCustomerKey != "" {
+		in.SSECustomerAlgorithm = aws.String(s3.ServerSideEncryptionAes256)
+		in.SSECustomerKey = aws.String(s.sseCustomerKey)
+		in.SSECustomerKeyMD5 = aws.String(s.sseCustomerKeyMD5)
+	}
+
+	return in
+}
+
+func (s *S3) headObjectInput(key string) *s3.HeadObjectInput {
+	in := &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}
+
+	if s.sseCustomerKey != "" {
+		in.SSECustomerAlgorithm = aws.String(s3.ServerSideEncryptionAes256)
+		in.SSECustomerKey = aws.String(s.sseCustomerKey)
+		in.SSECustomerKeyMD5 = aws.String(s.sseCustomerKeyMD5)
+	}
+
+	return in
+}
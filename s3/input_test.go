@@ -0,0 +1,98 @@
+package s3
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func TestSSEInputs(t *testing.T) {
+	f := newFakeS3(t)
+
+	for _, tc := range []struct {
+		name     string
+		opts     []Option
+		sse, kms string
+	}{
+		{"none", nil, "", ""},
+		{"sse-s3", []Option{WithSSES3()}, s3.ServerSideEncryptionAes256, ""},
+		{"sse-kms", []Option{WithSSEKMS("key")}, s3.ServerSideEncryptionAwsKms, "key"},
+		{"sse-kms default key", []Option{WithSSEKMS("")}, s3.ServerSideEncryptionAwsKms, ""},
+		{"last wins", []Option{WithSSEKMS("key"), WithSSES3()}, s3.ServerSideEncryptionAes256, ""},
+	} {
+		s := f.storage(tc.opts...)
+
+		put := s.putObjectInput("a")
+		if got := aws.StringValue(put.ServerSideEncryption); got != tc.sse {
+			t.Errorf("%s: put encryption %q, want %q", tc.name, got, tc.sse)
+		}
+		if got := aws.StringValue(put.SSEKMSKeyId); got != tc.kms {
+			t.Errorf("%s: put kms key %q, want %q", tc.name, got, tc.kms)
+		}
+
+		create := s.createMultipartUploadInput("a")
+		if got := aws.StringValue(create.ServerSideEncryption); got != tc.sse {
+			t.Errorf("%s: multipart encryption %q, want %q", tc.name, got, tc.sse)
+		}
+		if got := aws.StringValue(create.SSEKMSKeyId); got != tc.kms {
+			t.Errorf("%s: multipart kms key %q, want %q", tc.name, got, tc.kms)
+		}
+	}
+}
+
+func TestStorageClassInputs(t *testing.T) {
+	f := newFakeS3(t)
+	s := f.storage(WithStorageClass(s3.StorageClassStandardIa))
+
+	if got := aws.StringValue(s.putObjectInput("a").StorageClass); got != s3.StorageClassStandardIa {
+		t.Errorf("put storage class %q", got)
+	}
+
+	if got := aws.StringValue(s.createMultipartUploadInput("a").StorageClass); got != s3.StorageClassStandardIa {
+		t.Errorf("multipart storage class %q", got)
+	}
+
+	if got := aws.StringValue(s.copyObjectInput("a", "b").StorageClass); got != s3.StorageClassStandardIa {
+		t.Errorf("copy storage class %q", got)
+	}
+
+	if in := f.storage().putObjectInput("a"); in.StorageClass != nil {
+		t.Errorf("default storage class %q", *in.StorageClass)
+	}
+
+	for _, class := range []string{"GLACIER", "GLACIER_IR", "DEEP_ARCHIVE", "STANDARD"} {
+		if _, err := NewStorage(f.sess, testBucket, "", WithStorageClass(class)); err != nil {
+			t.Errorf("%s: %v", class, err)
+		}
+	}
+
+	for _, class := range []string{"", "glacier", "COLD"} {
+		if _, err := NewStorage(f.sess, testBucket, "", WithStorageClass(class)); err == nil {
+			t.Errorf("storage class %q accepted", class)
+		}
+	}
+}
+
+func TestACLInputs(t *testing.T) {
+	f := newFakeS3(t)
+	s := f.storage(WithACL(s3.ObjectCannedACLBucketOwnerFullControl))
+
+	if got := aws.StringValue(s.putObjectInput("a").ACL); got != s3.ObjectCannedACLBucketOwnerFullControl {
+		t.Errorf("put acl %q", got)
+	}
+
+	if got := aws.StringValue(s.createMultipartUploadInput("a").ACL); got != s3.ObjectCannedACLBucketOwnerFullControl {
+		t.Errorf("multipart acl %q", got)
+	}
+
+	if in := f.storage().putObjectInput("a"); in.ACL != nil {
+		t.Errorf("default acl %q", *in.ACL)
+	}
+
+	for _, acl := range []string{"", "Private", "full-control"} {
+		if _, err := NewStorage(f.sess, testBucket, "", WithACL(acl)); err == nil {
+			t.Errorf("acl %q accepted", acl)
+		}
+	}
+}
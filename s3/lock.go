@@ -0,0 +1,40 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/sputnik-systems/backups-storage"
+)
+
+// SetRetention sets object lock of existing object. Retention in COMPLIANCE
+// mode can not be shortened or removed until it expires.
+func (s *S3) SetRetention(name, mode string, until time.Time) error {
+	key, err := storage.ObjectKey(s.prefix, name)
+	if err != nil {
+		return err
+	}
+
+	in := &s3.PutObjectRetentionInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Retention: &s3.ObjectLockRetention{
+			Mode:            aws.String(mode),
+			RetainUntilDate: aws.Time(until),
+		},
+	}
+
+	if _, err := s.c.PutObjectRetentionWithContext(context.Background(), in); err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("%s: %w", key, storage.ErrNotFound)
+		}
+
+		return fmt.Errorf("set retention of %s: %w", key, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package s3
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/sputnik-systems/backups-storage"
+)
+
+func TestObjectLockInputs(t *testing.T) {
+	f := newFakeS3(t)
+	until := time.Date(2031, 10, 1, 0, 0, 0, 0, time.UTC)
+	s := f.storage(WithObjectLockMode(s3.ObjectLockModeCompliance), WithObjectLockRetainUntil(until))
+
+	put := s.putObjectInput("a")
+	if got := aws.StringValue(put.ObjectLockMode); got != s3.ObjectLockModeCompliance {
+		t.Errorf("put lock mode %q", got)
+	}
+	if got := aws.TimeValue(put.ObjectLockRetainUntilDate); !got.Equal(until) {
+		t.Errorf("put retain until %s", got)
+	}
+
+	create := s.createMultipartUploadInput("a")
+	if got := aws.StringValue(create.ObjectLockMode); got != s3.ObjectLockModeCompliance {
+		t.Errorf("multipart lock mode %q", got)
+	}
+	if got := aws.TimeValue(create.ObjectLockRetainUntilDate); !got.Equal(until) {
+		t.Errorf("multipart retain until %s", got)
+	}
+
+	if in := f.storage().putObjectInput("a"); in.ObjectLockMode != nil || in.ObjectLockRetainUntilDate != nil {
+		t.Error("object lock set by default")
+	}
+
+	if _, err := NewStorage(f.sess, testBucket, "", WithObjectLockMode("compliance")); err == nil {
+		t.Error("unknown lock mode accepted")
+	}
+}
+
+func TestUploadObjectLock(t *testing.T) {
+	until := time.Date(2031, 10, 1, 0, 0, 0, 0, time.UTC)
+	s, f := newTestStorage(t, WithObjectLockMode(s3.ObjectLockModeGovernance), WithObjectLockRetainUntil(until))
+
+	if err := s.Upload("a", strings.NewReader("data")); err != nil {
+		t.Fatal(err)
+	}
+
+	h := f.calls("PutObject")[0].header
+	if got := h.Get("X-Amz-Object-Lock-Mode"); got != s3.ObjectLockModeGovernance {
+		t.Errorf("lock mode header %q", got)
+	}
+	if got := h.Get("X-Amz-Object-Lock-Retain-Until-Date"); got != "2031-10-01T00:00:00Z" {
+		t.Errorf("retain until header %q", got)
+	}
+}
+
+func TestSetRetention(t *testing.T) {
+	s, f := newTestStorage(t)
+	until := time.Date(2031, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	var got struct {
+		Mode            string
+		RetainUntilDate time.Time
+	}
+	// the fake has no object lock
+	f.setHook(func(w http.ResponseWriter, r *http.Request, op string) bool {
+		if op != "PutObjectRetention" {
+			return false
+		}
+
+		if err := xml.NewDecoder(r.Body).Decode(&got); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+
+		return true
+	})
+
+	if err := s.SetRetention("db.sql", s3.ObjectLockModeCompliance, until); err != nil {
+		t.Fatal(err)
+	}
+
+	if c := f.calls("PutObjectRetention"); len(c) != 1 || c[0].key != "backups/db.sql" {
+		t.Errorf("retention requests %+v", c)
+	}
+
+	if got.Mode != s3.ObjectLockModeCompliance || !got.RetainUntilDate.Equal(until) {
+		t.Errorf("set retention %s until %s", got.Mode, got.RetainUntilDate)
+	}
+
+	f.setHook(replyError("PutObjectRetention", http.StatusNotFound, s3.ErrCodeNoSuchKey))
+	if err := s.SetRetention("missing", s3.ObjectLockModeCompliance, until); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("retention of missing object: %v", err)
+	}
+}
+
+func TestDeleteLocked(t *testing.T) {
+	s, f := newTestStorage(t)
+	f.put("backups/db.sql", []byte("data"))
+	f.setHook(deleteResult(func(key string) bool { return key == "backups/db.sql" }))
+
+	err := s.Delete("db.sql")
+	if err == nil {
+		t.Fatal("delete of locked object succeeded")
+	}
+
+	if !strings.Contains(err.Error(), "backups/db.sql") || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("error %q does not tell the object and the reason", err)
+	}
+}
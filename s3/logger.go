@@ -0,0 +1,17 @@
+package s3
+
+// Logger receives events of storage operations, transfers are logged at
+// debug level, retries and aborts at warn.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debugf(string, ...interface{}) {}
+
+func (nopLogger) Infof(string, ...interface{}) {}
+
+func (nopLogger) Warnf(string, ...interface{}) {}
@@ -0,0 +1,110 @@
+package s3
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testLogger records formatted messages prefixed with level.
+type testLogger struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (l *testLogger) log(level, format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.msgs = append(l.msgs, level+" "+fmt.Sprintf(format, args...))
+}
+
+func (l *testLogger) Debugf(format string, args ...interface{}) { l.log("debug", format, args...) }
+
+func (l *testLogger) Infof(format string, args ...interface{}) { l.log("info", format, args...) }
+
+func (l *testLogger) Warnf(format string, args ...interface{}) { l.log("warn", format, args...) }
+
+// logged reports whether a message of level contains all parts.
+func (l *testLogger) logged(level string, parts ...string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, m := range l.msgs {
+		found := strings.HasPrefix(m, level+" ")
+		for _, p := range parts {
+			found = found && strings.Contains(m, p)
+		}
+
+		if found {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestLogger(t *testing.T) {
+	l := &testLogger{}
+	s, f := newTestStorage(t, WithPartSize(minPartSize), WithLogger(l), WithRetry(2, time.Millisecond))
+
+	if err := s.Upload("small.sql", strings.NewReader("data")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Upload("large.sql", bytes.NewReader(randomBytes(t, 2*minPartSize))); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Download("small.sql", &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Delete("small.sql"); err != nil {
+		t.Fatal(err)
+	}
+
+	f.setHook(failTimes("PutObject", 1, http.StatusServiceUnavailable))
+	if err := s.Upload("retried.sql", strings.NewReader("data")); err != nil {
+		t.Fatal(err)
+	}
+
+	f.setHook(failPart("2"))
+	if err := s.Upload("aborted.sql", bytes.NewReader(randomBytes(t, 2*minPartSize))); err == nil {
+		t.Fatal("upload with failed part succeeded")
+	}
+
+	for _, tc := range []struct {
+		level string
+		parts []string
+	}{
+		{"debug", []string{"put backups/small.sql", "4 bytes"}},
+		{"debug", []string{"multipart upload of backups/large.sql", fmt.Sprint(minPartSize)}},
+		{"debug", []string{"part 1 of backups/large.sql", fmt.Sprintf("%d bytes in", minPartSize)}},
+		{"debug", []string{"part 2 of backups/large.sql"}},
+		{"debug", []string{"downloaded backups/small.sql", "4 bytes"}},
+		{"debug", []string{"deleting 1 objects"}},
+		{"warn", []string{"attempt 1 of 2 failed"}},
+		{"warn", []string{"aborting multipart upload of backups/aborted.sql"}},
+	} {
+		if !l.logged(tc.level, tc.parts...) {
+			t.Errorf("no %s message with %q", tc.level, tc.parts)
+		}
+	}
+
+	if t.Failed() {
+		t.Log(strings.Join(l.msgs, "\n"))
+	}
+}
+
+func TestNopLogger(t *testing.T) {
+	s, _ := newTestStorage(t)
+
+	if _, ok := s.log.(nopLogger); !ok {
+		t.Errorf("default logger %T", s.log)
+	}
+}
@@ -0,0 +1,107 @@
+//go:build integration
+
+package s3
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/sputnik-systems/backups-storage"
+)
+
+// Run with MinIO, e.g. docker run -p 9000:9000 minio/minio server /data,
+// and go test -tags integration -run MinIO ./s3. MINIO_ENDPOINT,
+// MINIO_ACCESS_KEY and MINIO_SECRET_KEY override the defaults of the
+// container.
+
+func newMinIOStorage(t *testing.T, opts ...Option) *S3 {
+	endpoint := os.Getenv("MINIO_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "http://127.0.0.1:9000"
+	}
+
+	accessKey, secretKey := os.Getenv("MINIO_ACCESS_KEY"), os.Getenv("MINIO_SECRET_KEY")
+	if accessKey == "" {
+		accessKey, secretKey = "minioadmin", "minioadmin"
+	}
+
+	sess, err := session.NewSession(&aws.Config{
+		Credentials:      credentials.NewStaticCredentials(accessKey, secretKey, ""),
+		Endpoint:         aws.String(endpoint),
+		Region:           aws.String("us-east-1"),
+		S3ForcePathStyle: aws.Bool(true),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bucket := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	if _, err := s3.New(sess).CreateBucket(&s3.CreateBucketInput{Bucket: aws.String(bucket)}); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := NewStorage(sess, bucket, "backups", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		storage.Clear(context.Background(), st, "", true)
+		s3.New(sess).DeleteBucket(&s3.DeleteBucketInput{Bucket: aws.String(bucket)})
+	})
+
+	return st.(*S3)
+}
+
+func TestMinIOMultipartUpload(t *testing.T) {
+	s := newMinIOStorage(t, WithPartSize(minPartSize), WithConcurrency(3))
+
+	// short reads must not make non-final parts smaller, which minio
+	// rejects with EntityTooSmall
+	data := randomBytes(t, 3*minPartSize+1234)
+	if err := s.Upload("db/dump.sql", &trickleReader{bytes.NewReader(data), 7919}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := s.Download("db/dump.sql", &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Fatalf("downloaded %d bytes, want %d", buf.Len(), len(data))
+	}
+
+	fi, err := s.Stat("db/dump.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fi.Size() != int64(len(data)) {
+		t.Errorf("stat size %d, want %d", fi.Size(), len(data))
+	}
+}
+
+func TestMinIOUnknownSizeStream(t *testing.T) {
+	s := newMinIOStorage(t, WithPartSize(minPartSize))
+
+	// not seekable, so it is uploaded by parts without knowing the size
+	data := randomBytes(t, 2*minPartSize+1)
+	if err := s.Upload("db/stream.sql", io.MultiReader(bytes.NewReader(data))); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := s.Download("db/stream.sql", &buf); err != nil || !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("downloaded %d bytes, %v, want %d", buf.Len(), err, len(data))
+	}
+}
@@ -0,0 +1,360 @@
+package s3
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"golang.org/x/time/rate"
+)
+
+const (
+	minPartSize = int64(5 * 1024 * 1024)
+	maxPartSize = int64(5 * 1024 * 1024 * 1024)
+	maxParts    = int64(10000)
+
+	maxDeleteKeys = 1000
+)
+
+type Option func(*S3) error
+
+// WithPartSize sets size of multipart upload parts, 100 MiB by default.
+func WithPartSize(size int64) Option {
+	return func(s *S3) error {
+		if size < minPartSize || size > maxPartSize {
+			return fmt.Errorf("part size %d is out of range [%d, %d]", size, minPartSize, maxPartSize)
+		}
+
+		s.partSize = size
+
+		return nil
+	}
+}
+
+// WithProgress sets a callback invoked with transferred and total bytes
+// after every part of Upload and Download. Total is -1 when upload size
+// can not be determined, i.e. reader is not an io.Seeker. It is called from
+// the goroutine of Upload or Download, parts completed at once by concurrent
+// uploads are reported together.
+func WithProgress(fn func(done, total int64)) Option {
+	return func(s *S3) error {
+		s.progress = fn
+
+		return nil
+	}
+}
+
+// WithRetry retries transient errors of part uploads, puts, gets and deletes
+// up to maxAttempts times in total with exponential backoff from base, the
+// delay is capped at maxRetryDelay. Retryer of the session still runs under
+// each attempt, so requests are sent up to maxAttempts * (MaxRetries + 1)
+// times, set aws.Config MaxRetries to 0 to retry only here.
+func WithRetry(maxAttempts int, base time.Duration) Option {
+	return func(s *S3) error {
+		if maxAttempts < 1 {
+			return fmt.Errorf("invalid retry attempts %d", maxAttempts)
+		}
+
+		if base <= 0 {
+			return fmt.Errorf("invalid retry base delay %s", base)
+		}
+
+		s.maxAttempts = maxAttempts
+		s.retryBase = base
+
+		return nil
+	}
+}
+
+// WithConcurrency sets the number of multipart upload parts sent in
+// parallel, each of them holds a part size buffer in memory.
+func WithConcurrency(n int) Option {
+	return func(s *S3) error {
+		if n < 1 {
+			return fmt.Errorf("invalid concurrency %d", n)
+		}
+
+		s.concurrency = n
+
+		return nil
+	}
+}
+
+// WithChecksum stores SHA-256 of uploaded objects in their metadata and
+// verifies it on Download. Multipart uploads are copied in place once
+// completed to set the checksum, which doubles the time of large uploads.
+func WithChecksum() Option {
+	return func(s *S3) error {
+		s.checksum = true
+
+		return nil
+	}
+}
+
+// WithSSES3 encrypts uploaded objects at rest with S3 managed keys.
+func WithSSES3() Option {
+	return func(s *S3) error {
+		s.sse = s3.ServerSideEncryptionAes256
+		s.sseKMSKeyID = ""
+		s.sseCustomerKey = ""
+
+		return nil
+	}
+}
+
+// WithSSEKMS encrypts uploaded objects at rest with the KMS key, empty
+// key id stands for the AWS managed key of account.
+func WithSSEKMS(keyID string) Option {
+	return func(s *S3) error {
+		s.sse = s3.ServerSideEncryptionAwsKms
+		s.sseKMSKeyID = keyID
+		s.sseCustomerKey = ""
+
+		return nil
+	}
+}
+
+// WithSSECustomerKey encrypts uploaded objects with the 256 bit customer
+// provided key, which is then required to read them.
+func WithSSECustomerKey(key []byte) Option {
+	return func(s *S3) error {
+		if len(key) != 32 {
+			return fmt.Errorf("invalid customer key length %d, must be 32 bytes", len(key))
+		}
+
+		sum := md5.Sum(key)
+
+		s.sse = ""
+		s.sseKMSKeyID = ""
+		s.sseCustomerKey = string(key)
+		s.sseCustomerKeyMD5 = base64.StdEncoding.EncodeToString(sum[:])
+
+		return nil
+	}
+}
+
+// WithStorageClass sets storage class of uploaded objects. Objects in
+// GLACIER and DEEP_ARCHIVE classes can not be downloaded until restored.
+func WithStorageClass(class string) Option {
+	return func(s *S3) error {
+		// glacier instant retrieval is newer than sdk enum
+		valid := class == "GLACIER_IR"
+		for _, v := range s3.StorageClass_Values() {
+			valid = valid || class == v
+		}
+
+		if !valid {
+			return fmt.Errorf("unknown storage class %q", class)
+		}
+
+		s.storageClass = class
+
+		return nil
+	}
+}
+
+// WithTags sets tags of uploaded objects.
+func WithTags(tags map[string]string) Option {
+	return func(s *S3) error {
+		// tagging header is encoded as url query
+		v := make(url.Values, len(tags))
+		for k, t := range tags {
+			v.Set(k, t)
+		}
+
+		s.tagging = v.Encode()
+
+		return nil
+	}
+}
+
+// WithMetadata sets user metadata (x-amz-meta-*) of uploaded objects,
+// keys are lower cased as S3 does.
+func WithMetadata(metadata map[string]string) Option {
+	return func(s *S3) error {
+		s.metadata = make(map[string]string, len(metadata))
+		for k, v := range metadata {
+			s.metadata[strings.ToLower(k)] = v
+		}
+
+		return nil
+	}
+}
+
+// WithRateLimit limits the aggregate rate of all uploads and downloads of
+// storage to bytesPerSec.
+func WithRateLimit(bytesPerSec int64) Option {
+	return func(s *S3) error {
+		if bytesPerSec <= 0 {
+			return fmt.Errorf("invalid rate limit %d", bytesPerSec)
+		}
+
+		s.limiter = rate.NewLimiter(rate.Limit(bytesPerSec), int(bytesPerSec))
+
+		return nil
+	}
+}
+
+// WithLogger sets logger of storage operations, nothing is logged by default.
+func WithLogger(l Logger) Option {
+	return func(s *S3) error {
+		if l == nil {
+			l = nopLogger{}
+		}
+
+		s.log = l
+
+		return nil
+	}
+}
+
+// WithDryRun makes Delete, DeleteBatch, DeleteVersion and Move log keys of
+// objects they would delete at info level, see WithLogger, without deleting
+// them. Move does not copy either.
+func WithDryRun() Option {
+	return func(s *S3) error {
+		s.dryRun = true
+
+		return nil
+	}
+}
+
+// WithNoOverwrite makes Upload fail with storage.ErrAlreadyExists when the
+// object exists.
+func WithNoOverwrite() Option {
+	return func(s *S3) error {
+		s.noOverwrite = true
+
+		return nil
+	}
+}
+
+// WithoutETagCheck skips comparing etag of completed multipart uploads with
+// the one computed from parts, for endpoints which compute it otherwise.
+func WithoutETagCheck() Option {
+	return func(s *S3) error {
+		s.noETagCheck = true
+
+		return nil
+	}
+}
+
+// WithContentType sets content type of uploaded objects instead of sniffing
+// it from their beginning.
+func WithContentType(contentType string) Option {
+	return func(s *S3) error {
+		s.contentType = contentType
+
+		return nil
+	}
+}
+
+// WithObjectLockMode sets object lock mode of uploaded objects, GOVERNANCE
+// or COMPLIANCE, it requires WithObjectLockRetainUntil and bucket with
+// object lock enabled.
+func WithObjectLockMode(mode string) Option {
+	return func(s *S3) error {
+		valid := false
+		for _, v := range s3.ObjectLockMode_Values() {
+			valid = valid || mode == v
+		}
+
+		if !valid {
+			return fmt.Errorf("unknown object lock mode %q", mode)
+		}
+
+		s.lockMode = mode
+
+		return nil
+	}
+}
+
+// WithObjectLockRetainUntil sets the time uploaded objects can not be
+// deleted or overwritten until.
+func WithObjectLockRetainUntil(t time.Time) Option {
+	return func(s *S3) error {
+		s.lockRetainUntil = t
+
+		return nil
+	}
+}
+
+// WithACL sets canned ACL of uploaded objects, e.g. bucket-owner-full-control
+// for buckets of other accounts.
+func WithACL(acl string) Option {
+	return func(s *S3) error {
+		valid := false
+		for _, v := range s3.ObjectCannedACL_Values() {
+			valid = valid || acl == v
+		}
+
+		if !valid {
+			return fmt.Errorf("unknown canned acl %q", acl)
+		}
+
+		s.acl = acl
+
+		return nil
+	}
+}
+
+// WithHTTPClient sets HTTP client of S3 requests. By default the client of
+// session is used, unless it is the sdk default one, which is replaced by
+// client with dial and response header timeouts.
+func WithHTTPClient(c *http.Client) Option {
+	return func(s *S3) error {
+		if c == nil {
+			return fmt.Errorf("http client is nil")
+		}
+
+		s.httpClient = c
+
+		return nil
+	}
+}
+
+// WithRequesterPays makes requests to requester pays bucket, which are
+// charged to the requester, other requests to such bucket are denied. The
+// header is signed into presigned urls, so their clients must send it too.
+func WithRequesterPays() Option {
+	return func(s *S3) error {
+		s.requesterPays = true
+
+		return nil
+	}
+}
+
+// WithExpectedBucketOwner makes requests fail with access denied unless the
+// bucket is owned by the account, e.g. after the bucket name was taken over
+// by another account.
+func WithExpectedBucketOwner(accountID string) Option {
+	return func(s *S3) error {
+		if len(accountID) != 12 || strings.Trim(accountID, "0123456789") != "" {
+			return fmt.Errorf("invalid account id %q", accountID)
+		}
+
+		s.bucketOwner = accountID
+
+		return nil
+	}
+}
+
+// WithOperationTimeout limits every request to S3 to d, reading of object
+// body included, so whole objects must be downloaded within it, unlike
+// DownloadResumable ranges. Uploads are limited per part.
+func WithOperationTimeout(d time.Duration) Option {
+	return func(s *S3) error {
+		if d <= 0 {
+			return fmt.Errorf("invalid operation timeout %s", d)
+		}
+
+		s.opTimeout = d
+
+		return nil
+	}
+}
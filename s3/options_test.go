@@ -0,0 +1,56 @@
+package s3
+
+import "testing"
+
+func TestWithPartSize(t *testing.T) {
+	f := newFakeS3(t)
+
+	if s := f.storage(); s.partSize != 100<<20 {
+		t.Errorf("default part size %d, want %d", s.partSize, 100<<20)
+	}
+
+	for _, tc := range []struct {
+		size int64
+		ok   bool
+	}{
+		{minPartSize - 1, false},
+		{minPartSize, true},
+		{maxPartSize, true},
+		{maxPartSize + 1, false},
+		{0, false},
+		{-1, false},
+	} {
+		s, err := NewStorage(f.sess, testBucket, "backups", WithPartSize(tc.size))
+		if (err == nil) != tc.ok {
+			t.Errorf("part size %d: got %v, want ok %v", tc.size, err, tc.ok)
+		}
+
+		if tc.ok && s.(*S3).partSize != tc.size {
+			t.Errorf("part size %d: set %d", tc.size, s.(*S3).partSize)
+		}
+	}
+}
+
+func TestPartSizeForLargeObject(t *testing.T) {
+	s := newFakeS3(t).storage(WithPartSize(minPartSize))
+
+	// fits the parts limit with configured part size
+	if ps, err := s.partSizeFor(minPartSize * maxParts); err != nil || ps != minPartSize {
+		t.Errorf("got %d, %v, want %d", ps, err, minPartSize)
+	}
+
+	// one byte more needs larger parts
+	size := int64(minPartSize*maxParts + 1)
+	ps, err := s.partSizeFor(size)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ps <= minPartSize || (size+ps-1)/ps > maxParts {
+		t.Errorf("part size %d gives %d parts", ps, (size+ps-1)/ps)
+	}
+
+	if _, err := s.partSizeFor(maxPartSize*maxParts + 1); err == nil {
+		t.Error("part size for object over the maximum")
+	}
+}
@@ -0,0 +1,52 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var (
+	ErrBucketNotFound = errors.New("bucket not found")
+	ErrAccessDenied   = errors.New("access denied")
+)
+
+// Ping checks that bucket is reachable and the prefix can be listed, so
+// misconfigured storage fails before backup starts. Errors wrap
+// ErrBucketNotFound or ErrAccessDenied when S3 reports the reason.
+func (s *S3) Ping() error {
+	return s.PingContext(context.Background())
+}
+
+func (s *S3) PingContext(ctx context.Context) error {
+	in := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(s.bucket),
+		Prefix:  aws.String(s.key("")),
+		MaxKeys: aws.Int64(1),
+	}
+
+	_, err := s.c.ListObjectsV2WithContext(ctx, in)
+	if err == nil {
+		return nil
+	}
+
+	if aerr, ok := err.(awserr.Error); ok {
+		switch aerr.Code() {
+		case s3.ErrCodeNoSuchBucket:
+			return fmt.Errorf("%s: %w", s.bucket, ErrBucketNotFound)
+		case "AccessDenied", "InvalidAccessKeyId", "SignatureDoesNotMatch", "ExpiredToken":
+			return fmt.Errorf("%s: %w: %v", s.bucket, ErrAccessDenied, err)
+		}
+	}
+
+	if rerr, ok := err.(awserr.RequestFailure); ok && rerr.StatusCode() == http.StatusForbidden {
+		return fmt.Errorf("%s: %w: %v", s.bucket, ErrAccessDenied, err)
+	}
+
+	return err
+}
@@ -0,0 +1,44 @@
+package s3
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestPing(t *testing.T) {
+	s, f := newTestStorage(t)
+
+	if err := s.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	c := f.calls("ListObjects")
+	if len(c) != 1 || c[0].query.Get("list-type") != "2" || c[0].query.Get("max-keys") != "1" || c[0].query.Get("prefix") != "backups/" {
+		t.Errorf("ping requests %+v", c)
+	}
+
+	st, err := NewStorage(f.sess, "missing", "backups")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := st.(*S3).Ping(); !errors.Is(err, ErrBucketNotFound) {
+		t.Errorf("ping of missing bucket: %v", err)
+	}
+
+	f.setHook(replyError("ListObjects", http.StatusForbidden, "AccessDenied"))
+	if err := s.Ping(); !errors.Is(err, ErrAccessDenied) || errors.Is(err, ErrBucketNotFound) {
+		t.Errorf("ping with denied access: %v", err)
+	}
+
+	f.setHook(failOp("ListObjects"))
+	if err := s.Ping(); !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("ping with forbidden status: %v", err)
+	}
+
+	f.setHook(replyError("ListObjects", http.StatusInternalServerError, "InternalError"))
+	if err := s.Ping(); err == nil || errors.Is(err, ErrAccessDenied) || errors.Is(err, ErrBucketNotFound) {
+		t.Errorf("ping with server error: %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package s3
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/sputnik-systems/backups-storage"
+)
+
+// NewSpacesStorage returns storage in DigitalOcean Spaces of region like
+// nyc3 or fra1.
+func NewSpacesStorage(region, key, secret, bucket, prefix string, opts ...Option) (storage.Storage, error) {
+	if region == "" {
+		return nil, fmt.Errorf("spaces region is required")
+	}
+
+	return NewStorageWithConfig(Config{
+		Endpoint: spacesEndpoint(region),
+		// spaces ignore signing region, but sdk requires one
+		Region:          "us-east-1",
+		AccessKeyID:     key,
+		SecretAccessKey: secret,
+		Bucket:          bucket,
+		Prefix:          prefix,
+	}, opts...)
+}
+
+// NewWasabiStorage returns storage in Wasabi of region like us-east-1 or
+// eu-central-1.
+func NewWasabiStorage(region, key, secret, bucket, prefix string, opts ...Option) (storage.Storage, error) {
+	if region == "" {
+		return nil, fmt.Errorf("wasabi region is required")
+	}
+
+	return NewStorageWithConfig(Config{
+		Endpoint:        wasabiEndpoint(region),
+		Region:          region,
+		AccessKeyID:     key,
+		SecretAccessKey: secret,
+		Bucket:          bucket,
+		Prefix:          prefix,
+	}, opts...)
+}
+
+func spacesEndpoint(region string) string {
+	return fmt.Sprintf("https://%s.digitaloceanspaces.com", region)
+}
+
+func wasabiEndpoint(region string) string {
+	return fmt.Sprintf("https://s3.%s.wasabisys.com", region)
+}
+
+// NewB2Storage returns storage in Backblaze B2 of region like us-west-004
+// via its S3 compatible API. B2 does not support storage classes and object
+// tagging, so they are omitted, and SSE-KMS, which is rejected.
+func NewB2Storage(region, keyID, key, bucket, prefix string, opts ...Option) (storage.Storage, error) {
+	if region == "" {
+		return nil, fmt.Errorf("b2 region is required")
+	}
+
+	return NewStorageWithConfig(Config{
+		Endpoint:        b2Endpoint(region),
+		Region:          region,
+		AccessKeyID:     keyID,
+		SecretAccessKey: key,
+		Bucket:          bucket,
+		Prefix:          prefix,
+	}, append(append([]Option{}, opts...), b2Quirks())...)
+}
+
+// b2Quirks drops settings B2 rejects, it must be applied after user options.
+func b2Quirks() Option {
+	return func(s *S3) error {
+		if s.sse == s3.ServerSideEncryptionAwsKms {
+			return fmt.Errorf("b2 does not support SSE-KMS, use WithSSES3 or WithSSECustomerKey")
+		}
+
+		s.storageClass = ""
+		s.tagging = ""
+
+		return nil
+	}
+}
+
+func b2Endpoint(region string) string {
+	return fmt.Sprintf("https://s3.%s.backblazeb2.com", region)
+}
@@ -0,0 +1,113 @@
+package s3
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/sputnik-systems/backups-storage"
+)
+
+// requestURL returns url of object request of storage.
+func requestURL(t *testing.T, st storage.Storage) string {
+	t.Helper()
+
+	s := st.(*S3)
+	req, _ := s.c.GetObjectRequest(s.getObjectInput(s.key("db.sql")))
+	if err := req.Build(); err != nil {
+		t.Fatal(err)
+	}
+
+	return req.HTTPRequest.URL.String()
+}
+
+func TestSpacesStorage(t *testing.T) {
+	for region, want := range map[string]string{
+		"nyc3": "https://bucket.nyc3.digitaloceanspaces.com/backups/db.sql",
+		"fra1": "https://bucket.fra1.digitaloceanspaces.com/backups/db.sql",
+	} {
+		s, err := NewSpacesStorage(region, "key", "secret", "bucket", "backups")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := requestURL(t, s); got != want {
+			t.Errorf("%s: request to %s, want %s", region, got, want)
+		}
+	}
+
+	if _, err := NewSpacesStorage("", "key", "secret", "bucket", "backups"); err == nil {
+		t.Error("empty region accepted")
+	}
+}
+
+func TestWasabiStorage(t *testing.T) {
+	for region, want := range map[string]string{
+		"us-east-1":    "https://bucket.s3.us-east-1.wasabisys.com/backups/db.sql",
+		"eu-central-1": "https://bucket.s3.eu-central-1.wasabisys.com/backups/db.sql",
+	} {
+		s, err := NewWasabiStorage(region, "key", "secret", "bucket", "backups")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := requestURL(t, s); got != want {
+			t.Errorf("%s: request to %s, want %s", region, got, want)
+		}
+
+		if got := aws.StringValue(s.(*S3).c.Config.Region); got != region {
+			t.Errorf("%s: signing region %s", region, got)
+		}
+	}
+
+	if _, err := NewWasabiStorage("", "key", "secret", "bucket", "backups"); err == nil {
+		t.Error("empty region accepted")
+	}
+}
+
+func TestB2Storage(t *testing.T) {
+	s, err := NewB2Storage("us-west-004", "id", "key", "bucket", "backups")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := requestURL(t, s), "https://bucket.s3.us-west-004.backblazeb2.com/backups/db.sql"; got != want {
+		t.Errorf("request to %s, want %s", got, want)
+	}
+
+	// rejected settings are dropped whatever the order of options
+	opts := []Option{WithStorageClass(s3.StorageClassStandardIa), WithTags(map[string]string{"env": "prod"}), WithSSES3()}
+	s, err = NewB2Storage("us-west-004", "id", "key", "bucket", "backups", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := s.(*S3).putObjectInput("a")
+	if in.StorageClass != nil || in.Tagging != nil {
+		t.Errorf("storage class %v and tagging %v are sent", in.StorageClass, in.Tagging)
+	}
+
+	if aws.StringValue(in.ServerSideEncryption) != s3.ServerSideEncryptionAes256 {
+		t.Errorf("encryption %v", in.ServerSideEncryption)
+	}
+
+	// caller options are not modified by appended quirks
+	opts = make([]Option, 1, 2)
+	opts[0] = WithSSES3()
+	if _, err := NewB2Storage("us-west-004", "id", "key", "bucket", "backups", opts...); err != nil {
+		t.Fatal(err)
+	}
+
+	if opts[:2][1] != nil {
+		t.Error("quirks are appended to caller options")
+	}
+
+	if _, err := NewB2Storage("us-west-004", "id", "key", "bucket", "backups", WithSSEKMS("")); err == nil {
+		t.Error("sse-kms accepted")
+	}
+
+	if _, err := NewB2Storage("", "id", "key", "bucket", "backups"); err == nil {
+		t.Error("empty region accepted")
+	}
+}
@@ -0,0 +1,7 @@
+//go:build race
+
+package s3
+
+// raceEnabled is set when the race detector, which makes sync.Pool drop
+// buffers at random, is on.
+const raceEnabled = true
@@ -0,0 +1,40 @@
+package s3
+
+import (
+	"context"
+	"io"
+
+	"golang.org/x/time/rate"
+)
+
+// rateReader blocks reads until the limiter shared by all transfers of
+// storage allows them.
+type rateReader struct {
+	ctx context.Context
+	r   io.Reader
+	l   *rate.Limiter
+}
+
+func (s *S3) rateLimited(ctx context.Context, r io.Reader) io.Reader {
+	if s.limiter == nil {
+		return r
+	}
+
+	return &rateReader{ctx, r, s.limiter}
+}
+
+func (r *rateReader) Read(p []byte) (int, error) {
+	// limiter does not allow waiting for more than burst at once
+	if len(p) > r.l.Burst() {
+		p = p[:r.l.Burst()]
+	}
+
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if werr := r.l.WaitN(r.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+
+	return n, err
+}
@@ -0,0 +1,45 @@
+package s3
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	const rate = 4 << 20
+
+	// burst of the limiter passes at once, the rest at the rate
+	bound := func(size int64) time.Duration {
+		return time.Duration(float64(size-rate) / rate * float64(time.Second))
+	}
+
+	// concurrent parts share the limit
+	s, f := newTestStorage(t, WithPartSize(minPartSize), WithConcurrency(2), WithRateLimit(rate))
+	data := randomBytes(t, 2*minPartSize)
+
+	start := time.Now()
+	if err := s.Upload("db.sql", bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	if d := time.Since(start); d < bound(int64(len(data))) {
+		t.Errorf("uploaded %d bytes in %s, want at least %s", len(data), d, bound(int64(len(data))))
+	}
+
+	s = f.storage(WithRateLimit(rate))
+	start = time.Now()
+
+	var buf bytes.Buffer
+	if err := s.DownloadRange("db.sql", 0, 6<<20, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if d := time.Since(start); d < bound(int64(buf.Len())) {
+		t.Errorf("downloaded %d bytes in %s, want at least %s", buf.Len(), d, bound(int64(buf.Len())))
+	}
+
+	if _, err := NewStorage(f.sess, testBucket, "", WithRateLimit(0)); err == nil {
+		t.Error("rate limit 0 accepted")
+	}
+}
@@ -0,0 +1,118 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/sputnik-systems/backups-storage"
+)
+
+type RestoreState struct {
+	// Archived is set for objects in GLACIER and DEEP_ARCHIVE classes
+	Archived   bool
+	InProgress bool
+	Restored   bool
+	// Expiry is the time restored copy is removed
+	Expiry time.Time
+}
+
+// Restore requests temporary copy of archived object for days, tier is one
+// of Expedited, Standard or Bulk, empty tier stands for Standard. Repeated
+// requests for restore in progress succeed.
+func (s *S3) Restore(name string, days int, tier string) error {
+	if days < 1 {
+		return fmt.Errorf("invalid restore days %d", days)
+	}
+
+	if tier == "" {
+		tier = s3.TierStandard
+	}
+
+	valid := false
+	for _, v := range s3.Tier_Values() {
+		valid = valid || tier == v
+	}
+
+	if !valid {
+		return fmt.Errorf("unknown restore tier %q", tier)
+	}
+
+	key, err := storage.ObjectKey(s.prefix, name)
+	if err != nil {
+		return err
+	}
+
+	in := &s3.RestoreObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		RestoreRequest: &s3.RestoreRequest{
+			Days: aws.Int64(int64(days)),
+			GlacierJobParameters: &s3.GlacierJobParameters{
+				Tier: aws.String(tier),
+			},
+		},
+	}
+
+	_, err = s.c.RestoreObjectWithContext(context.Background(), in)
+	if err != nil {
+		if rerr, ok := err.(awserr.RequestFailure); ok && rerr.StatusCode() == http.StatusConflict {
+			return nil
+		}
+
+		if isNotFound(err) {
+			return fmt.Errorf("%s: %w", key, storage.ErrNotFound)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// RestoreStatus returns whether the object is archived and the state of
+// its restore.
+func (s *S3) RestoreStatus(name string) (RestoreState, error) {
+	key, err := storage.ObjectKey(s.prefix, name)
+	if err != nil {
+		return RestoreState{}, err
+	}
+
+	o, err := s.c.HeadObject(s.headObjectInput(key))
+	if err != nil {
+		if isNotFound(err) {
+			return RestoreState{}, fmt.Errorf("%s: %w", key, storage.ErrNotFound)
+		}
+
+		return RestoreState{}, s.sseCustomerKeyError(key, err)
+	}
+
+	class := aws.StringValue(o.StorageClass)
+	state := RestoreState{
+		Archived: class == s3.StorageClassGlacier || class == s3.StorageClassDeepArchive,
+	}
+
+	// header looks like: ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"
+	restore := aws.StringValue(o.Restore)
+	switch {
+	case strings.Contains(restore, `ongoing-request="true"`):
+		state.InProgress = true
+	case strings.Contains(restore, `ongoing-request="false"`):
+		state.Restored = true
+
+		if i := strings.Index(restore, `expiry-date="`); i >= 0 {
+			v := restore[i+len(`expiry-date="`):]
+			if j := strings.Index(v, `"`); j >= 0 {
+				state.Expiry, _ = http.ParseTime(v[:j])
+			}
+		}
+	}
+
+	return state, nil
+}
@@ -0,0 +1,117 @@
+package s3
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/sputnik-systems/backups-storage"
+)
+
+// archived answers HeadObject with the storage class and restore header.
+func archived(class, restore string) func(w http.ResponseWriter, r *http.Request, op string) bool {
+	return func(w http.ResponseWriter, r *http.Request, op string) bool {
+		if op != "HeadObject" {
+			return false
+		}
+
+		w.Header().Set("Content-Length", "4")
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.Header().Set("X-Amz-Storage-Class", class)
+		if restore != "" {
+			w.Header().Set("X-Amz-Restore", restore)
+		}
+
+		return true
+	}
+}
+
+func TestRestoreStatus(t *testing.T) {
+	s, f := newTestStorage(t)
+	expiry := time.Date(2021, 12, 21, 0, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		name, class, restore string
+		want                 RestoreState
+	}{
+		{"standard", s3.StorageClassStandard, "", RestoreState{}},
+		{"archived", s3.StorageClassGlacier, "", RestoreState{Archived: true}},
+		{"deep archive", s3.StorageClassDeepArchive, "", RestoreState{Archived: true}},
+		{"in progress", s3.StorageClassGlacier, `ongoing-request="true"`, RestoreState{Archived: true, InProgress: true}},
+		{"restored", s3.StorageClassGlacier, `ongoing-request="false", expiry-date="Tue, 21 Dec 2021 00:00:00 GMT"`,
+			RestoreState{Archived: true, Restored: true, Expiry: expiry}},
+	} {
+		f.setHook(archived(tc.class, tc.restore))
+
+		state, err := s.RestoreStatus("db.sql")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if state != tc.want {
+			t.Errorf("%s: state %+v, want %+v", tc.name, state, tc.want)
+		}
+	}
+
+	f.setHook(nil)
+	if _, err := s.RestoreStatus("missing"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("status of missing object: %v", err)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	s, f := newTestStorage(t)
+
+	var body string
+	status := http.StatusAccepted
+	f.setHook(func(w http.ResponseWriter, r *http.Request, op string) bool {
+		if op != "RestoreObject" {
+			return false
+		}
+
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		w.WriteHeader(status)
+
+		return true
+	})
+
+	if err := s.Restore("db.sql", 3, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if c := f.calls("RestoreObject"); len(c) != 1 || c[0].key != "backups/db.sql" {
+		t.Errorf("restore requests %+v", c)
+	}
+
+	if !strings.Contains(body, "<Days>3</Days>") || !strings.Contains(body, "<Tier>Standard</Tier>") {
+		t.Errorf("restore request %s", body)
+	}
+
+	// restore already in progress
+	status = http.StatusConflict
+	if err := s.Restore("db.sql", 3, s3.TierBulk); err != nil {
+		t.Errorf("repeated restore: %v", err)
+	}
+
+	if !strings.Contains(body, "<Tier>Bulk</Tier>") {
+		t.Errorf("restore request %s", body)
+	}
+
+	for _, tc := range []struct {
+		days int
+		tier string
+	}{
+		{0, ""},
+		{1, "Fast"},
+	} {
+		if err := s.Restore("db.sql", tc.days, tc.tier); err == nil {
+			t.Errorf("restore for %d days of tier %q accepted", tc.days, tc.tier)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/sputnik-systems/backups-storage"
+)
+
+// ranges are retried at least this many times, regardless of WithRetry
+const minRangeAttempts = 5
+
+// readError marks failures of reading response body, e.g. connection
+// resets, which sdk does not consider retryable.
+type readError struct {
+	err error
+}
+
+func (e *readError) Error() string { return e.err.Error() }
+
+func (e *readError) Unwrap() error { return e.err }
+
+type bodyReadErrors struct {
+	r io.Reader
+}
+
+func (r bodyReadErrors) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil && err != io.EOF {
+		err = &readError{err}
+	}
+
+	return n, err
+}
+
+// DownloadResumable downloads object into w by ranges of part size, see
+// WithPartSize. Failed ranges are downloaded again on their own, so a
+// connection reset does not restart download of a large object. Object
+// changed during download fails it, as ranges are requested by its etag.
+func (s *S3) DownloadResumable(name string, w io.WriterAt) error {
+	ctx := context.Background()
+	key, err := storage.ObjectKey(s.prefix, name)
+	if err != nil {
+		return err
+	}
+
+	o, err := s.c.HeadObjectWithContext(ctx, s.headObjectInput(key))
+	if err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("%s: %w", key, storage.ErrNotFound)
+		}
+
+		return s.sseCustomerKeyError(key, err)
+	}
+
+	size := aws.Int64Value(o.ContentLength)
+	attempts := max(s.maxAttempts, minRangeAttempts)
+	retryable := func(err error) bool {
+		var rerr *readError
+
+		return errors.As(err, &rerr) || isRetryable(err)
+	}
+
+	for offset := int64(0); offset < size; offset += s.partSize {
+		end := min(offset+s.partSize, size) - 1
+
+		err := s.retryWith(ctx, attempts, retryable, func() error {
+			in := s.getObjectInput(key)
+			in.Range = aws.String(fmt.Sprintf("bytes=%d-%d", offset, end))
+			in.IfMatch = o.ETag
+
+			r, err := s.c.GetObjectWithContext(ctx, in)
+			if err != nil {
+				return s.getObjectError(key, err)
+			}
+			defer r.Body.Close()
+
+			// retried range overwrites what its failed attempt wrote
+			n, err := io.Copy(io.NewOffsetWriter(w, offset), bodyReadErrors{s.rateLimited(ctx, r.Body)})
+			if err == nil && n != end-offset+1 {
+				err = &readError{fmt.Errorf("short range %d-%d, got %d bytes", offset, end, n)}
+			}
+
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("%s: range %d-%d: %w", key, offset, end, err)
+		}
+
+		s.reportProgress(end+1, size)
+	}
+	s.log.Debugf("downloaded %s by ranges, %d bytes", key, size)
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package s3
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sputnik-systems/backups-storage"
+)
+
+// resetRange breaks connection once in the middle of the range starting at
+// offset after writing garbage.
+func resetRange(offset, length int64) func(w http.ResponseWriter, r *http.Request, op string) bool {
+	var once sync.Once
+	return func(w http.ResponseWriter, r *http.Request, op string) bool {
+		if op != "GetObject" || r.Header.Get("Range") != fmt.Sprintf("bytes=%d-%d", offset, offset+length-1) {
+			return false
+		}
+
+		failed := false
+		once.Do(func() { failed = true })
+		if !failed {
+			return false
+		}
+
+		w.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/*", offset, offset+length-1))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(bytes.Repeat([]byte("x"), int(length/2)))
+		w.(http.Flusher).Flush()
+
+		panic(http.ErrAbortHandler)
+	}
+}
+
+func TestDownloadResumable(t *testing.T) {
+	s, f := newTestStorage(t, WithPartSize(minPartSize))
+	data := randomBytes(t, 3*minPartSize-100)
+	f.put("backups/db.sql", data)
+	f.setHook(resetRange(minPartSize, minPartSize))
+
+	out, err := os.Create(filepath.Join(t.TempDir(), "db.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	if err := s.DownloadResumable("db.sql", out); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, data) {
+		t.Fatalf("downloaded %d bytes differ from %d bytes", len(got), len(data))
+	}
+
+	// only the broken range is requested again
+	ranges := make(map[string]int)
+	for _, c := range f.calls("GetObject") {
+		ranges[c.header.Get("Range")]++
+
+		if c.header.Get("If-Match") == "" {
+			t.Errorf("range %s requested without etag", c.header.Get("Range"))
+		}
+	}
+
+	want := map[string]int{
+		fmt.Sprintf("bytes=0-%d", minPartSize-1):                 1,
+		fmt.Sprintf("bytes=%d-%d", minPartSize, 2*minPartSize-1): 2,
+		fmt.Sprintf("bytes=%d-%d", 2*minPartSize, len(data)-1):   1,
+	}
+	if fmt.Sprint(ranges) != fmt.Sprint(want) {
+		t.Errorf("requested ranges %v, want %v", ranges, want)
+	}
+}
+
+func TestDownloadResumableFailure(t *testing.T) {
+	s, f := newTestStorage(t, WithPartSize(minPartSize), WithRetry(1, time.Millisecond))
+	f.put("backups/db.sql", randomBytes(t, 2*minPartSize))
+
+	var buf writerAt
+	if err := s.DownloadResumable("missing", &buf); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("download of missing object: %v", err)
+	}
+
+	// ranges are retried more than configured for other requests
+	f.setHook(failTimes("GetObject", minRangeAttempts-1, http.StatusServiceUnavailable))
+	if err := s.DownloadResumable("db.sql", &buf); err != nil {
+		t.Errorf("download with %d failed attempts: %v", minRangeAttempts-1, err)
+	}
+
+	f.setHook(failTimes("GetObject", minRangeAttempts, http.StatusServiceUnavailable))
+	if err := s.DownloadResumable("db.sql", &buf); err == nil {
+		t.Error("download succeeded after all attempts of range failed")
+	}
+}
+
+// writerAt is in memory io.WriterAt.
+type writerAt struct {
+	mu sync.Mutex
+	b  []byte
+}
+
+func (w *writerAt) WriteAt(p []byte, off int64) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if end := off + int64(len(p)); end > int64(len(w.b)) {
+		w.b = append(w.b, make([]byte, end-int64(len(w.b)))...)
+	}
+
+	return copy(w.b[off:], p), nil
+}
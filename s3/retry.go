@@ -0,0 +1,69 @@
+package s3
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// maxRetryDelay caps the exponential backoff of retry.
+const maxRetryDelay = 30 * time.Second
+
+// retry calls fn until it succeeds with a non retryable error or attempts
+// are exhausted, sleeping with exponential backoff and jitter in between.
+func (s *S3) retry(ctx context.Context, fn func() error) error {
+	return s.retryWith(ctx, s.maxAttempts, isRetryable, fn)
+}
+
+// retryWith is retry with own number of attempts and retryable errors.
+func (s *S3) retryWith(ctx context.Context, maxAttempts int, retryable func(error) bool, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= maxAttempts || !retryable(err) {
+			return err
+		}
+
+		d := s.backoff(attempt)
+		s.log.Warnf("attempt %d of %d failed, retrying in %s: %v", attempt, maxAttempts, d, err)
+
+		t := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// backoff returns delay after the failed attempt, which is jittered over the
+// upper half of the exponential step.
+func (s *S3) backoff(attempt int) time.Duration {
+	d := maxRetryDelay
+	if shift := uint(attempt - 1); shift < 32 && s.retryBase < maxRetryDelay>>shift {
+		d = s.retryBase << shift
+	}
+
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func isRetryable(err error) bool {
+	if request.IsErrorRetryable(err) || request.IsErrorThrottle(err) {
+		return true
+	}
+
+	if rerr, ok := err.(awserr.RequestFailure); ok {
+		switch rerr.StatusCode() {
+		case http.StatusInternalServerError, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,113 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// failTimes fails the first n requests of the operation with status.
+func failTimes(op string, n, status int) func(w http.ResponseWriter, r *http.Request, op string) bool {
+	var mu sync.Mutex
+	return func(w http.ResponseWriter, r *http.Request, o string) bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if o != op || n == 0 {
+			return false
+		}
+		n--
+
+		w.WriteHeader(status)
+
+		return true
+	}
+}
+
+func TestRetry(t *testing.T) {
+	for _, op := range []string{"PutObject", "UploadPart", "GetObject", "DeleteObjects"} {
+		s, f := newTestStorage(t, WithPartSize(minPartSize), WithRetry(3, time.Millisecond))
+		f.put("backups/existing", []byte("data"))
+		f.setHook(failTimes(op, 2, http.StatusServiceUnavailable))
+
+		var err error
+		switch op {
+		case "PutObject":
+			err = s.Upload("a", strings.NewReader("data"))
+		case "UploadPart":
+			err = s.Upload("a", bytes.NewReader(randomBytes(t, 2*minPartSize)))
+		case "GetObject":
+			err = s.Download("existing", &bytes.Buffer{})
+		case "DeleteObjects":
+			err = s.Delete("existing")
+		}
+
+		if err != nil {
+			t.Errorf("%s: %v", op, err)
+		}
+
+		if n := len(f.calls(op)); n < 3 {
+			t.Errorf("%s: %d attempts, want at least 3", op, n)
+		}
+	}
+}
+
+func TestRetryExhausted(t *testing.T) {
+	s, f := newTestStorage(t, WithRetry(3, time.Millisecond))
+	f.setHook(failTimes("PutObject", 3, http.StatusServiceUnavailable))
+
+	if err := s.Upload("a", strings.NewReader("data")); err == nil {
+		t.Fatal("upload succeeded after all attempts failed")
+	}
+
+	if n := len(f.calls("PutObject")); n != 3 {
+		t.Errorf("%d attempts, want 3", n)
+	}
+}
+
+func TestRetryNotRetryable(t *testing.T) {
+	s, f := newTestStorage(t, WithRetry(3, time.Millisecond))
+	f.setHook(failTimes("PutObject", 3, http.StatusForbidden))
+
+	if err := s.Upload("a", strings.NewReader("data")); err == nil {
+		t.Fatal("forbidden upload succeeded")
+	}
+
+	if n := len(f.calls("PutObject")); n != 1 {
+		t.Errorf("%d attempts of forbidden upload, want 1", n)
+	}
+}
+
+func TestRetryCanceled(t *testing.T) {
+	s, f := newTestStorage(t, WithRetry(3, time.Hour))
+	f.setHook(failTimes("PutObject", 3, http.StatusServiceUnavailable))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := s.UploadContext(ctx, "a", strings.NewReader("data"))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if d := time.Since(start); d > 10*time.Second {
+		t.Errorf("canceled backoff took %s", d)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	s := newFakeS3(t).storage(WithRetry(100, 100*time.Millisecond))
+
+	for attempt := 1; attempt <= 100; attempt++ {
+		step := min(s.retryBase<<min(attempt-1, 20), maxRetryDelay)
+		if d := s.backoff(attempt); d < step/2 || d > step || d > maxRetryDelay {
+			t.Errorf("attempt %d: backoff %s out of [%s, %s]", attempt, d, step/2, step)
+		}
+	}
+}
@@ -1,90 +1,336 @@
 package s3
 
 import (
-	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
 	"io"
+	"mime"
 	"net/http"
+	"net/url"
 	"path"
 	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/sputnik-systems/backups-storage"
+	"golang.org/x/time/rate"
 )
 
 type S3 struct {
 	c              *s3.S3
 	bucket, prefix string
 	partSize       int64
+	progress       func(int64, int64)
+	maxAttempts    int
+	retryBase      time.Duration
+	concurrency    int
+	checksum       bool
+	sse            string
+	sseKMSKeyID    string
+
+	sseCustomerKey, sseCustomerKeyMD5 string
+
+	storageClass string
+	tagging      string
+	metadata     map[string]string
+	limiter      *rate.Limiter
+	log          Logger
+	dryRun       bool
+	noOverwrite  bool
+	noETagCheck  bool
+	contentType  string
+	acl          string
+
+	lockMode        string
+	lockRetainUntil time.Time
+
+	httpClient *http.Client
+
+	requesterPays bool
+	bucketOwner   string
+	opTimeout     time.Duration
+
+	// part buffers shared by uploads and downloads, see Sub
+	buffers *sync.Pool
+}
+
+const checksumKey = "sha256"
+
+// DeleteError is returned when S3 refused to delete some of objects, e.g.
+// because of access policy or object lock.
+type DeleteError struct {
+	Objects []ObjectError
+}
+
+type ObjectError struct {
+	Key     string
+	Code    string
+	Message string
+}
+
+func (e *DeleteError) Error() string {
+	msgs := make([]string, 0, len(e.Objects))
+	for _, o := range e.Objects {
+		msgs = append(msgs, fmt.Sprintf("%s: %s: %s", o.Key, o.Code, o.Message))
+	}
+
+	return fmt.Sprintf("failed to delete %d objects: %s", len(e.Objects), strings.Join(msgs, "; "))
+}
+
+// UploadResult describes object stored by upload, version id is set only
+// in versioned buckets.
+type UploadResult struct {
+	Key       string
+	ETag      string
+	VersionID string
+	Size      int64
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
 }
 
 type FileInfo struct {
-	name  string
-	size  int64
-	mtime time.Time
-	isdir bool
+	name     string
+	size     int64
+	mtime    time.Time
+	isdir    bool
+	metadata map[string]string
 }
 
-func NewStorage(sess *session.Session, bucket, prefix string) storage.Storage {
+func NewStorage(sess *session.Session, bucket, prefix string, opts ...Option) (storage.Storage, error) {
 	partSize := int64(100 * 1024 * 1024)
 
-	return &S3{
-		c:        s3.New(sess),
+	s := &S3{
 		bucket:   bucket,
 		prefix:   prefix,
 		partSize: partSize,
+
+		maxAttempts: 1,
+		retryBase:   100 * time.Millisecond,
+		concurrency: 1,
+		log:         nopLogger{},
+		buffers:     &sync.Pool{},
+	}
+
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+
+	// client depends on options, e.g. concurrency
+	s.c = s3.New(sess, aws.NewConfig().WithHTTPClient(s.httpClientFor(sess)))
+	s.c.Handlers.Build.PushBack(s.setRequestHeaders)
+	s.c.Handlers.Validate.PushBack(s.setTimeout)
+
+	return s, nil
+}
+
+// Sub returns storage of the same bucket and options rooted at prefix
+// under the storage prefix, prefixes escaping it are rejected.
+func (s *S3) Sub(prefix string) (storage.Storage, error) {
+	key, err := storage.ObjectKey(s.prefix, prefix)
+	if err != nil {
+		return nil, err
 	}
+
+	sub := *s
+	sub.prefix = key
+
+	return &sub, nil
 }
 
 func (s *S3) List() ([]storage.FileInfo, error) {
-	return s.list(s.prefix)
+	return s.ListContext(context.Background())
 }
 
-func (s *S3) list(prefix string) ([]storage.FileInfo, error) {
+func (s *S3) ListContext(ctx context.Context) ([]storage.FileInfo, error) {
+	return s.list(ctx, s.key(""))
+}
+
+// ListFunc calls fn for every object with the name prefix as pages arrive,
+// without synthesized directories. Listing stops on the first fn error,
+// which is returned.
+func (s *S3) ListFunc(prefix string, fn func(storage.FileInfo) error) error {
+	return s.listFunc(context.Background(), s.key(prefix), fn)
+}
+
+func (s *S3) listFunc(ctx context.Context, prefix string, fn func(storage.FileInfo) error) error {
 	in := &s3.ListObjectsV2Input{
-		Bucket: aws.String(s.bucket),
-		Prefix: aws.String(prefix),
+		Bucket:       aws.String(s.bucket),
+		Prefix:       aws.String(prefix),
+		EncodingType: aws.String(s3.EncodingTypeUrl),
 	}
 
-	fi := make([]storage.FileInfo, 0)
-	err := s.c.ListObjectsV2Pages(in, func(page *s3.ListObjectsV2Output, last bool) bool {
+	var ferr error
+	err := s.c.ListObjectsV2PagesWithContext(ctx, in, func(page *s3.ListObjectsV2Output, last bool) bool {
 		for _, o := range page.Contents {
-			fi = append(fi, &FileInfo{*o.Key, *o.Size, *o.LastModified, false})
+			if ferr = fn(&FileInfo{s.relName(listedKey(page.EncodingType, o.Key)), *o.Size, *o.LastModified, false, nil}); ferr != nil {
+				return false
+			}
 		}
 
 		return !last
 	})
+	if err != nil {
+		return err
+	}
+
+	return ferr
+}
+
+func (s *S3) list(ctx context.Context, prefix string) ([]storage.FileInfo, error) {
+	fi := make([]storage.FileInfo, 0)
+	err := s.listFunc(ctx, prefix, func(f storage.FileInfo) error {
+		fi = append(fi, f)
+
+		return nil
+	})
+	if err != nil {
+		return fi, err
+	}
+
+	return withDirs(fi), nil
+}
+
+// ListPage returns a page of at most maxKeys objects with the name prefix
+// starting from the token, along with the token of the next page, which is
+// empty for the last one. Directories are synthesized of the page objects.
+func (s *S3) ListPage(prefix, token string, maxKeys int) ([]storage.FileInfo, string, error) {
+	in := &s3.ListObjectsV2Input{
+		Bucket:       aws.String(s.bucket),
+		Prefix:       aws.String(s.key(prefix)),
+		EncodingType: aws.String(s3.EncodingTypeUrl),
+	}
+	if token != "" {
+		in.ContinuationToken = aws.String(token)
+	}
+	if maxKeys > 0 {
+		in.MaxKeys = aws.Int64(int64(maxKeys))
+	}
+
+	page, err := s.c.ListObjectsV2WithContext(context.Background(), in)
+	if err != nil {
+		return nil, "", err
+	}
+
+	fi := make([]storage.FileInfo, 0, len(page.Contents))
+	for _, o := range page.Contents {
+		fi = append(fi, &FileInfo{s.relName(listedKey(page.EncodingType, o.Key)), *o.Size, *o.LastModified, false, nil})
+	}
+
+	next := ""
+	if aws.BoolValue(page.IsTruncated) {
+		next = aws.StringValue(page.NextContinuationToken)
+	}
+
+	return withDirs(fi), next, nil
+}
+
+// withDirs adds directories of objects to them, keeping the latest mtime of
+// their objects, and sorts all by name in descending order.
+func withDirs(fi []storage.FileInfo) []storage.FileInfo {
+	di := make(map[string]*FileInfo)
+	for _, f := range fi {
+		// slice of the name does not allocate unlike path.Dir
+		dir := f.Name()[:strings.LastIndexByte(f.Name(), '/')+1]
+		if dir == "" {
+			continue
+		}
+
+		if d, ok := di[dir]; !ok {
+			di[dir] = &FileInfo{dir, int64(0), f.ModTime(), true, nil}
+		} else if d.mtime.Before(f.ModTime()) {
+			d.mtime = f.ModTime()
+		}
+	}
+
+	for _, d := range di {
+		fi = append(fi, d)
+	}
+
+	sort.Slice(fi, func(i, j int) bool {
+		return fi[i].Name() > fi[j].Name()
+	})
+
+	return fi
+}
+
+// ListGlob returns objects which names relative to the prefix match the
+// shell pattern, see path.Match for the syntax.
+func (s *S3) ListGlob(pattern string) ([]storage.FileInfo, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	// list only keys starting with literal part of pattern
+	literal := pattern
+	if i := strings.IndexAny(pattern, `*?[\`); i >= 0 {
+		literal = pattern[:i]
+	}
+
+	fi := make([]storage.FileInfo, 0)
+	err := s.listFunc(context.Background(), s.key(literal), func(f storage.FileInfo) error {
+		if ok, _ := path.Match(pattern, f.Name()); ok {
+			fi = append(fi, f)
+		}
+
+		return nil
+	})
 	if err != nil {
 		return fi, err
 	}
 
-	// sort by mtime dicreasing
 	sort.Slice(fi, func(i, j int) bool {
-		return fi[i].ModTime().Unix() < fi[j].ModTime().Unix()
+		return fi[i].Name() > fi[j].Name()
 	})
 
-	// calc directories
-	di := make([]storage.FileInfo, 0)
-	for _, o := range fi {
-		name := path.Dir(o.Name()) + "/"
-		di = append(di, &FileInfo{name, int64(0), o.ModTime(), true})
-	}
-	di = func(in []storage.FileInfo) []storage.FileInfo {
-		names := make(map[string]struct{})
-		out := make([]storage.FileInfo, 0)
-		for _, d := range in {
-			if _, ok := names[d.Name()]; !ok {
-				names[d.Name()] = struct{}{}
-				out = append(out, d)
-			}
+	return fi, nil
+}
+
+// ListDir returns only immediate children of the directory like ls does,
+// nested objects are collapsed into directory entries.
+func (s *S3) ListDir(name string) ([]storage.FileInfo, error) {
+	prefix := s.key(strings.Trim(name, "/"))
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	in := &s3.ListObjectsV2Input{
+		Bucket:       aws.String(s.bucket),
+		Prefix:       aws.String(prefix),
+		Delimiter:    aws.String("/"),
+		EncodingType: aws.String(s3.EncodingTypeUrl),
+	}
+
+	fi := make([]storage.FileInfo, 0)
+	err := s.c.ListObjectsV2PagesWithContext(context.Background(), in, func(page *s3.ListObjectsV2Output, last bool) bool {
+		for _, p := range page.CommonPrefixes {
+			fi = append(fi, &FileInfo{s.relName(listedKey(page.EncodingType, p.Prefix)), int64(0), time.Time{}, true, nil})
 		}
 
-		return out
-	}(di)
+		for _, o := range page.Contents {
+			fi = append(fi, &FileInfo{s.relName(listedKey(page.EncodingType, o.Key)), *o.Size, *o.LastModified, false, nil})
+		}
 
-	fi = append(fi, di...)
+		return !last
+	})
+	if err != nil {
+		return fi, err
+	}
 
 	sort.Slice(fi, func(i, j int) bool {
 		return fi[i].Name() > fi[j].Name()
@@ -94,175 +340,1350 @@ func (s *S3) list(prefix string) ([]storage.FileInfo, error) {
 }
 
 func (s *S3) Delete(name string) error {
-	prefix := path.Join(s.prefix, name)
-	fi, err := s.list(prefix)
-	if err != nil {
+	return s.DeleteContext(context.Background(), name)
+}
+
+func (s *S3) DeleteContext(ctx context.Context, name string) error {
+	if err := storage.ValidateName(name); err != nil {
 		return err
 	}
 
-	oi := make([]*s3.ObjectIdentifier, 0)
-	for _, o := range fi {
-		oi = append(oi, &s3.ObjectIdentifier{Key: aws.String(o.Name())})
-	}
+	keys := make([]string, 0)
+	err := s.listFunc(ctx, s.key(strings.TrimSuffix(name, "/")), func(f storage.FileInfo) error {
+		keys = append(keys, s.key(f.Name()))
 
-	in := &s3.DeleteObjectsInput{
-		Bucket: aws.String(s.bucket),
-		Delete: &s3.Delete{
-			Objects: oi,
-		},
+		return nil
+	})
+	if err == nil {
+		err = s.deleteKeys(ctx, keys)
 	}
 
-	if _, err := s.c.DeleteObjects(in); err != nil {
-		return err
+	// missing objects are not an error, but missing bucket is
+	if isNotFound(err) {
+		return fmt.Errorf("%s: %w", name, storage.ErrNotFound)
 	}
 
-	return nil
+	return err
 }
 
-func (s *S3) Upload(name string, buf io.Reader) error {
-	var mupload *s3.CreateMultipartUploadOutput
-	var mparts []*s3.CompletedPart
-	var part *s3.CompletedPart
-	var err error
-
-	key := path.Join(s.prefix, name)
-	b := make([]byte, s.partSize)
-	for {
-		n, err := buf.Read(b)
+func (s *S3) DeleteBatch(names []string) error {
+	keys := make([]string, 0, len(names))
+	for _, name := range names {
+		key, err := storage.ObjectKey(s.prefix, name)
 		if err != nil {
-			if err == io.EOF {
-				break
-			} else {
-				return err
-			}
+			return err
 		}
 
-		b = b[:n]
+		keys = append(keys, key)
+	}
 
-		if int64(n) == s.partSize {
-			if mupload == nil {
-				contentType := http.DetectContentType(b)
+	return s.deleteKeys(context.Background(), keys)
+}
 
-				in := &s3.CreateMultipartUploadInput{
-					Bucket:      aws.String(s.bucket),
-					Key:         aws.String(key),
-					ContentType: aws.String(contentType),
-				}
+func (s *S3) deleteKeys(ctx context.Context, keys []string) error {
+	if s.dryRun {
+		for _, key := range keys {
+			s.log.Infof("dry run, not deleting %s", key)
+		}
 
-				mupload, err = s.c.CreateMultipartUpload(in)
-				if err != nil {
-					return err
-				}
+		return nil
+	}
 
-				mparts = make([]*s3.CompletedPart, 0)
-			}
+	derr := &DeleteError{}
 
-			part, err = s.uploadPart(key, mupload.UploadId, int64(len(mparts)+1), b)
-			if err != nil {
-				return err
-			}
+	for len(keys) > 0 {
+		n := len(keys)
+		if n > maxDeleteKeys {
+			n = maxDeleteKeys
+		}
 
-			mparts = append(mparts, part)
+		oi := make([]*s3.ObjectIdentifier, 0, n)
+		for _, key := range keys[:n] {
+			oi = append(oi, &s3.ObjectIdentifier{Key: aws.String(key)})
 		}
-	}
+		keys = keys[n:]
 
-	if mupload == nil {
-		in := &s3.PutObjectInput{
+		in := &s3.DeleteObjectsInput{
 			Bucket: aws.String(s.bucket),
-			Key:    aws.String(key),
-			Body:   bytes.NewReader(b),
+			Delete: &s3.Delete{
+				Objects: oi,
+				Quiet:   aws.Bool(true),
+			},
 		}
 
-		if _, err = s.c.PutObject(in); err != nil {
+		s.log.Debugf("deleting %d objects", len(oi))
+
+		var out *s3.DeleteObjectsOutput
+		err := s.retry(ctx, func() (err error) {
+			out, err = s.c.DeleteObjectsWithContext(ctx, in)
+
 			return err
-		}
-	} else {
-		part, err = s.uploadPart(key, mupload.UploadId, int64(len(mparts)+1), b)
+		})
 		if err != nil {
 			return err
 		}
 
-		mparts = append(mparts, part)
-
-		in := &s3.CompleteMultipartUploadInput{
-			Bucket:   aws.String(s.bucket),
-			Key:      aws.String(key),
-			UploadId: mupload.UploadId,
-			MultipartUpload: &s3.CompletedMultipartUpload{
-				Parts: mparts,
-			},
+		// failed keys are reported in response body with 200 status
+		for _, e := range out.Errors {
+			s.log.Warnf("failed to delete %s: %s", aws.StringValue(e.Key), aws.StringValue(e.Message))
+			derr.Objects = append(derr.Objects, ObjectError{
+				Key:     aws.StringValue(e.Key),
+				Code:    aws.StringValue(e.Code),
+				Message: aws.StringValue(e.Message),
+			})
 		}
+	}
 
-		if _, err = s.c.CompleteMultipartUpload(in); err != nil {
-			return err
-		}
+	if len(derr.Objects) > 0 {
+		return derr
 	}
 
 	return nil
 }
 
-func (s *S3) Download(name string, buf io.Writer) error {
-	key := path.Join(s.prefix, name)
+func (s *S3) Upload(name string, buf io.Reader) error {
+	return s.UploadContext(context.Background(), name, buf)
+}
 
-	in := &s3.GetObjectInput{
-		Bucket: aws.String(s.bucket),
-		Key:    aws.String(key),
-	}
+func (s *S3) UploadContext(ctx context.Context, name string, buf io.Reader) error {
+	_, err := s.upload(ctx, name, buf)
+
+	return err
+}
+
+// UploadWithResult uploads like Upload and returns the stored object.
+func (s *S3) UploadWithResult(name string, buf io.Reader) (UploadResult, error) {
+	return s.upload(context.Background(), name, buf)
+}
+
+func (s *S3) upload(ctx context.Context, name string, buf io.Reader) (res UploadResult, err error) {
+	var mupload *s3.CreateMultipartUploadOutput
+	var mparts []*s3.CompletedPart
+	var sums map[int64][]byte
+	var done int64
+	var contentType string
 
-	o, err := s.c.GetObject(in)
+	key, err := storage.ObjectKey(s.prefix, name)
 	if err != nil {
-		return err
+		return res, err
 	}
 
-	b := make([]byte, s.partSize)
-	for {
-		n, err := o.Body.Read(b)
-		if err != nil {
-			if err == io.EOF {
-				break
-			} else {
-				return err
+	// parts are uploaded in background, first failed part stops the rest
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var perr error
+	pctx, cancel := context.WithCancel(ctx)
+	sem := make(chan struct{}, s.concurrency)
+	start := time.Now()
+
+	// do not leave orphaned parts of a failed upload in bucket
+	defer func() {
+		cancel()
+		wg.Wait()
+
+		// report cancellation instead of the request error it caused
+		if err != nil && ctx.Err() != nil {
+			err = ctx.Err()
+		}
+
+		if err != nil && mupload != nil {
+			s.log.Warnf("aborting multipart upload of %s: %v", key, err)
+
+			// upload context may be already canceled here
+			if aerr := s.abortUpload(context.Background(), key, mupload.UploadId); aerr != nil {
+				err = fmt.Errorf("%w (abort multipart upload: %v)", err, aerr)
 			}
 		}
 
-		b = b[:n]
+		if err == nil {
+			s.log.Debugf("uploaded %s in %s", key, time.Since(start))
+		}
+	}()
 
-		n, err = buf.Write(b)
+	// not every s3 compatible storage supports conditional writes, so the
+	// existence is checked upfront as well, the race is closed only by s3
+	if s.noOverwrite {
+		ok, err := s.exists(ctx, key)
 		if err != nil {
-			return err
+			return res, err
+		}
+
+		if ok {
+			return res, fmt.Errorf("%s: %w", key, storage.ErrAlreadyExists)
 		}
 	}
 
-	return nil
-}
+	size, err := readerSize(buf)
+	if err != nil {
+		return res, err
+	}
 
-func (s *S3) uploadPart(key string, uploadId *string, partNumber int64, body []byte) (*s3.CompletedPart, error) {
-	contentLength := int64(len(body))
+	partSize, err := s.partSizeFor(size)
+	if err != nil {
+		return res, err
+	}
 
-	pi := &s3.UploadPartInput{
-		Bucket:        aws.String(s.bucket),
-		Key:           aws.String(key),
-		UploadId:      uploadId,
-		Body:          bytes.NewReader(body),
-		PartNumber:    aws.Int64(partNumber),
-		ContentLength: aws.Int64(contentLength),
+	// objects of known small size, empty ones included, do not need a whole
+	// part buffer, an extra byte makes the read hit the end of stream
+	bufSize := partSize
+	if size >= 0 && size < partSize {
+		bufSize = size + 1
 	}
 
-	res, err := s.c.UploadPart(pi)
-	if err != nil {
-		return nil, err
+	// stream is read sequentially even with concurrent part uploads, so
+	// limiting it bounds the aggregate rate of parts
+	buf = s.rateLimited(ctx, buf)
+
+	var h hash.Hash
+	if s.checksum {
+		h = sha256.New()
+		buf = io.TeeReader(buf, h)
 	}
 
-	return &s3.CompletedPart{
-		ETag:       res.ETag,
-		PartNumber: aws.Int64(partNumber),
-	}, nil
-}
+	// parts complete in background, while progress is reported from this
+	// goroutine, so the callback is never called from another one
+	var reported int64
+	report := func() {
+		mu.Lock()
+		d := done
+		mu.Unlock()
 
-func (f *FileInfo) Name() string { return f.name }
+		if d != reported {
+			reported = d
+			s.reportProgress(d, size)
+		}
+	}
 
-func (f *FileInfo) Size() int64 { return f.size }
+	for partNumber := int64(1); ; partNumber++ {
+		if err = ctx.Err(); err != nil {
+			return res, err
+		}
 
-func (f *FileInfo) ModTime() time.Time { return f.mtime }
+		// wait for a free slot, so at most concurrency parts are in memory
+		sem <- struct{}{}
 
-func (f *FileInfo) IsDir() bool { return f.isdir }
+		mu.Lock()
+		err = perr
+		mu.Unlock()
+		if err != nil {
+			return res, err
+		}
+		report()
+
+		// fill the whole part, short reads are not the end of stream
+		b := s.getBuffer(bufSize)
+		n, rerr := io.ReadFull(buf, b)
+		if rerr != nil && rerr != io.EOF && rerr != io.ErrUnexpectedEOF {
+			return res, rerr
+		}
+		last := rerr != nil
+
+		// stream may be longer than its size, e.g. file growing while read,
+		// rest of the part is read to keep non-final parts of part size
+		if !last && int64(n) < partSize {
+			bufSize = partSize
+			pb := s.getBuffer(bufSize)
+			copy(pb, b[:n])
+			s.putBuffer(b)
+			b = pb
+
+			m, rerr := io.ReadFull(buf, b[n:])
+			if rerr != nil && rerr != io.EOF && rerr != io.ErrUnexpectedEOF {
+				return res, rerr
+			}
+			n += m
+			last = rerr != nil
+		}
+
+		if partNumber == 1 {
+			contentType = s.detectContentType(key, b[:n])
+		}
+
+		if mupload == nil {
+			if last {
+				metadata := checksumMetadata(h)
+
+				// whole body is in memory, so s3 can verify it
+				sum := md5.Sum(b[:n])
+				contentMD5 := base64.StdEncoding.EncodeToString(sum[:])
+
+				var out *s3.PutObjectOutput
+				err = s.retry(ctx, func() (err error) {
+					// body is consumed by each attempt
+					body := newBodyReader(b[:n])
+					defer body.release()
+
+					in := s.putObjectInput(key)
+					in.Body = body
+					in.ContentMD5 = aws.String(contentMD5)
+					in.ContentType = aws.String(contentType)
+					in.Metadata = mergeMetadata(in.Metadata, metadata)
+
+					out, err = s.c.PutObjectWithContext(ctx, in, s.writeOptions()...)
+
+					return err
+				})
+				s.putBuffer(b)
+				if err != nil {
+					return res, s.writeError(key, err)
+				}
+				s.log.Debugf("put %s, %d bytes", key, n)
+				s.reportProgress(int64(n), size)
+
+				res = UploadResult{key, aws.StringValue(out.ETag), aws.StringValue(out.VersionId), int64(n)}
+				// some servers do not return etag, which is md5 of body
+				// unless object is encrypted with kms or customer key
+				if out.ETag == nil && s.md5ETag() {
+					res.ETag = `"` + hex.EncodeToString(sum[:]) + `"`
+				}
+
+				return res, nil
+			}
+
+			in := s.createMultipartUploadInput(key)
+			in.ContentType = aws.String(contentType)
+
+			out, err := s.c.CreateMultipartUploadWithContext(ctx, in)
+			if err != nil {
+				return res, err
+			}
+			mupload = out
+			s.log.Debugf("started multipart upload of %s, part size %d", key, partSize)
+
+			mparts = make([]*s3.CompletedPart, 0)
+			sums = make(map[int64][]byte)
+		}
+
+		// stream size may be a multiple of part size, so the last read can be empty
+		if n == 0 {
+			s.putBuffer(b)
+			<-sem
+
+			break
+		}
+
+		if partNumber > maxParts {
+			return res, fmt.Errorf("upload %s exceeds %d parts of %d bytes, increase part size", key, maxParts, partSize)
+		}
+
+		wg.Add(1)
+		go func(partNumber int64, body []byte) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			part, sum, err := s.uploadPart(pctx, key, mupload.UploadId, partNumber, body)
+			s.putBuffer(body)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				if perr == nil {
+					perr = err
+					cancel()
+				}
+
+				return
+			}
+
+			mparts = append(mparts, part)
+			sums[partNumber] = sum
+			done += int64(len(body))
+		}(partNumber, b[:n])
+
+		if last {
+			break
+		}
+	}
+
+	wg.Wait()
+	if perr != nil {
+		return res, perr
+	}
+	report()
+
+	// parts complete in any order, but must be listed sequentially
+	sort.Slice(mparts, func(i, j int) bool {
+		return *mparts[i].PartNumber < *mparts[j].PartNumber
+	})
+
+	in := &s3.CompleteMultipartUploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(key),
+		UploadId: mupload.UploadId,
+		MultipartUpload: &s3.CompletedMultipartUpload{
+			Parts: mparts,
+		},
+	}
+
+	out, err := s.c.CompleteMultipartUploadWithContext(ctx, in, s.writeOptions()...)
+	if err != nil {
+		return res, s.writeError(key, err)
+	}
+	mupload = nil
+	res = UploadResult{key, aws.StringValue(out.ETag), aws.StringValue(out.VersionId), done}
+
+	if s.md5ETag() {
+		ordered := make([][]byte, 0, len(mparts))
+		for _, p := range mparts {
+			ordered = append(ordered, sums[*p.PartNumber])
+		}
+
+		// etag not returned is computed the way s3 does
+		expected := multipartETag(ordered)
+		if out.ETag == nil {
+			res.ETag = `"` + expected + `"`
+		} else if etag := strings.Trim(*out.ETag, `"`); etag != expected && !s.noETagCheck {
+			err := fmt.Errorf("%s: etag %s of completed upload, expected %s: %w", key, etag, expected, storage.ErrChecksumMismatch)
+
+			// corrupted object is already committed, version of it only
+			// in versioned buckets
+			di := &s3.DeleteObjectInput{
+				Bucket:    aws.String(s.bucket),
+				Key:       aws.String(key),
+				VersionId: out.VersionId,
+			}
+			if _, derr := s.c.DeleteObjectWithContext(context.Background(), di); derr != nil {
+				err = fmt.Errorf("%w (delete corrupted object: %v)", err, derr)
+			}
+
+			return res, err
+		}
+	}
+
+	// checksum is known only after the whole stream is read, while metadata
+	// of multipart upload is set at its creation, so object is copied in place
+	if h != nil {
+		cres, err := s.copy(ctx, key, key, checksumMetadata(h))
+		if err != nil {
+			return res, err
+		}
+
+		res.ETag, res.VersionID = cres.ETag, cres.VersionID
+	}
+
+	return res, nil
+}
+
+func (s *S3) Download(name string, buf io.Writer) error {
+	return s.DownloadContext(context.Background(), name, buf)
+}
+
+func (s *S3) DownloadContext(ctx context.Context, name string, buf io.Writer) error {
+	key, err := storage.ObjectKey(s.prefix, name)
+	if err != nil {
+		return err
+	}
+
+	in := s.getObjectInput(key)
+
+	return s.download(ctx, in, buf)
+}
+
+func (s *S3) DownloadRange(name string, offset, length int64, buf io.Writer) error {
+	if offset < 0 {
+		return fmt.Errorf("invalid range offset %d", offset)
+	}
+
+	key, err := storage.ObjectKey(s.prefix, name)
+	if err != nil {
+		return err
+	}
+
+	// non-positive length means up to the end of object
+	r := fmt.Sprintf("bytes=%d-", offset)
+	if length > 0 {
+		r = fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+	}
+
+	in := s.getObjectInput(key)
+	in.Range = aws.String(r)
+
+	return s.download(context.Background(), in, buf)
+}
+
+// DownloadIfModified downloads object only if it was modified after since,
+// otherwise false is returned and nothing is written.
+func (s *S3) DownloadIfModified(name string, since time.Time, buf io.Writer) (bool, error) {
+	key, err := storage.ObjectKey(s.prefix, name)
+	if err != nil {
+		return false, err
+	}
+
+	in := s.getObjectInput(key)
+	in.IfModifiedSince = aws.Time(since)
+
+	if err := s.download(context.Background(), in, buf); err != nil {
+		if rerr, ok := err.(awserr.RequestFailure); ok && rerr.StatusCode() == http.StatusNotModified {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Open returns object body to read from, it must be closed to release
+// connection. Unlike Download it does not verify checksum.
+func (s *S3) Open(name string) (io.ReadCloser, error) {
+	key, err := storage.ObjectKey(s.prefix, name)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	o, err := s.getObject(ctx, s.getObjectInput(key))
+	if err != nil {
+		return nil, err
+	}
+
+	return &readCloser{s.rateLimited(ctx, o.Body), o.Body}, nil
+}
+
+func (s *S3) getObject(ctx context.Context, in *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	var o *s3.GetObjectOutput
+	err := s.retry(ctx, func() (err error) {
+		o, err = s.c.GetObjectWithContext(ctx, in)
+
+		return err
+	})
+	if err != nil {
+		return nil, s.getObjectError(aws.StringValue(in.Key), err)
+	}
+
+	return o, nil
+}
+
+func (s *S3) getObjectError(key string, err error) error {
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeInvalidObjectState {
+		return fmt.Errorf("%s must be restored before download: %w", key, storage.ErrObjectArchived)
+	}
+
+	if isNotFound(err) {
+		return fmt.Errorf("%s: %w", key, storage.ErrNotFound)
+	}
+
+	return s.sseCustomerKeyError(key, err)
+}
+
+func (s *S3) download(ctx context.Context, in *s3.GetObjectInput, buf io.Writer) error {
+	o, err := s.getObject(ctx, in)
+	if err != nil {
+		return err
+	}
+
+	defer o.Body.Close()
+
+	total := int64(-1)
+	if o.ContentLength != nil {
+		total = *o.ContentLength
+	}
+
+	// only whole objects can be verified
+	var h hash.Hash
+	sum := metadataValue(o.Metadata, checksumKey)
+	if s.checksum && sum != "" && in.Range == nil {
+		h = sha256.New()
+		buf = io.MultiWriter(buf, h)
+	}
+
+	body := s.rateLimited(ctx, o.Body)
+	start := time.Now()
+
+	var done, reported int64
+	b := s.getBuffer(s.partSize)
+	defer s.putBuffer(b)
+	for {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		n, rerr := body.Read(b)
+		// bytes returned along with an error still belong to the object
+		if n > 0 {
+			if _, err = buf.Write(b[:n]); err != nil {
+				return err
+			}
+
+			// body is read in small chunks, so report once per part size
+			done += int64(n)
+			if done-reported >= s.partSize {
+				s.reportProgress(done, total)
+				reported = done
+			}
+		}
+
+		if rerr != nil {
+			if rerr == io.EOF {
+				if done != reported || done == 0 {
+					s.reportProgress(done, total)
+				}
+
+				if h != nil && hex.EncodeToString(h.Sum(nil)) != sum {
+					return fmt.Errorf("%s: %w", aws.StringValue(in.Key), storage.ErrChecksumMismatch)
+				}
+				s.log.Debugf("downloaded %s, %d bytes in %s", aws.StringValue(in.Key), done, time.Since(start))
+
+				break
+			}
+
+			if err = ctx.Err(); err != nil {
+				return err
+			}
+
+			return rerr
+		}
+	}
+
+	return nil
+}
+
+func (s *S3) Exists(name string) (bool, error) {
+	key, err := storage.ObjectKey(s.prefix, name)
+	if err != nil {
+		return false, err
+	}
+
+	return s.exists(context.Background(), key)
+}
+
+func (s *S3) exists(ctx context.Context, key string) (bool, error) {
+	in := s.headObjectInput(key)
+
+	err := s.retry(ctx, func() error {
+		_, err := s.c.HeadObjectWithContext(ctx, in)
+
+		return err
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return false, s.missingBucket(ctx)
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *S3) Stat(name string) (storage.FileInfo, error) {
+	key, err := storage.ObjectKey(s.prefix, name)
+	if err != nil {
+		return nil, err
+	}
+
+	in := s.headObjectInput(key)
+
+	o, err := s.c.HeadObject(in)
+	if err != nil {
+		if isNotFound(err) {
+			if err := s.missingBucket(context.Background()); err != nil {
+				return nil, err
+			}
+
+			return nil, fmt.Errorf("%s: %w", key, storage.ErrNotFound)
+		}
+
+		return nil, s.sseCustomerKeyError(key, err)
+	}
+
+	// sdk returns keys in canonical header form
+	metadata := make(map[string]string, len(o.Metadata))
+	for k, v := range o.Metadata {
+		metadata[strings.ToLower(k)] = aws.StringValue(v)
+	}
+
+	return &FileInfo{s.relName(key), aws.Int64Value(o.ContentLength), aws.TimeValue(o.LastModified), false, metadata}, nil
+}
+
+func (s *S3) Copy(src, dst string) error {
+	srcKey, err := storage.ObjectKey(s.prefix, src)
+	if err != nil {
+		return err
+	}
+
+	dstKey, err := storage.ObjectKey(s.prefix, dst)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.copy(context.Background(), srcKey, dstKey, nil)
+
+	return err
+}
+
+func (s *S3) Move(src, dst string) error {
+	ctx := context.Background()
+	srcKey, err := storage.ObjectKey(s.prefix, src)
+	if err != nil {
+		return err
+	}
+
+	dstKey, err := storage.ObjectKey(s.prefix, dst)
+	if err != nil {
+		return err
+	}
+
+	if s.dryRun {
+		s.log.Infof("dry run, not moving %s to %s", srcKey, dstKey)
+
+		return nil
+	}
+
+	if _, err := s.copy(ctx, srcKey, dstKey, nil); err != nil {
+		return fmt.Errorf("move %s: copy: %w", src, err)
+	}
+
+	// exact key only, unlike prefix based Delete
+	in := &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(srcKey),
+	}
+
+	if _, err := s.c.DeleteObjectWithContext(ctx, in); err != nil {
+		return fmt.Errorf("move %s: delete source: %w", src, err)
+	}
+
+	return nil
+}
+
+// CopyPrefix copies every object under srcPrefix server side, replacing
+// srcPrefix of its name with dstPrefix. Objects are copied by up to
+// concurrency at a time, see WithConcurrency. Names not valid by
+// storage.ValidateName are rejected before anything is copied.
+func (s *S3) CopyPrefix(srcPrefix, dstPrefix string) error {
+	for _, p := range []string{srcPrefix, dstPrefix} {
+		if p == "" {
+			continue
+		}
+
+		if err := storage.ValidateName(p); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// names are collected upfront, so copies under the source prefix are
+	// not listed again
+	names := make([]string, 0)
+	err := s.listFunc(ctx, s.key(srcPrefix), func(f storage.FileInfo) error {
+		names = append(names, f.Name())
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	type copyKeys struct{ name, src, dst string }
+
+	keys := make([]copyKeys, 0, len(names))
+	for _, name := range names {
+		src, err := storage.ObjectKey(s.prefix, name)
+		if err != nil {
+			return err
+		}
+
+		dst, err := storage.ObjectKey(s.prefix, dstPrefix+strings.TrimPrefix(name, srcPrefix))
+		if err != nil {
+			return err
+		}
+
+		keys = append(keys, copyKeys{name, src, dst})
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var cerr error
+	sem := make(chan struct{}, s.concurrency)
+	for _, k := range keys {
+		sem <- struct{}{}
+
+		mu.Lock()
+		err = cerr
+		mu.Unlock()
+		if err != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(k copyKeys) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if _, err := s.copy(ctx, k.src, k.dst, nil); err != nil {
+				mu.Lock()
+				if cerr == nil {
+					cerr = fmt.Errorf("copy %s: %w", k.name, err)
+					cancel()
+				}
+				mu.Unlock()
+			}
+		}(k)
+	}
+	wg.Wait()
+
+	return cerr
+}
+
+// copy copies object server side, metadata is merged into the source one.
+func (s *S3) copy(ctx context.Context, srcKey, dstKey string, metadata map[string]*string) (res UploadResult, err error) {
+	var mupload *s3.CreateMultipartUploadOutput
+
+	hi := s.headObjectInput(srcKey)
+
+	o, err := s.c.HeadObjectWithContext(ctx, hi)
+	if err != nil {
+		if isNotFound(err) {
+			if err := s.missingBucket(ctx); err != nil {
+				return res, err
+			}
+
+			return res, fmt.Errorf("%s: %w", srcKey, storage.ErrNotFound)
+		}
+
+		return res, err
+	}
+
+	replace := metadata != nil
+	if replace {
+		for k, v := range o.Metadata {
+			if _, ok := metadata[strings.ToLower(k)]; !ok {
+				metadata[strings.ToLower(k)] = v
+			}
+		}
+	} else {
+		metadata = o.Metadata
+	}
+
+	source := copySource(s.bucket, srcKey)
+	size := aws.Int64Value(o.ContentLength)
+	if size <= maxPartSize {
+		in := s.copyObjectInput(dstKey, source)
+
+		// copy in place is allowed only when metadata is replaced
+		if replace {
+			in.MetadataDirective = aws.String(s3.MetadataDirectiveReplace)
+			in.Metadata = metadata
+			in.ContentType = o.ContentType
+		}
+
+		out, err := s.c.CopyObjectWithContext(ctx, in)
+		if err != nil {
+			return res, err
+		}
+
+		res = UploadResult{dstKey, "", aws.StringValue(out.VersionId), size}
+		if out.CopyObjectResult != nil {
+			res.ETag = aws.StringValue(out.CopyObjectResult.ETag)
+		}
+
+		return res, nil
+	}
+
+	// single copy request is limited to 5 GiB, so larger objects
+	// are copied by ranges as parts of multipart upload
+	defer func() {
+		if err != nil && mupload != nil {
+			if aerr := s.abortUpload(context.Background(), dstKey, mupload.UploadId); aerr != nil {
+				err = fmt.Errorf("%w (abort multipart upload: %v)", err, aerr)
+			}
+		}
+	}()
+
+	partSize, err := s.partSizeFor(size)
+	if err != nil {
+		return res, err
+	}
+
+	in := s.createMultipartUploadInput(dstKey)
+	in.ContentType = o.ContentType
+	in.Metadata = metadata
+
+	out, err := s.c.CreateMultipartUploadWithContext(ctx, in)
+	if err != nil {
+		return res, err
+	}
+	mupload = out
+
+	mparts := make([]*s3.CompletedPart, 0)
+	for offset := int64(0); offset < size; offset += partSize {
+		end := offset + partSize - 1
+		if end >= size {
+			end = size - 1
+		}
+
+		partNumber := int64(len(mparts) + 1)
+		pi := s.uploadPartCopyInput(dstKey, mupload.UploadId, partNumber, source)
+		pi.CopySourceRange = aws.String(fmt.Sprintf("bytes=%d-%d", offset, end))
+
+		pres, err := s.c.UploadPartCopyWithContext(ctx, pi)
+		if err != nil {
+			return res, err
+		}
+
+		mparts = append(mparts, &s3.CompletedPart{
+			ETag:       pres.CopyPartResult.ETag,
+			PartNumber: aws.Int64(partNumber),
+		})
+	}
+
+	ci := &s3.CompleteMultipartUploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(dstKey),
+		UploadId: mupload.UploadId,
+		MultipartUpload: &s3.CompletedMultipartUpload{
+			Parts: mparts,
+		},
+	}
+
+	cout, err := s.c.CompleteMultipartUploadWithContext(ctx, ci)
+	if err != nil {
+		return res, err
+	}
+
+	return UploadResult{dstKey, aws.StringValue(cout.ETag), aws.StringValue(cout.VersionId), size}, nil
+}
+
+func (s *S3) SetTags(name string, tags map[string]string) error {
+	key, err := storage.ObjectKey(s.prefix, name)
+	if err != nil {
+		return err
+	}
+
+	tagSet := make([]*s3.Tag, 0, len(tags))
+	for k, v := range tags {
+		tagSet = append(tagSet, &s3.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+
+	in := &s3.PutObjectTaggingInput{
+		Bucket:  aws.String(s.bucket),
+		Key:     aws.String(key),
+		Tagging: &s3.Tagging{TagSet: tagSet},
+	}
+
+	if _, err := s.c.PutObjectTagging(in); err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("%s: %w", key, storage.ErrNotFound)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (s *S3) GetTags(name string) (map[string]string, error) {
+	key, err := storage.ObjectKey(s.prefix, name)
+	if err != nil {
+		return nil, err
+	}
+
+	in := &s3.GetObjectTaggingInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}
+
+	o, err := s.c.GetObjectTagging(in)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, fmt.Errorf("%s: %w", key, storage.ErrNotFound)
+		}
+
+		return nil, err
+	}
+
+	tags := make(map[string]string, len(o.TagSet))
+	for _, t := range o.TagSet {
+		tags[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+	}
+
+	return tags, nil
+}
+
+// PresignDownload returns URL to download the object without credentials
+// until expiry. With WithSSECustomerKey the holder of the URL must send
+// the key in x-amz-server-side-encryption-customer-* headers.
+func (s *S3) PresignDownload(name string, expiry time.Duration) (string, error) {
+	key, err := storage.ObjectKey(s.prefix, name)
+	if err != nil {
+		return "", err
+	}
+
+	req, _ := s.c.GetObjectRequest(s.getObjectInput(key))
+
+	return req.Presign(expiry)
+}
+
+// PresignUpload returns URL to upload the object by PUT without credentials
+// until expiry. Encryption, storage class, tags, metadata, object lock and
+// ACL settings are signed as headers, the holder of the URL must send them
+// with the same values, the customer key ones included.
+func (s *S3) PresignUpload(name string, expiry time.Duration) (string, error) {
+	key, err := storage.ObjectKey(s.prefix, name)
+	if err != nil {
+		return "", err
+	}
+
+	req, _ := s.c.PutObjectRequest(s.putObjectInput(key))
+
+	return req.Presign(expiry)
+}
+
+// AbortIncompleteUploads aborts multipart uploads under the prefix started
+// more than olderThan ago, e.g. by interrupted jobs, and returns their
+// number. Uploads in progress should be excluded by a large enough age.
+func (s *S3) AbortIncompleteUploads(olderThan time.Duration) (int, error) {
+	ctx := context.Background()
+	in := &s3.ListMultipartUploadsInput{
+		Bucket:       aws.String(s.bucket),
+		Prefix:       aws.String(s.key("")),
+		EncodingType: aws.String(s3.EncodingTypeUrl),
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	uploads := make([]*s3.MultipartUpload, 0)
+	err := s.c.ListMultipartUploadsPagesWithContext(ctx, in, func(page *s3.ListMultipartUploadsOutput, last bool) bool {
+		for _, u := range page.Uploads {
+			if aws.TimeValue(u.Initiated).Before(cutoff) {
+				u.Key = aws.String(listedKey(page.EncodingType, u.Key))
+				uploads = append(uploads, u)
+			}
+		}
+
+		return !last
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// abort after listing, so pages are not shifted
+	for i, u := range uploads {
+		if err := s.abortUpload(ctx, aws.StringValue(u.Key), u.UploadId); err != nil && !isNotFound(err) {
+			return i, fmt.Errorf("abort upload of %s: %w", aws.StringValue(u.Key), err)
+		}
+	}
+
+	return len(uploads), nil
+}
+
+// uploadPart returns completed part and md5 sum of its body.
+func (s *S3) uploadPart(ctx context.Context, key string, uploadId *string, partNumber int64, body []byte) (*s3.CompletedPart, []byte, error) {
+	contentLength := int64(len(body))
+	start := time.Now()
+
+	sum := md5.Sum(body)
+	contentMD5 := base64.StdEncoding.EncodeToString(sum[:])
+
+	var res *s3.UploadPartOutput
+	err := s.retry(ctx, func() (err error) {
+		r := newBodyReader(body)
+		defer r.release()
+
+		pi := s.uploadPartInput(key, uploadId, partNumber)
+		pi.Body = r
+		pi.ContentLength = aws.Int64(contentLength)
+		pi.ContentMD5 = aws.String(contentMD5)
+
+		res, err = s.c.UploadPartWithContext(ctx, pi)
+
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	s.log.Debugf("uploaded part %d of %s, %d bytes in %s", partNumber, key, contentLength, time.Since(start))
+
+	return &s3.CompletedPart{
+		ETag:       res.ETag,
+		PartNumber: aws.Int64(partNumber),
+	}, sum[:], nil
+}
+
+// md5ETag reports whether etag of uploaded objects is md5 based, which is
+// not for objects encrypted with kms or customer keys.
+func (s *S3) md5ETag() bool {
+	return s.sse != s3.ServerSideEncryptionAwsKms && s.sseCustomerKey == ""
+}
+
+// multipartETag returns etag s3 computes for multipart upload of parts
+// with the md5 sums, the sums must be ordered by part number.
+func multipartETag(sums [][]byte) string {
+	h := md5.New()
+	for _, sum := range sums {
+		h.Write(sum)
+	}
+
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(h.Sum(nil)), len(sums))
+}
+
+// key joins name with the prefix keeping trailing slash, so it can be used
+// as list prefix.
+func (s *S3) key(name string) string {
+	if s.prefix == "" {
+		return name
+	}
+
+	return strings.TrimSuffix(s.prefix, "/") + "/" + name
+}
+
+// relName strips the prefix from object key.
+func (s *S3) relName(key string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(key, strings.TrimSuffix(s.prefix, "/")), "/")
+}
+
+// partSizeFor returns part size which keeps upload of size bytes within
+// parts limit, unknown size is passed as -1.
+func (s *S3) partSizeFor(size int64) (int64, error) {
+	if size <= s.partSize*maxParts {
+		return s.partSize, nil
+	}
+
+	// round up to whole MiB
+	partSize := (size + maxParts - 1) / maxParts
+	partSize = (partSize + 1<<20 - 1) &^ (1<<20 - 1)
+	if partSize > maxPartSize {
+		return 0, fmt.Errorf("object size %d exceeds maximum of %d bytes", size, maxPartSize*maxParts)
+	}
+
+	return partSize, nil
+}
+
+// detectContentType returns configured content type or the one of key
+// extension, falling back to sniffing it from the beginning of object.
+func (s *S3) detectContentType(key string, b []byte) string {
+	if s.contentType != "" {
+		return s.contentType
+	}
+
+	if t := mime.TypeByExtension(path.Ext(key)); t != "" {
+		return t
+	}
+
+	// sniffing reports empty objects as text
+	if len(b) == 0 {
+		return "application/octet-stream"
+	}
+
+	// only the first 512 bytes are considered by sniffing
+	if len(b) > 512 {
+		b = b[:512]
+	}
+
+	return http.DetectContentType(b)
+}
+
+// writeOptions returns request options of requests creating objects.
+func (s *S3) writeOptions() []request.Option {
+	if !s.noOverwrite {
+		return nil
+	}
+
+	// sdk predates conditional writes, so header is set directly
+	return []request.Option{func(r *request.Request) {
+		r.HTTPRequest.Header.Set("If-None-Match", "*")
+	}}
+}
+
+// setRequestHeaders sets headers which apply to every request, so they need
+// not be set on each operation input.
+func (s *S3) setRequestHeaders(r *request.Request) {
+	if s.requesterPays {
+		r.HTTPRequest.Header.Set("X-Amz-Request-Payer", s3.RequestPayerRequester)
+	}
+
+	if s.bucketOwner != "" {
+		r.HTTPRequest.Header.Set("X-Amz-Expected-Bucket-Owner", s.bucketOwner)
+
+		// copies are made within the bucket, so it is the source one too
+		if r.Operation.Name == "CopyObject" || r.Operation.Name == "UploadPartCopy" {
+			r.HTTPRequest.Header.Set("X-Amz-Source-Expected-Bucket-Owner", s.bucketOwner)
+		}
+	}
+}
+
+func (s *S3) writeError(key string, err error) error {
+	if rerr, ok := err.(awserr.RequestFailure); ok && rerr.StatusCode() == http.StatusPreconditionFailed {
+		return fmt.Errorf("%s: %w", key, storage.ErrAlreadyExists)
+	}
+
+	// some servers, e.g. minio, also require non-final parts of equal size
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "EntityTooSmall" {
+		return fmt.Errorf("%s: part other than the last one is smaller than %d bytes or than the others: %w", key, minPartSize, err)
+	}
+
+	return err
+}
+
+// sseCustomerKeyError explains opaque bad request returned for objects
+// encrypted with customer key, when the key is not configured.
+func (s *S3) sseCustomerKeyError(key string, err error) error {
+	if s.sseCustomerKey != "" {
+		return err
+	}
+
+	if rerr, ok := err.(awserr.RequestFailure); ok && rerr.StatusCode() == http.StatusBadRequest {
+		return fmt.Errorf("%s may be encrypted with customer provided key, which is not set: %w", key, err)
+	}
+
+	return err
+}
+
+func (s *S3) reportProgress(done, total int64) {
+	if s.progress != nil {
+		s.progress(done, total)
+	}
+}
+
+func (s *S3) abortUpload(ctx context.Context, key string, uploadId *string) error {
+	in := &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(key),
+		UploadId: uploadId,
+	}
+
+	_, err := s.c.AbortMultipartUploadWithContext(ctx, in)
+
+	return err
+}
+
+func checksumMetadata(h hash.Hash) map[string]*string {
+	if h == nil {
+		return nil
+	}
+
+	return map[string]*string{
+		checksumKey: aws.String(hex.EncodeToString(h.Sum(nil))),
+	}
+}
+
+func mergeMetadata(dst, src map[string]*string) map[string]*string {
+	if len(src) == 0 {
+		return dst
+	}
+
+	if dst == nil {
+		dst = make(map[string]*string, len(src))
+	}
+
+	for k, v := range src {
+		dst[k] = v
+	}
+
+	return dst
+}
+
+// metadataValue looks key up ignoring case, as sdk returns metadata keys
+// in canonical http header form.
+func metadataValue(metadata map[string]*string, key string) string {
+	for k, v := range metadata {
+		if strings.EqualFold(k, key) {
+			return aws.StringValue(v)
+		}
+	}
+
+	return ""
+}
+
+// listedKey returns key of list response, which is url encoded on request,
+// so keys with characters not allowed in XML are listed intact. Servers
+// ignoring the encoding type do not return it, their keys are kept as is.
+func listedKey(encodingType, key *string) string {
+	k := aws.StringValue(key)
+	if aws.StringValue(encodingType) != s3.EncodingTypeUrl {
+		return k
+	}
+
+	if d, err := url.QueryUnescape(k); err == nil {
+		return d
+	}
+
+	return k
+}
+
+// copySource escapes object location for x-amz-copy-source header, "+" is
+// escaped too as some servers decode it as a space. Slashes are kept, as
+// some servers look for "?versionId" in the source before unescaping it.
+func copySource(bucket, key string) string {
+	segs := strings.Split(bucket+"/"+key, "/")
+	for i, seg := range segs {
+		segs[i] = strings.ReplaceAll(url.PathEscape(seg), "+", "%2B")
+	}
+
+	return strings.Join(segs, "/")
+}
+
+// readerSize returns the number of bytes left in r if it is seekable, or -1.
+func readerSize(r io.Reader) (int64, error) {
+	sk, ok := r.(io.Seeker)
+	if !ok {
+		return -1, nil
+	}
+
+	cur, err := sk.Seek(0, io.SeekCurrent)
+	if err != nil {
+		// pipes and sockets are not really seekable
+		return -1, nil
+	}
+
+	end, err := sk.Seek(0, io.SeekEnd)
+	if err != nil {
+		return -1, nil
+	}
+
+	if _, err := sk.Seek(cur, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	return end - cur, nil
+}
+
+// missingBucket returns error if the bucket does not exist, responses to HEAD
+// have no body to tell it from missing object.
+func (s *S3) missingBucket(ctx context.Context) error {
+	_, err := s.c.HeadBucketWithContext(ctx, &s3.HeadBucketInput{Bucket: aws.String(s.bucket)})
+	if rerr, ok := err.(awserr.RequestFailure); ok && rerr.StatusCode() == http.StatusNotFound {
+		return fmt.Errorf("bucket %s: %w", s.bucket, err)
+	}
+
+	return nil
+}
+
+func isNotFound(err error) bool {
+	// missing bucket is misconfiguration rather than missing object
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchBucket {
+		return false
+	}
+
+	if rerr, ok := err.(awserr.RequestFailure); ok && rerr.StatusCode() == http.StatusNotFound {
+		return true
+	}
+
+	if aerr, ok := err.(awserr.Error); ok {
+		switch aerr.Code() {
+		case s3.ErrCodeNoSuchKey, "NotFound":
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *FileInfo) Name() string { return f.name }
+
+func (f *FileInfo) Size() int64 { return f.size }
+
+func (f *FileInfo) ModTime() time.Time { return f.mtime }
+
+func (f *FileInfo) IsDir() bool { return f.isdir }
+
+// Metadata returns user metadata with lower cased keys, it is available
+// only for objects returned by Stat.
+func (f *FileInfo) Metadata() map[string]string { return f.metadata }
+
+// Checksum returns SHA-256 stored by WithChecksum, it is available only for
+// objects returned by Stat.
+func (f *FileInfo) Checksum() string { return f.metadata[checksumKey] }
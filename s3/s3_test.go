@@ -0,0 +1,2680 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+
+	"github.com/sputnik-systems/backups-storage"
+)
+
+const testBucket = "bucket"
+
+// fakeS3 is s3 api of in-memory backend, which records requests and lets
+// tests answer or fail them instead of the backend.
+type fakeS3 struct {
+	t      testing.TB
+	sess   *session.Session
+	client *http.Client
+
+	mu       sync.Mutex
+	requests []recorded
+	hook     func(w http.ResponseWriter, r *http.Request, op string) bool
+}
+
+// recorded is request seen by the fake with its operation name.
+type recorded struct {
+	op     string
+	key    string
+	query  url.Values
+	header http.Header
+	size   int64
+}
+
+func newFakeS3(t testing.TB) *fakeS3 {
+	f := &fakeS3{t: t}
+	backend := gofakes3.New(s3mem.New()).Server()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op, key := operation(r)
+
+		f.mu.Lock()
+		f.requests = append(f.requests, recorded{op, key, r.URL.Query(), r.Header.Clone(), r.ContentLength})
+		hook := f.hook
+		f.mu.Unlock()
+
+		if hook != nil && hook(w, r, op) {
+			return
+		}
+
+		backend.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	// customer keys are sent over TLS only, trust the test server
+	t.Setenv("AWS_CA_BUNDLE", "")
+	f.client = srv.Client()
+	f.sess = session.Must(session.NewSession(&aws.Config{
+		Credentials:      credentials.NewStaticCredentials("id", "secret", ""),
+		Endpoint:         aws.String(srv.URL),
+		HTTPClient:       f.client,
+		Region:           aws.String("us-east-1"),
+		S3ForcePathStyle: aws.Bool(true),
+		MaxRetries:       aws.Int(0),
+	}))
+
+	if _, err := s3.New(f.sess).CreateBucket(&s3.CreateBucketInput{Bucket: aws.String(testBucket)}); err != nil {
+		t.Fatal(err)
+	}
+	f.reset()
+
+	return f
+}
+
+// newTestStorage returns storage with prefix "backups" in bucket of new
+// fake server.
+func newTestStorage(t *testing.T, opts ...Option) (*S3, *fakeS3) {
+	f := newFakeS3(t)
+
+	return f.storage(opts...), f
+}
+
+func (f *fakeS3) storage(opts ...Option) *S3 {
+	s, err := NewStorage(f.sess, testBucket, "backups", opts...)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+
+	return s.(*S3)
+}
+
+// setHook sets function which sees requests before the backend, it answers
+// the request itself when it returns true.
+func (f *fakeS3) setHook(hook func(w http.ResponseWriter, r *http.Request, op string) bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.hook = hook
+}
+
+func (f *fakeS3) reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests = nil
+}
+
+// calls returns recorded requests of the operation, all of them for empty
+// op.
+func (f *fakeS3) calls(op string) []recorded {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var calls []recorded
+	for _, r := range f.requests {
+		if op == "" || r.op == op {
+			calls = append(calls, r)
+		}
+	}
+
+	return calls
+}
+
+// put stores object with the key directly in the backend.
+func (f *fakeS3) put(key string, data []byte) {
+	_, err := s3.New(f.sess).PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(testBucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	})
+	if err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+// get returns object with the key directly from the backend.
+func (f *fakeS3) get(key string) []byte {
+	o, err := s3.New(f.sess).GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(testBucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	defer o.Body.Close()
+
+	b, err := io.ReadAll(o.Body)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+
+	return b
+}
+
+// operation returns name of s3 operation of path style request and the key.
+func operation(r *http.Request) (string, string) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	q := r.URL.Query()
+	has := func(p string) bool { _, ok := q[p]; return ok }
+	copySource := r.Header.Get("X-Amz-Copy-Source") != ""
+
+	switch {
+	case bucket == "":
+		return "ListBuckets", ""
+	case key == "":
+		switch {
+		case r.Method == http.MethodPost && has("delete"):
+			return "DeleteObjects", ""
+		case r.Method == http.MethodGet && has("uploads"):
+			return "ListMultipartUploads", ""
+		case r.Method == http.MethodGet && has("versions"):
+			return "ListObjectVersions", ""
+		case r.Method == http.MethodGet:
+			return "ListObjects", ""
+		case r.Method == http.MethodHead:
+			return "HeadBucket", ""
+		case r.Method == http.MethodPut:
+			return "CreateBucket", ""
+		}
+	case r.Method == http.MethodPost && has("uploads"):
+		return "CreateMultipartUpload", key
+	case r.Method == http.MethodPost && has("uploadId"):
+		return "CompleteMultipartUpload", key
+	case r.Method == http.MethodPost && has("restore"):
+		return "RestoreObject", key
+	case r.Method == http.MethodPut && has("partNumber") && copySource:
+		return "UploadPartCopy", key
+	case r.Method == http.MethodPut && has("partNumber"):
+		return "UploadPart", key
+	case r.Method == http.MethodPut && has("tagging"):
+		return "PutObjectTagging", key
+	case r.Method == http.MethodPut && has("retention"):
+		return "PutObjectRetention", key
+	case r.Method == http.MethodPut && copySource:
+		return "CopyObject", key
+	case r.Method == http.MethodPut:
+		return "PutObject", key
+	case r.Method == http.MethodGet && has("tagging"):
+		return "GetObjectTagging", key
+	case r.Method == http.MethodGet:
+		return "GetObject", key
+	case r.Method == http.MethodHead:
+		return "HeadObject", key
+	case r.Method == http.MethodDelete && has("uploadId"):
+		return "AbortMultipartUpload", key
+	case r.Method == http.MethodDelete:
+		return "DeleteObject", key
+	}
+
+	return r.Method, key
+}
+
+// randomBytes returns n random bytes, which do not compress.
+func randomBytes(t *testing.T, n int64) []byte {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+// trickleReader returns at most n bytes per Read.
+type trickleReader struct {
+	r io.Reader
+	n int
+}
+
+func (r *trickleReader) Read(p []byte) (int, error) {
+	if len(p) > r.n {
+		p = p[:r.n]
+	}
+
+	return r.r.Read(p)
+}
+
+func TestUploadShortReads(t *testing.T) {
+	s, f := newTestStorage(t, WithPartSize(minPartSize))
+
+	for _, n := range []int{1, 3, 7} {
+		data := randomBytes(t, 2*minPartSize+1234)
+		if err := s.Upload("db.sql", &trickleReader{bytes.NewReader(data), n}); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := f.get("backups/db.sql"); !bytes.Equal(got, data) {
+			t.Fatalf("%d bytes per read: got %d bytes, want %d", n, len(got), len(data))
+		}
+
+		// every part but the last is of part size
+		parts := f.calls("UploadPart")
+		if len(parts) != 3 {
+			t.Fatalf("%d bytes per read: %d parts, want 3", n, len(parts))
+		}
+
+		for _, p := range parts[:2] {
+			if p.size != minPartSize {
+				t.Errorf("%d bytes per read: part of %d bytes, want %d", n, p.size, minPartSize)
+			}
+		}
+		f.reset()
+	}
+}
+
+// shortFirstReader returns few bytes on the first Read and fills p later.
+type shortFirstReader struct {
+	r    io.Reader
+	read bool
+}
+
+func (r *shortFirstReader) Read(p []byte) (int, error) {
+	if !r.read && len(p) > 10 {
+		r.read = true
+		p = p[:10]
+	}
+
+	return r.r.Read(p)
+}
+
+func TestUploadShortFirstRead(t *testing.T) {
+	s, f := newTestStorage(t, WithPartSize(minPartSize))
+
+	data := randomBytes(t, 3*minPartSize)
+	if err := s.Upload("db.sql", &shortFirstReader{r: bytes.NewReader(data)}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, p := range f.calls("UploadPart") {
+		if p.size != minPartSize {
+			t.Errorf("part %d of %d bytes, want %d", i+1, p.size, minPartSize)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := s.Download("db.sql", &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Fatalf("downloaded %d bytes, want %d", buf.Len(), len(data))
+	}
+}
+
+// failPart fails upload of the part with the number.
+func failPart(number string) func(w http.ResponseWriter, r *http.Request, op string) bool {
+	return func(w http.ResponseWriter, r *http.Request, op string) bool {
+		if op != "UploadPart" || r.URL.Query().Get("partNumber") != number {
+			return false
+		}
+
+		w.WriteHeader(http.StatusForbidden)
+
+		return true
+	}
+}
+
+func TestUploadAbortsOnPartFailure(t *testing.T) {
+	s, f := newTestStorage(t, WithPartSize(minPartSize))
+	f.setHook(failPart("2"))
+
+	if err := s.Upload("db.sql", bytes.NewReader(randomBytes(t, 3*minPartSize))); err == nil {
+		t.Fatal("upload with failed part succeeded")
+	}
+
+	created := f.calls("UploadPart")
+	aborted := f.calls("AbortMultipartUpload")
+	if len(aborted) != 1 {
+		t.Fatalf("%d aborts, want 1", len(aborted))
+	}
+
+	if id := aborted[0].query.Get("uploadId"); id == "" || id != created[0].query.Get("uploadId") {
+		t.Errorf("aborted upload %q, want %q", id, created[0].query.Get("uploadId"))
+	}
+
+	if len(f.calls("CompleteMultipartUpload")) != 0 {
+		t.Error("failed upload completed")
+	}
+}
+
+func TestUploadContextCanceled(t *testing.T) {
+	s, f := newTestStorage(t, WithPartSize(minPartSize))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// cancel while the first part is being uploaded
+	f.setHook(func(w http.ResponseWriter, r *http.Request, op string) bool {
+		if op == "UploadPart" {
+			cancel()
+		}
+
+		return false
+	})
+
+	err := s.UploadContext(ctx, "db.sql", bytes.NewReader(randomBytes(t, 4*minPartSize)))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want %v", err, context.Canceled)
+	}
+
+	if n := len(f.calls("AbortMultipartUpload")); n != 1 {
+		t.Errorf("%d aborts, want 1", n)
+	}
+
+	if n := len(f.calls("UploadPart")); n == 4 {
+		t.Error("all parts uploaded after cancel")
+	}
+}
+
+func TestExists(t *testing.T) {
+	s, f := newTestStorage(t)
+	f.put("backups/present", []byte("x"))
+
+	if ok, err := s.Exists("present"); err != nil || !ok {
+		t.Errorf("present object: got %v, %v, want true", ok, err)
+	}
+
+	if ok, err := s.Exists("absent"); err != nil || ok {
+		t.Errorf("absent object: got %v, %v, want false", ok, err)
+	}
+
+	if key := f.calls("HeadObject")[0].key; key != "backups/present" {
+		t.Errorf("head of %s, want backups/present", key)
+	}
+
+	f.setHook(func(w http.ResponseWriter, r *http.Request, op string) bool {
+		w.WriteHeader(http.StatusForbidden)
+
+		return true
+	})
+
+	if ok, err := s.Exists("present"); err == nil || ok {
+		t.Errorf("forbidden object: got %v, %v, want error", ok, err)
+	}
+}
+
+// zeroSeeker is seekable stream of size zero bytes, which is never stored.
+type zeroSeeker struct {
+	size, off int64
+}
+
+func (z *zeroSeeker) Read(p []byte) (int, error) {
+	if z.off >= z.size {
+		return 0, io.EOF
+	}
+
+	n := int64(len(p))
+	if n > z.size-z.off {
+		n = z.size - z.off
+	}
+	clear(p[:n])
+	z.off += n
+
+	return int(n), nil
+}
+
+func (z *zeroSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += z.off
+	case io.SeekEnd:
+		offset += z.size
+	}
+	z.off = offset
+
+	return offset, nil
+}
+
+func TestUploadScalesPartSize(t *testing.T) {
+	s, f := newTestStorage(t)
+	// the first part tells the part size, the rest is not sent
+	f.setHook(failPart("1"))
+
+	size := int64(1536) << 30
+	if err := s.Upload("huge.sql", &zeroSeeker{size: size}); err == nil {
+		t.Fatal("upload with failed part succeeded")
+	}
+
+	parts := f.calls("UploadPart")
+	if len(parts) != 1 {
+		t.Fatalf("%d parts sent, want 1", len(parts))
+	}
+
+	ps := parts[0].size
+	if ps <= s.partSize || (size+ps-1)/ps > maxParts {
+		t.Errorf("part size %d gives %d parts of 1.5 TiB", ps, (size+ps-1)/ps)
+	}
+}
+
+// largeObject answers requests which gofakes3 does not support for object
+// of the key reported to be of size bytes, so its parts are copied by
+// UploadPartCopy.
+func largeObject(key string, size int64) func(w http.ResponseWriter, r *http.Request, op string) bool {
+	return func(w http.ResponseWriter, r *http.Request, op string) bool {
+		_, k, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+
+		switch {
+		case op == "HeadObject" && k == key:
+			w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+			w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+			w.Header().Set("ETag", `"large"`)
+		case op == "UploadPartCopy":
+			fmt.Fprintf(w, `<CopyPartResult><ETag>"part-%s"</ETag></CopyPartResult>`, r.URL.Query().Get("partNumber"))
+		case op == "CompleteMultipartUpload":
+			fmt.Fprint(w, `<CompleteMultipartUploadResult><ETag>"copy-2"</ETag></CompleteMultipartUploadResult>`)
+		default:
+			return false
+		}
+
+		return true
+	}
+}
+
+func TestCopy(t *testing.T) {
+	s, f := newTestStorage(t)
+	f.put("backups/src", []byte("data"))
+
+	if err := s.Copy("src", "dst/copy"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := f.get("backups/dst/copy"); string(got) != "data" {
+		t.Errorf("copied %q, want data", got)
+	}
+
+	copies := f.calls("CopyObject")
+	if len(copies) != 1 {
+		t.Fatalf("%d copy requests, want 1", len(copies))
+	}
+
+	if src, _ := url.PathUnescape(copies[0].header.Get("X-Amz-Copy-Source")); src != testBucket+"/backups/src" {
+		t.Errorf("copy source %s, want %s/backups/src", src, testBucket)
+	}
+}
+
+func TestCopyLargeObject(t *testing.T) {
+	s, f := newTestStorage(t)
+
+	size := int64(maxPartSize + 1)
+	f.setHook(largeObject("backups/src", size))
+
+	if err := s.Copy("src", "dst"); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(f.calls("CopyObject")); n != 0 {
+		t.Errorf("%d single copy requests of large object", n)
+	}
+
+	// parts are of part size and cover the whole object
+	parts := f.calls("UploadPartCopy")
+	want := (size + s.partSize - 1) / s.partSize
+	if int64(len(parts)) != want {
+		t.Fatalf("%d parts, want %d", len(parts), want)
+	}
+
+	for i, p := range parts {
+		end := min(int64(i+1)*s.partSize, size) - 1
+		if rg := p.header.Get("X-Amz-Copy-Source-Range"); rg != fmt.Sprintf("bytes=%d-%d", int64(i)*s.partSize, end) {
+			t.Errorf("part %d of range %s", i+1, rg)
+		}
+
+		if p.key != "backups/dst" {
+			t.Errorf("part %d copied to %s", i+1, p.key)
+		}
+	}
+
+	if n := len(f.calls("CompleteMultipartUpload")); n != 1 {
+		t.Errorf("%d completions, want 1", n)
+	}
+}
+
+// failOp fails every request of the operation.
+func failOp(op string) func(w http.ResponseWriter, r *http.Request, op string) bool {
+	return func(w http.ResponseWriter, r *http.Request, o string) bool {
+		if o != op {
+			return false
+		}
+
+		w.WriteHeader(http.StatusForbidden)
+
+		return true
+	}
+}
+
+// replyError answers every request of the operation with S3 error code.
+func replyError(op string, status int, code string) func(w http.ResponseWriter, r *http.Request, op string) bool {
+	return func(w http.ResponseWriter, r *http.Request, o string) bool {
+		if o != op {
+			return false
+		}
+
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+
+		return true
+	}
+}
+
+func TestMove(t *testing.T) {
+	s, f := newTestStorage(t)
+	f.put("backups/tmp/db.sql", []byte("data"))
+
+	if err := s.Move("tmp/db.sql", "db.sql"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := f.get("backups/db.sql"); string(got) != "data" {
+		t.Errorf("moved %q, want data", got)
+	}
+
+	if ok, err := s.Exists("tmp/db.sql"); err != nil || ok {
+		t.Errorf("source exists %v, %v", ok, err)
+	}
+}
+
+func TestMoveCopyFailure(t *testing.T) {
+	s, f := newTestStorage(t)
+	f.put("backups/src", []byte("data"))
+	f.setHook(failOp("CopyObject"))
+
+	err := s.Move("src", "dst")
+	if err == nil || !strings.Contains(err.Error(), "copy") {
+		t.Fatalf("got %v, want copy error", err)
+	}
+
+	if n := len(f.calls("DeleteObject")); n != 0 {
+		t.Errorf("source deleted %d times after failed copy", n)
+	}
+
+	if ok, err := s.Exists("src"); err != nil || !ok {
+		t.Errorf("source exists %v, %v", ok, err)
+	}
+}
+
+func TestMoveDeleteFailure(t *testing.T) {
+	s, f := newTestStorage(t)
+	f.put("backups/src", []byte("data"))
+	f.setHook(failOp("DeleteObject"))
+
+	err := s.Move("src", "dst")
+	if err == nil || !strings.Contains(err.Error(), "delete source") {
+		t.Fatalf("got %v, want delete source error", err)
+	}
+
+	if ok, err := s.Exists("dst"); err != nil || !ok {
+		t.Errorf("destination exists %v, %v", ok, err)
+	}
+}
+
+func TestPresign(t *testing.T) {
+	s, f := newTestStorage(t)
+
+	for _, presign := range []func(string, time.Duration) (string, error){s.PresignDownload, s.PresignUpload} {
+		raw, err := presign("db/dump.sql", 15*time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if u.Path != "/"+testBucket+"/backups/db/dump.sql" {
+			t.Errorf("presigned path %s", u.Path)
+		}
+
+		if exp := u.Query().Get("X-Amz-Expires"); exp != "900" {
+			t.Errorf("expires %q, want 900", exp)
+		}
+	}
+
+	// presigned url is usable without credentials
+	u, err := s.PresignUpload("db/dump.sql", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest(http.MethodPut, u, strings.NewReader("data"))
+	resp, err := f.client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if got := f.get("backups/db/dump.sql"); string(got) != "data" {
+		t.Errorf("uploaded by presigned url %q", got)
+	}
+}
+
+func TestPresignSSE(t *testing.T) {
+	key := bytes.Repeat([]byte("k"), 32)
+	for _, tc := range []struct {
+		opt      Option
+		download string
+		upload   string
+	}{
+		{
+			WithSSEKMS("key"),
+			"",
+			"x-amz-server-side-encryption;x-amz-server-side-encryption-aws-kms-key-id",
+		},
+		{
+			WithSSECustomerKey(key),
+			"x-amz-server-side-encryption-customer-algorithm;x-amz-server-side-encryption-customer-key;x-amz-server-side-encryption-customer-key-md5",
+			"x-amz-server-side-encryption-customer-algorithm;x-amz-server-side-encryption-customer-key;x-amz-server-side-encryption-customer-key-md5",
+		},
+	} {
+		s, _ := newTestStorage(t, tc.opt)
+
+		for _, p := range []struct {
+			presign func(string, time.Duration) (string, error)
+			want    string
+		}{{s.PresignDownload, tc.download}, {s.PresignUpload, tc.upload}} {
+			raw, err := p.presign("db/dump.sql", time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			u, err := url.Parse(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var sse []string
+			for _, h := range strings.Split(u.Query().Get("X-Amz-SignedHeaders"), ";") {
+				if strings.HasPrefix(h, "x-amz-server-side-encryption") {
+					sse = append(sse, h)
+				}
+			}
+
+			if got := strings.Join(sse, ";"); got != p.want {
+				t.Errorf("signed headers %s, want %s", got, p.want)
+			}
+		}
+	}
+}
+
+func TestDownloadRange(t *testing.T) {
+	s, f := newTestStorage(t)
+	f.put("backups/db.sql", []byte("0123456789"))
+
+	for _, tc := range []struct {
+		offset, length int64
+		header, want   string
+	}{
+		{2, 3, "bytes=2-4", "234"},
+		{0, 1, "bytes=0-0", "0"},
+		{7, 0, "bytes=7-", "789"},
+		{4, -1, "bytes=4-", "456789"},
+	} {
+		f.reset()
+
+		var buf bytes.Buffer
+		if err := s.DownloadRange("db.sql", tc.offset, tc.length, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		if buf.String() != tc.want {
+			t.Errorf("range %d+%d: got %q, want %q", tc.offset, tc.length, buf.String(), tc.want)
+		}
+
+		if rg := f.calls("GetObject")[0].header.Get("Range"); rg != tc.header {
+			t.Errorf("range %d+%d: header %s, want %s", tc.offset, tc.length, rg, tc.header)
+		}
+	}
+
+	if err := s.DownloadRange("db.sql", -1, 2, &bytes.Buffer{}); err == nil {
+		t.Error("negative offset accepted")
+	}
+}
+
+// progressRecorder records progress callbacks, it is not synchronized as
+// callbacks come from the goroutine of the operation.
+type progressRecorder struct {
+	done, total []int64
+}
+
+func (p *progressRecorder) record(done, total int64) {
+	p.done = append(p.done, done)
+	p.total = append(p.total, total)
+}
+
+func (p *progressRecorder) check(t *testing.T, size, total int64) {
+	t.Helper()
+
+	if len(p.done) == 0 {
+		t.Fatal("no progress reported")
+	}
+
+	for i := range p.done {
+		if i > 0 && p.done[i] <= p.done[i-1] {
+			t.Errorf("progress %v is not increasing", p.done)
+		}
+
+		if p.total[i] != total {
+			t.Errorf("total %d, want %d", p.total[i], total)
+		}
+	}
+
+	if last := p.done[len(p.done)-1]; last != size {
+		t.Errorf("last progress %d, want %d", last, size)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	var p progressRecorder
+	s, _ := newTestStorage(t, WithPartSize(minPartSize), WithConcurrency(3), WithProgress(p.record))
+
+	size := int64(4*minPartSize + 100)
+	data := randomBytes(t, size)
+
+	// size of seekable reader is known
+	if err := s.Upload("a", bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	p.check(t, size, size)
+
+	p = progressRecorder{}
+	if err := s.Upload("b", &trickleReader{bytes.NewReader(data), 1 << 20}); err != nil {
+		t.Fatal(err)
+	}
+	p.check(t, size, -1)
+
+	p = progressRecorder{}
+	if err := s.Download("a", io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	p.check(t, size, size)
+}
+
+func TestUploadConcurrentPartsOrder(t *testing.T) {
+	s, f := newTestStorage(t, WithPartSize(minPartSize), WithConcurrency(4))
+
+	var complete []byte
+	f.setHook(func(w http.ResponseWriter, r *http.Request, op string) bool {
+		switch op {
+		case "UploadPart":
+			// the first part completes last
+			if r.URL.Query().Get("partNumber") == "1" {
+				time.Sleep(200 * time.Millisecond)
+			}
+		case "CompleteMultipartUpload":
+			complete, _ = io.ReadAll(r.Body)
+			r.Body = io.NopCloser(bytes.NewReader(complete))
+		}
+
+		return false
+	})
+
+	data := randomBytes(t, 4*minPartSize)
+	if err := s.Upload("db.sql", bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	var manifest struct {
+		Parts []struct {
+			PartNumber int64
+		} `xml:"Part"`
+	}
+	if err := xml.Unmarshal(complete, &manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(manifest.Parts) != 4 {
+		t.Fatalf("%d parts completed, want 4", len(manifest.Parts))
+	}
+
+	for i, p := range manifest.Parts {
+		if n := p.PartNumber; n != int64(i+1) {
+			t.Errorf("part %d listed at %d", n, i+1)
+		}
+	}
+
+	if got := f.get("backups/db.sql"); !bytes.Equal(got, data) {
+		t.Error("assembled object differs")
+	}
+}
+
+func TestUploadConcurrentPartFailure(t *testing.T) {
+	s, f := newTestStorage(t, WithPartSize(minPartSize), WithConcurrency(4))
+	f.setHook(failPart("2"))
+
+	if err := s.Upload("db.sql", bytes.NewReader(randomBytes(t, 8*minPartSize))); err == nil {
+		t.Fatal("upload with failed part succeeded")
+	}
+
+	if n := len(f.calls("AbortMultipartUpload")); n != 1 {
+		t.Errorf("%d aborts, want 1", n)
+	}
+
+	if ok, err := s.Exists("db.sql"); err != nil || ok {
+		t.Errorf("failed upload exists %v, %v", ok, err)
+	}
+}
+
+func TestDownloadChecksumMismatch(t *testing.T) {
+	s, f := newTestStorage(t, WithChecksum())
+
+	if err := s.Upload("db.sql", strings.NewReader("data")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Download("db.sql", &bytes.Buffer{}); err != nil {
+		t.Fatalf("download of intact object: %v", err)
+	}
+
+	// stored checksum does not match the body
+	_, err := s3.New(f.sess).PutObject(&s3.PutObjectInput{
+		Bucket:   aws.String(testBucket),
+		Key:      aws.String("backups/db.sql"),
+		Body:     strings.NewReader("data"),
+		Metadata: map[string]*string{checksumKey: aws.String(strings.Repeat("0", 64))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Download("db.sql", &bytes.Buffer{}); !errors.Is(err, storage.ErrChecksumMismatch) {
+		t.Errorf("got %v, want %v", err, storage.ErrChecksumMismatch)
+	}
+}
+
+func TestUploadContentMD5(t *testing.T) {
+	s, f := newTestStorage(t)
+
+	if err := s.Upload("db.sql", strings.NewReader("data")); err != nil {
+		t.Fatal(err)
+	}
+
+	sum := md5.Sum([]byte("data"))
+	want := base64.StdEncoding.EncodeToString(sum[:])
+	if got := f.calls("PutObject")[0].header.Get("Content-Md5"); got != want {
+		t.Errorf("Content-MD5 %q, want %q", got, want)
+	}
+}
+
+func TestUploadSSEHeaders(t *testing.T) {
+	s, f := newTestStorage(t, WithPartSize(minPartSize), WithSSEKMS("key"))
+
+	if err := s.Upload("small", strings.NewReader("data")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Upload("large", bytes.NewReader(randomBytes(t, 2*minPartSize))); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, op := range []string{"PutObject", "CreateMultipartUpload"} {
+		h := f.calls(op)[0].header
+		if got := h.Get("X-Amz-Server-Side-Encryption"); got != s3.ServerSideEncryptionAwsKms {
+			t.Errorf("%s: encryption %q", op, got)
+		}
+		if got := h.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"); got != "key" {
+			t.Errorf("%s: kms key %q", op, got)
+		}
+	}
+}
+
+func TestSSECustomerKeyRoundTrip(t *testing.T) {
+	key := randomBytes(t, 32)
+	s, f := newTestStorage(t, WithPartSize(minPartSize), WithSSECustomerKey(key))
+
+	sum := md5.Sum(key)
+	keyMD5 := base64.StdEncoding.EncodeToString(sum[:])
+
+	large := randomBytes(t, 2*minPartSize)
+	for name, data := range map[string][]byte{"small": []byte("data"), "large": large} {
+		f.reset()
+
+		if err := s.Upload(name, bytes.NewReader(data)); err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		if err := s.Download(name, &buf); err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(buf.Bytes(), data) {
+			t.Errorf("%s: downloaded %d bytes, want %d", name, buf.Len(), len(data))
+		}
+
+		if _, err := s.Stat(name); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, op := range []string{"PutObject", "CreateMultipartUpload", "UploadPart", "GetObject", "HeadObject"} {
+			for _, r := range f.calls(op) {
+				if r.header.Get("X-Amz-Server-Side-Encryption-Customer-Algorithm") != "AES256" ||
+					r.header.Get("X-Amz-Server-Side-Encryption-Customer-Key") != base64.StdEncoding.EncodeToString(key) ||
+					r.header.Get("X-Amz-Server-Side-Encryption-Customer-Key-Md5") != keyMD5 {
+					t.Errorf("%s: %s without customer key", name, op)
+				}
+			}
+		}
+	}
+
+	if err := s.Copy("small", "copy"); err != nil {
+		t.Fatal(err)
+	}
+
+	h := f.calls("CopyObject")[0].header
+	if h.Get("X-Amz-Copy-Source-Server-Side-Encryption-Customer-Key-Md5") != keyMD5 ||
+		h.Get("X-Amz-Server-Side-Encryption-Customer-Key-Md5") != keyMD5 {
+		t.Error("copy without customer key")
+	}
+}
+
+func TestSSECustomerKeyMissing(t *testing.T) {
+	s, f := newTestStorage(t)
+	f.put("backups/db.sql", []byte("data"))
+
+	// server refuses to read encrypted object without the key
+	f.setHook(func(w http.ResponseWriter, r *http.Request, op string) bool {
+		if op != "GetObject" {
+			return false
+		}
+		w.WriteHeader(http.StatusBadRequest)
+
+		return true
+	})
+
+	err := s.Download("db.sql", &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "customer provided key") {
+		t.Errorf("download without key: %v", err)
+	}
+
+	if _, err := NewStorage(f.sess, testBucket, "backups", WithSSECustomerKey([]byte("short"))); err == nil {
+		t.Error("customer key of 5 bytes accepted")
+	}
+}
+
+func TestUploadStorageClass(t *testing.T) {
+	s, f := newTestStorage(t, WithPartSize(minPartSize), WithStorageClass(s3.StorageClassGlacier))
+
+	if err := s.Upload("small", strings.NewReader("data")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Upload("large", bytes.NewReader(randomBytes(t, 2*minPartSize))); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, op := range []string{"PutObject", "CreateMultipartUpload"} {
+		if got := f.calls(op)[0].header.Get("X-Amz-Storage-Class"); got != s3.StorageClassGlacier {
+			t.Errorf("%s: storage class %q", op, got)
+		}
+	}
+}
+
+func TestDownloadArchived(t *testing.T) {
+	s, f := newTestStorage(t)
+	f.put("backups/db.sql", []byte("data"))
+	f.setHook(replyError("GetObject", http.StatusForbidden, s3.ErrCodeInvalidObjectState))
+
+	if err := s.Download("db.sql", &bytes.Buffer{}); !errors.Is(err, storage.ErrObjectArchived) {
+		t.Errorf("got %v, want %v", err, storage.ErrObjectArchived)
+	}
+}
+
+func TestUploadTags(t *testing.T) {
+	tags := map[string]string{"env": "prod", "owner": "db team", "path": "a/b&c=d+e", "юникод": "значение"}
+	s, f := newTestStorage(t, WithPartSize(minPartSize), WithTags(tags))
+
+	if err := s.Upload("small", strings.NewReader("data")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Upload("large", bytes.NewReader(randomBytes(t, 2*minPartSize))); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, op := range []string{"PutObject", "CreateMultipartUpload"} {
+		v, err := url.ParseQuery(f.calls(op)[0].header.Get("X-Amz-Tagging"))
+		if err != nil {
+			t.Fatalf("%s: %v", op, err)
+		}
+
+		if len(v) != len(tags) {
+			t.Errorf("%s: tagging %v, want %v", op, v, tags)
+		}
+
+		for k, tag := range tags {
+			if v.Get(k) != tag {
+				t.Errorf("%s: tag %s %q, want %q", op, k, v.Get(k), tag)
+			}
+		}
+	}
+}
+
+func TestTagsRoundTrip(t *testing.T) {
+	s, f := newTestStorage(t)
+	f.put("backups/db.sql", []byte("data"))
+
+	// backend has no tagging, keep tag set of the object in the hook
+	var tagging []byte
+	f.setHook(func(w http.ResponseWriter, r *http.Request, op string) bool {
+		switch op {
+		case "PutObjectTagging":
+			tagging, _ = io.ReadAll(r.Body)
+		case "GetObjectTagging":
+			w.Write(tagging)
+		default:
+			return false
+		}
+
+		return true
+	})
+
+	tags := map[string]string{"env": "prod", "retention": "30d", "note": "a b&c=d"}
+	if err := s.SetTags("db.sql", tags); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.GetTags("db.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fmt.Sprint(got) != fmt.Sprint(tags) {
+		t.Errorf("got tags %v, want %v", got, tags)
+	}
+
+	if c := f.calls("PutObjectTagging"); len(c) != 1 || c[0].key != "backups/db.sql" {
+		t.Errorf("tagging requests %v", c)
+	}
+
+	f.setHook(replyError("GetObjectTagging", http.StatusNotFound, s3.ErrCodeNoSuchKey))
+	if _, err := s.GetTags("missing"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("tags of missing object: %v", err)
+	}
+}
+
+func TestMetadataRoundTrip(t *testing.T) {
+	metadata := map[string]string{"Host": "db1", "Dump-Tool": "pg_dump 13.4", "database": "users"}
+	s, _ := newTestStorage(t, WithPartSize(minPartSize), WithMetadata(metadata))
+
+	want := map[string]string{"host": "db1", "dump-tool": "pg_dump 13.4", "database": "users"}
+	for name, data := range map[string][]byte{"small": []byte("data"), "large": randomBytes(t, 2*minPartSize)} {
+		if err := s.Upload(name, bytes.NewReader(data)); err != nil {
+			t.Fatal(err)
+		}
+
+		fi, err := s.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := fi.(*FileInfo).Metadata(); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%s: metadata %v, want %v", name, got, want)
+		}
+	}
+}
+
+// deleteResult answers DeleteObjects with per object errors for keys
+// accepted by fail, other keys are reported deleted.
+func deleteResult(fail func(key string) bool) func(w http.ResponseWriter, r *http.Request, op string) bool {
+	return func(w http.ResponseWriter, r *http.Request, op string) bool {
+		if op != "DeleteObjects" {
+			return false
+		}
+
+		var req struct {
+			Objects []struct{ Key string } `xml:"Object"`
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return true
+		}
+
+		var b strings.Builder
+		b.WriteString("<DeleteResult>")
+		for _, o := range req.Objects {
+			if fail(o.Key) {
+				fmt.Fprintf(&b, "<Error><Key>%s</Key><Code>AccessDenied</Code><Message>Access Denied</Message></Error>", o.Key)
+			}
+		}
+		b.WriteString("</DeleteResult>")
+
+		w.Header().Set("Content-Type", "application/xml")
+		io.WriteString(w, b.String())
+
+		return true
+	}
+}
+
+func TestDeleteChunks(t *testing.T) {
+	s, f := newTestStorage(t)
+
+	names := make([]string, 2500)
+	for i := range names {
+		names[i] = fmt.Sprintf("db/%04d.sql", i)
+	}
+
+	if err := s.DeleteBatch(names); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := f.calls("DeleteObjects")
+	if len(calls) != 3 {
+		t.Fatalf("%d delete calls, want 3", len(calls))
+	}
+
+	// every 100th key fails
+	f.reset()
+	f.setHook(deleteResult(func(key string) bool { return strings.HasSuffix(key, "00.sql") }))
+
+	err := s.DeleteBatch(names)
+
+	var derr *DeleteError
+	if !errors.As(err, &derr) {
+		t.Fatalf("got %v, want %T", err, derr)
+	}
+
+	if len(f.calls("DeleteObjects")) != 3 {
+		t.Errorf("stopped after failed chunk")
+	}
+
+	if len(derr.Objects) != 25 {
+		t.Errorf("%d failed objects, want 25", len(derr.Objects))
+	}
+
+	for _, o := range derr.Objects {
+		if !strings.HasSuffix(o.Key, "00.sql") || o.Code != "AccessDenied" {
+			t.Errorf("failed object %+v", o)
+		}
+	}
+}
+
+func TestDeletePrefixChunks(t *testing.T) {
+	s, f := newTestStorage(t)
+	for i := 0; i < 2500; i++ {
+		f.put(fmt.Sprintf("backups/db/%04d.sql", i), nil)
+	}
+	f.reset()
+
+	if err := s.Delete("db"); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(f.calls("DeleteObjects")); n != 3 {
+		t.Errorf("%d delete calls, want 3", n)
+	}
+
+	if list, err := s.List(); err != nil || len(list) != 0 {
+		t.Errorf("left %d objects, %v", len(list), err)
+	}
+}
+
+func TestDeletePartialFailure(t *testing.T) {
+	s, f := newTestStorage(t)
+	f.setHook(deleteResult(func(key string) bool { return key == "backups/locked.sql" }))
+
+	err := s.DeleteBatch([]string{"deleted.sql", "locked.sql"})
+	if err == nil {
+		t.Fatal("partial failure reported as success")
+	}
+
+	if !strings.Contains(err.Error(), "backups/locked.sql: AccessDenied: Access Denied") {
+		t.Errorf("error %q does not mention failed key", err)
+	}
+
+	if strings.Contains(err.Error(), "deleted.sql") {
+		t.Errorf("error %q mentions deleted key", err)
+	}
+}
+
+// names returns names of listed entries in order.
+func names(fi []storage.FileInfo) []string {
+	n := make([]string, 0, len(fi))
+	for _, f := range fi {
+		n = append(n, f.Name())
+	}
+
+	return n
+}
+
+func TestListDir(t *testing.T) {
+	s, f := newTestStorage(t)
+	for _, key := range []string{"a/b/c.sql", "a/b/d/e.sql", "a/f.sql", "a/g/h.sql", "i.sql"} {
+		f.put("backups/"+key, []byte(key))
+	}
+
+	for _, tc := range []struct {
+		dir, want string
+	}{
+		{"", "i.sql a/"},
+		{"a", "a/g/ a/f.sql a/b/"},
+		{"a/", "a/g/ a/f.sql a/b/"},
+		{"a/b", "a/b/d/ a/b/c.sql"},
+		{"missing", ""},
+	} {
+		fi, err := s.ListDir(tc.dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := strings.Join(names(fi), " "); got != tc.want {
+			t.Errorf("%q: listed %s, want %s", tc.dir, got, tc.want)
+		}
+
+		for _, f := range fi {
+			if f.IsDir() != strings.HasSuffix(f.Name(), "/") {
+				t.Errorf("%s: directory %v", f.Name(), f.IsDir())
+			}
+
+			if f.IsDir() && f.Size() != 0 {
+				t.Errorf("%s: directory size %d", f.Name(), f.Size())
+			}
+
+			if !f.IsDir() && f.Size() != int64(len(f.Name())) {
+				t.Errorf("%s: size %d, want %d", f.Name(), f.Size(), len(f.Name()))
+			}
+		}
+	}
+
+	for _, r := range f.calls("ListObjects") {
+		if r.query.Get("delimiter") != "/" {
+			t.Errorf("listed with delimiter %q", r.query.Get("delimiter"))
+		}
+	}
+}
+
+func TestListFunc(t *testing.T) {
+	s, f := newTestStorage(t)
+	for i := 0; i < 2500; i++ {
+		f.put(fmt.Sprintf("backups/db/%04d.sql", i), nil)
+	}
+	f.put("backups/other.sql", nil)
+	f.reset()
+
+	seen := make(map[string]bool)
+	err := s.ListFunc("db/", func(fi storage.FileInfo) error {
+		seen[fi.Name()] = true
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(seen) != 2500 || !seen["db/0000.sql"] || !seen["db/2499.sql"] {
+		t.Errorf("visited %d objects, want 2500", len(seen))
+	}
+
+	if n := len(f.calls("ListObjects")); n != 3 {
+		t.Errorf("listed %d pages, want 3", n)
+	}
+
+	// stops on the first page
+	f.reset()
+
+	stop := errors.New("stop")
+	n := 0
+	err = s.ListFunc("db/", func(fi storage.FileInfo) error {
+		if n++; n == 10 {
+			return stop
+		}
+
+		return nil
+	})
+	if !errors.Is(err, stop) {
+		t.Errorf("got %v, want %v", err, stop)
+	}
+
+	if n != 10 {
+		t.Errorf("visited %d objects after error", n)
+	}
+
+	if n := len(f.calls("ListObjects")); n != 1 {
+		t.Errorf("listed %d pages after error, want 1", n)
+	}
+}
+
+// oldWithDirs is directory synthesis List had before withDirs, with the
+// root directory entry dropped since.
+func oldWithDirs(fi []storage.FileInfo) []storage.FileInfo {
+	sort.Slice(fi, func(i, j int) bool {
+		return fi[i].ModTime().Unix() < fi[j].ModTime().Unix()
+	})
+
+	di := make([]storage.FileInfo, 0)
+	for _, o := range fi {
+		name := path.Dir(o.Name()) + "/"
+		di = append(di, &FileInfo{name, int64(0), o.ModTime(), true, nil})
+	}
+	di = func(in []storage.FileInfo) []storage.FileInfo {
+		names := make(map[string]struct{})
+		out := make([]storage.FileInfo, 0)
+		for _, d := range in {
+			if _, ok := names[d.Name()]; !ok && d.Name() != "./" {
+				names[d.Name()] = struct{}{}
+				out = append(out, d)
+			}
+		}
+
+		return out
+	}(di)
+
+	fi = append(fi, di...)
+
+	sort.Slice(fi, func(i, j int) bool {
+		return fi[i].Name() > fi[j].Name()
+	})
+
+	return fi
+}
+
+// listing returns objects spread over nested directories.
+func listing(n int) []storage.FileInfo {
+	start := time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	fi := make([]storage.FileInfo, 0, n)
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("db%d/%02d/%05d.sql", i%5, i%31, i)
+		if i%7 == 0 {
+			name = fmt.Sprintf("%05d.sql", i)
+		}
+
+		fi = append(fi, &FileInfo{name, int64(i), start.Add(time.Duration(i) * time.Minute), false, nil})
+	}
+
+	return fi
+}
+
+func TestWithDirs(t *testing.T) {
+	got := withDirs(listing(5000))
+	want := oldWithDirs(listing(5000))
+
+	if len(got) != len(want) {
+		t.Fatalf("%d entries, want %d", len(got), len(want))
+	}
+
+	latest := make(map[string]time.Time)
+	for _, f := range got {
+		if dir := path.Dir(f.Name()) + "/"; !f.IsDir() && f.ModTime().After(latest[dir]) {
+			latest[dir] = f.ModTime()
+		}
+	}
+
+	for i := range got {
+		if got[i].Name() != want[i].Name() || got[i].IsDir() != want[i].IsDir() || got[i].Size() != want[i].Size() {
+			t.Fatalf("entry %d: %s, want %s", i, got[i].Name(), want[i].Name())
+		}
+
+		// directories now take the latest mtime of their objects
+		if got[i].IsDir() && !got[i].ModTime().Equal(latest[got[i].Name()]) {
+			t.Errorf("%s: mtime %s, want %s", got[i].Name(), got[i].ModTime(), latest[got[i].Name()])
+		}
+	}
+
+	in := listing(5000)
+	allocs := testing.AllocsPerRun(10, func() { withDirs(in[:len(in):len(in)]) })
+	oldAllocs := testing.AllocsPerRun(10, func() { oldWithDirs(append([]storage.FileInfo(nil), in...)) })
+	if allocs > oldAllocs/4 {
+		t.Errorf("%.0f allocations, old implementation made %.0f", allocs, oldAllocs)
+	}
+}
+
+func TestListGlob(t *testing.T) {
+	s, f := newTestStorage(t)
+	for _, key := range []string{"mysql-1-full.sql.gz", "mysql-2-full.sql.gz", "mysql-3-incr.sql.gz", "mysql-10-full.sql.gz", "pg/mysql-4-full.sql.gz"} {
+		f.put("backups/"+key, nil)
+	}
+	f.put("other/mysql-5-full.sql.gz", nil)
+
+	for _, tc := range []struct {
+		pattern, want string
+	}{
+		{"mysql-*-full.sql.gz", "mysql-2-full.sql.gz mysql-10-full.sql.gz mysql-1-full.sql.gz"},
+		{"mysql-?-full.sql.gz", "mysql-2-full.sql.gz mysql-1-full.sql.gz"},
+		{"mysql-[13]-*.sql.gz", "mysql-3-incr.sql.gz mysql-1-full.sql.gz"},
+		{"mysql-[^1]-*", "mysql-3-incr.sql.gz mysql-2-full.sql.gz"},
+		{"*/mysql-*", "pg/mysql-4-full.sql.gz"},
+		{"postgres-*", ""},
+	} {
+		fi, err := s.ListGlob(tc.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := strings.Join(names(fi), " "); got != tc.want {
+			t.Errorf("%s: listed %s, want %s", tc.pattern, got, tc.want)
+		}
+	}
+
+	if _, err := s.ListGlob("mysql-[1"); err == nil {
+		t.Error("malformed pattern accepted")
+	}
+}
+
+func TestListNamesRelative(t *testing.T) {
+	f := newFakeS3(t)
+
+	for _, prefix := range []string{"", "backups", "backups/", "a/b"} {
+		s, err := NewStorage(f.sess, testBucket, prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.Upload("db/dump.sql", strings.NewReader("data")); err != nil {
+			t.Fatal(err)
+		}
+
+		list, err := s.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := strings.Join(names(list), " "); got != "db/dump.sql db/" {
+			t.Errorf("prefix %q: listed %s, want db/dump.sql db/", prefix, got)
+		}
+
+		for _, fi := range list {
+			if fi.IsDir() {
+				continue
+			}
+
+			var buf bytes.Buffer
+			if err := s.Download(fi.Name(), &buf); err != nil || buf.String() != "data" {
+				t.Errorf("prefix %q: downloaded listed %s %q, %v", prefix, fi.Name(), buf.String(), err)
+			}
+
+			if st, err := s.Stat(fi.Name()); err != nil || st.Name() != fi.Name() {
+				t.Errorf("prefix %q: stat of listed %s: %v", prefix, fi.Name(), err)
+			}
+		}
+
+		if err := s.Delete("db"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAbortIncompleteUploads(t *testing.T) {
+	s, f := newTestStorage(t)
+
+	now := time.Now().UTC()
+	pages := [][]struct {
+		id  string
+		age time.Duration
+	}{
+		{{"1", 48 * time.Hour}, {"2", time.Hour}, {"3", 25 * time.Hour}},
+		{{"4", time.Minute}, {"5", 72 * time.Hour}},
+	}
+
+	f.setHook(func(w http.ResponseWriter, r *http.Request, op string) bool {
+		switch op {
+		case "ListMultipartUploads":
+			page, next := pages[0], "true"
+			if r.URL.Query().Get("upload-id-marker") != "" {
+				page, next = pages[1], "false"
+			}
+
+			var b strings.Builder
+			fmt.Fprintf(&b, "<ListMultipartUploadsResult><Bucket>%s</Bucket><IsTruncated>%s</IsTruncated>", testBucket, next)
+			fmt.Fprintf(&b, "<NextKeyMarker>backups/db%s.sql</NextKeyMarker><NextUploadIdMarker>%s</NextUploadIdMarker>", page[len(page)-1].id, page[len(page)-1].id)
+			for _, u := range page {
+				fmt.Fprintf(&b, "<Upload><Key>backups/db%s.sql</Key><UploadId>%s</UploadId><Initiated>%s</Initiated></Upload>",
+					u.id, u.id, now.Add(-u.age).Format(time.RFC3339))
+			}
+			b.WriteString("</ListMultipartUploadsResult>")
+
+			w.Header().Set("Content-Type", "application/xml")
+			io.WriteString(w, b.String())
+		case "AbortMultipartUpload":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			return false
+		}
+
+		return true
+	})
+
+	n, err := s.AbortIncompleteUploads(24 * time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 3 {
+		t.Errorf("aborted %d uploads, want 3", n)
+	}
+
+	if c := f.calls("ListMultipartUploads"); len(c) != 2 || c[0].query.Get("prefix") != "backups/" {
+		t.Errorf("listed %d pages", len(c))
+	}
+
+	var aborted []string
+	for _, r := range f.calls("AbortMultipartUpload") {
+		aborted = append(aborted, r.key+"#"+r.query.Get("uploadId"))
+	}
+
+	if got := strings.Join(aborted, " "); got != "backups/db1.sql#1 backups/db3.sql#3 backups/db5.sql#5" {
+		t.Errorf("aborted %s", got)
+	}
+}
+
+func TestNotFoundErrors(t *testing.T) {
+	s, f := newTestStorage(t)
+	f.put("backups/present", []byte("x"))
+
+	ops := map[string]func(name string) error{
+		"download": func(name string) error { return s.Download(name, &bytes.Buffer{}) },
+		"stat": func(name string) error {
+			_, err := s.Stat(name)
+			return err
+		},
+		"copy": func(name string) error { return s.Copy(name, "copy") },
+	}
+
+	for op, fn := range ops {
+		if err := fn("absent"); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("%s of absent object: got %v, want %v", op, err, storage.ErrNotFound)
+		}
+	}
+
+	f.setHook(func(w http.ResponseWriter, r *http.Request, op string) bool {
+		w.WriteHeader(http.StatusInternalServerError)
+
+		return true
+	})
+
+	for op, fn := range ops {
+		if err := fn("present"); err == nil || errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("%s with server error: got %v", op, err)
+		}
+	}
+
+	// missing bucket is not a missing object
+	f.setHook(nil)
+	s = f.storage()
+	s.bucket = "missing"
+
+	for op, fn := range ops {
+		if err := fn("present"); err == nil || errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("%s in missing bucket: got %v", op, err)
+		}
+	}
+
+	if err := s.Delete("present"); err == nil || errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("delete in missing bucket: got %v", err)
+	}
+
+	if ok, err := s.Exists("present"); err == nil || ok {
+		t.Errorf("exists in missing bucket: got %v, %v", ok, err)
+	}
+}
+
+func TestDeleteDryRun(t *testing.T) {
+	l := &testLogger{}
+	s, f := newTestStorage(t, WithDryRun(), WithLogger(l))
+	for _, key := range []string{"db/a.sql", "db/b/c.sql", "other.sql"} {
+		f.put("backups/"+key, nil)
+	}
+	f.reset()
+
+	if err := s.Delete("db"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.DeleteBatch([]string{"other.sql"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Move("other.sql", "moved.sql"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.DeleteVersion("other.sql", "v1"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, op := range []string{"DeleteObjects", "DeleteObject", "CopyObject"} {
+		if n := len(f.calls(op)); n != 0 {
+			t.Errorf("%d %s calls in dry run", n, op)
+		}
+	}
+
+	for _, key := range []string{"backups/db/a.sql", "backups/db/b/c.sql", "backups/other.sql"} {
+		if !l.logged("info", "not deleting "+key) {
+			t.Errorf("deletion of %s not logged", key)
+		}
+	}
+
+	if !l.logged("info", "not moving backups/other.sql to backups/moved.sql") {
+		t.Error("move not logged")
+	}
+
+	if !l.logged("info", "not deleting backups/other.sql version v1") {
+		t.Error("version deletion not logged")
+	}
+
+	list, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(names(list), " "); got != "other.sql db/b/c.sql db/b/ db/a.sql db/" {
+		t.Errorf("left %s", got)
+	}
+}
+
+// badETag answers CompleteMultipartUpload with etag not matching parts.
+func badETag(w http.ResponseWriter, r *http.Request, op string) bool {
+	if op != "CompleteMultipartUpload" {
+		return false
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.Header().Set("X-Amz-Version-Id", "v1")
+	fmt.Fprintf(w, `<CompleteMultipartUploadResult><Bucket>%s</Bucket><Key>backups/db.sql</Key><ETag>"%032x-2"</ETag></CompleteMultipartUploadResult>`, testBucket, 0)
+
+	return true
+}
+
+func TestUploadETagMismatch(t *testing.T) {
+	s, f := newTestStorage(t, WithPartSize(minPartSize))
+	data := randomBytes(t, 2*minPartSize)
+
+	// parts are sent with their md5 and completed upload etag matches them
+	if err := s.Upload("db.sql", bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range f.calls("UploadPart") {
+		if p.header.Get("Content-Md5") == "" {
+			t.Errorf("part %s without Content-MD5", p.query.Get("partNumber"))
+		}
+	}
+
+	f.reset()
+	f.setHook(badETag)
+
+	err := s.Upload("db.sql", bytes.NewReader(data))
+	if !errors.Is(err, storage.ErrChecksumMismatch) {
+		t.Fatalf("got %v, want %v", err, storage.ErrChecksumMismatch)
+	}
+
+	// corrupted version is removed
+	d := f.calls("DeleteObject")
+	if len(d) != 1 || d[0].key != "backups/db.sql" || d[0].query.Get("versionId") != "v1" {
+		t.Errorf("delete of corrupted object %+v", d)
+	}
+
+	f.reset()
+	s = f.storage(WithPartSize(minPartSize), WithoutETagCheck())
+	if err := s.Upload("db.sql", bytes.NewReader(data)); err != nil {
+		t.Errorf("upload without etag check: %v", err)
+	}
+
+	if d := f.calls("DeleteObject"); len(d) != 0 {
+		t.Errorf("deleted object without etag check")
+	}
+}
+
+func TestUploadNoOverwrite(t *testing.T) {
+	s, f := newTestStorage(t, WithPartSize(minPartSize), WithNoOverwrite())
+	f.put("backups/existing.sql", []byte("old"))
+	f.reset()
+
+	// created concurrently after the existence check
+	f.setHook(replyError("PutObject", http.StatusPreconditionFailed, "PreconditionFailed"))
+	if err := s.Upload("raced.sql", strings.NewReader("data")); !errors.Is(err, storage.ErrAlreadyExists) {
+		t.Errorf("native precondition: got %v, want %v", err, storage.ErrAlreadyExists)
+	}
+
+	if h := f.calls("PutObject")[0].header.Get("If-None-Match"); h != "*" {
+		t.Errorf("put with If-None-Match %q", h)
+	}
+
+	// server ignoring the precondition
+	f.reset()
+	f.setHook(nil)
+	if err := s.Upload("existing.sql", strings.NewReader("data")); !errors.Is(err, storage.ErrAlreadyExists) {
+		t.Errorf("fallback check: got %v, want %v", err, storage.ErrAlreadyExists)
+	}
+
+	if len(f.calls("HeadObject")) != 1 || len(f.calls("PutObject")) != 0 {
+		t.Errorf("existing object is put")
+	}
+
+	if got := f.get("backups/existing.sql"); string(got) != "old" {
+		t.Errorf("existing object overwritten with %q", got)
+	}
+
+	f.reset()
+	if err := s.Upload("new.sql", bytes.NewReader(randomBytes(t, 2*minPartSize))); err != nil {
+		t.Fatal(err)
+	}
+
+	if h := f.calls("CompleteMultipartUpload")[0].header.Get("If-None-Match"); h != "*" {
+		t.Errorf("complete with If-None-Match %q", h)
+	}
+}
+
+func TestUploadNoOverwriteCanceled(t *testing.T) {
+	s, f := newTestStorage(t, WithNoOverwrite())
+	f.setHook(stall("HeadObject", 2*time.Second))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := s.UploadContext(ctx, "a.sql", strings.NewReader("data")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("canceled existence check took %s", d)
+	}
+
+	if n := len(f.calls("PutObject")); n != 0 {
+		t.Errorf("%d puts after canceled existence check", n)
+	}
+}
+
+// bodyCounter counts response bodies not closed yet.
+type bodyCounter struct {
+	http.RoundTripper
+	open atomic.Int64
+}
+
+type countedBody struct {
+	io.ReadCloser
+	c    *bodyCounter
+	once sync.Once
+}
+
+func (c *bodyCounter) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, err := c.RoundTripper.RoundTrip(r)
+	if err == nil {
+		c.open.Add(1)
+		resp.Body = &countedBody{ReadCloser: resp.Body, c: c}
+	}
+
+	return resp, err
+}
+
+func (b *countedBody) Close() error {
+	b.once.Do(func() { b.c.open.Add(-1) })
+
+	return b.ReadCloser.Close()
+}
+
+func TestOpen(t *testing.T) {
+	s, f := newTestStorage(t)
+	data := randomBytes(t, 4<<20)
+	f.put("backups/db.sql", data)
+
+	c := &bodyCounter{RoundTripper: f.client.Transport}
+	f.client.Transport = c
+	t.Cleanup(func() { f.client.Transport = c.RoundTripper })
+
+	for i := 0; i < 10; i++ {
+		r, err := s.Open("db.sql")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// stream is read lazily and closed early
+		head := make([]byte, 1024)
+		if _, err := io.ReadFull(r, head); err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(head, data[:1024]) {
+			t.Error("read wrong data")
+		}
+
+		if err := r.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := c.open.Load(); n != 0 {
+		t.Errorf("%d response bodies left open", n)
+	}
+
+	r, err := s.Open("db.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if got, err := io.ReadAll(r); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("read %d bytes, %v, want %d", len(got), err, len(data))
+	}
+
+	if _, err := s.Open("missing"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("open of missing object: %v", err)
+	}
+}
+
+func TestUploadContentTypeConsistent(t *testing.T) {
+	large := append([]byte("%PDF-1.4\n"), randomBytes(t, 2*minPartSize)...)
+	small := large[:1000]
+
+	for _, tc := range []struct {
+		opts []Option
+		want string
+	}{
+		{nil, "application/pdf"},
+		{[]Option{WithContentType("application/x-custom")}, "application/x-custom"},
+	} {
+		s, f := newTestStorage(t, append(tc.opts, WithPartSize(minPartSize))...)
+
+		if err := s.Upload("small", bytes.NewReader(small)); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.Upload("large", bytes.NewReader(large)); err != nil {
+			t.Fatal(err)
+		}
+
+		put := f.calls("PutObject")[0].header.Get("Content-Type")
+		multipart := f.calls("CreateMultipartUpload")[0].header.Get("Content-Type")
+		if put != tc.want || multipart != tc.want {
+			t.Errorf("content type of put %q and of multipart upload %q, want %q", put, multipart, tc.want)
+		}
+	}
+}
+
+func TestPutObjectContentType(t *testing.T) {
+	s, f := newTestStorage(t)
+
+	for _, tc := range []struct {
+		name, data, want string
+	}{
+		{"manifest.json", `{"a": 1}`, "application/json"},
+		{"dump", "CREATE TABLE users (id int);\n", "text/plain; charset=utf-8"},
+		{"archive", "\x1f\x8b\x08\x00", "application/x-gzip"},
+		{"empty", "", "application/octet-stream"},
+	} {
+		f.reset()
+
+		if err := s.Upload(tc.name, strings.NewReader(tc.data)); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := f.calls("PutObject")[0].header.Get("Content-Type"); got != tc.want {
+			t.Errorf("%s: content type %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestAppend(t *testing.T) {
+	s, f := newTestStorage(t, WithPartSize(minPartSize), WithNoOverwrite())
+
+	// new object
+	if err := s.Append("log", strings.NewReader("first ")); err != nil {
+		t.Fatal(err)
+	}
+
+	// small existing object is uploaded again with appended data
+	if err := s.Append("log", strings.NewReader("second")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := f.get("backups/log"); string(got) != "first second" {
+		t.Errorf("appended object %q", got)
+	}
+
+	// nothing to append
+	if err := s.Append("log", strings.NewReader("")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := f.get("backups/log"); string(got) != "first second" {
+		t.Errorf("object after empty append %q", got)
+	}
+}
+
+func TestAppendLargeObject(t *testing.T) {
+	s, f := newTestStorage(t, WithPartSize(minPartSize))
+
+	size := int64(12 << 20)
+	f.setHook(largeObject("backups/log", size))
+
+	if err := s.Append("log", strings.NewReader("tail")); err != nil {
+		t.Fatal(err)
+	}
+
+	// short tail of existing object is merged into the previous range
+	copies := f.calls("UploadPartCopy")
+	want := []string{fmt.Sprintf("bytes=0-%d", minPartSize-1), fmt.Sprintf("bytes=%d-%d", minPartSize, size-1)}
+	if len(copies) != len(want) {
+		t.Fatalf("%d copied parts, want %d", len(copies), len(want))
+	}
+
+	for i, c := range copies {
+		if rg := c.header.Get("X-Amz-Copy-Source-Range"); rg != want[i] {
+			t.Errorf("part %d: range %s, want %s", i+1, rg, want[i])
+		}
+
+		if src, _ := url.PathUnescape(c.header.Get("X-Amz-Copy-Source")); src != testBucket+"/backups/log" {
+			t.Errorf("part %d: copy source %s", i+1, src)
+		}
+	}
+
+	parts := f.calls("UploadPart")
+	if len(parts) != 1 || parts[0].query.Get("partNumber") != "3" || parts[0].size != 4 {
+		t.Errorf("uploaded parts %+v", parts)
+	}
+
+	if len(f.calls("CompleteMultipartUpload")) != 1 || len(f.calls("AbortMultipartUpload")) != 0 {
+		t.Error("append is not completed")
+	}
+
+	// failed part aborts upload leaving object intact
+	f.reset()
+	f.setHook(func(w http.ResponseWriter, r *http.Request, op string) bool {
+		if op == "UploadPart" {
+			w.WriteHeader(http.StatusForbidden)
+
+			return true
+		}
+
+		return largeObject("backups/log", size)(w, r, op)
+	})
+
+	if err := s.Append("log", strings.NewReader("tail")); err == nil {
+		t.Fatal("append with failed part succeeded")
+	}
+
+	if len(f.calls("AbortMultipartUpload")) != 1 || len(f.calls("CompleteMultipartUpload")) != 0 {
+		t.Error("failed append is not aborted")
+	}
+}
+
+func TestUploadACL(t *testing.T) {
+	s, f := newTestStorage(t, WithPartSize(minPartSize), WithACL(s3.ObjectCannedACLBucketOwnerFullControl))
+
+	if err := s.Upload("small", strings.NewReader("data")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Upload("large", bytes.NewReader(randomBytes(t, 2*minPartSize))); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, op := range []string{"PutObject", "CreateMultipartUpload"} {
+		c := f.calls(op)
+		if len(c) != 1 {
+			t.Fatalf("%d %s requests", len(c), op)
+		}
+
+		if got := c[0].header.Get("X-Amz-Acl"); got != s3.ObjectCannedACLBucketOwnerFullControl {
+			t.Errorf("%s: acl %q", op, got)
+		}
+	}
+}
+
+func TestUploadWithResult(t *testing.T) {
+	s, f := newTestStorage(t, WithPartSize(minPartSize))
+	// the fake keeps no versions, headers set ahead are kept in reply
+	f.setHook(func(w http.ResponseWriter, r *http.Request, op string) bool {
+		if op == "PutObject" {
+			w.Header().Set("X-Amz-Version-Id", "v7")
+		}
+
+		return false
+	})
+
+	data := []byte("data")
+	res, err := s.UploadWithResult("db/small.sql", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := UploadResult{"backups/db/small.sql", fmt.Sprintf(`"%x"`, md5.Sum(data)), "v7", 4}
+	if res != want {
+		t.Errorf("result %+v, want %+v", res, want)
+	}
+
+	data = randomBytes(t, 2*minPartSize+1)
+	res, err = s.UploadWithResult("db/large.sql", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Key != "backups/db/large.sql" || res.Size != int64(len(data)) || !strings.HasSuffix(res.ETag, `-3"`) {
+		t.Errorf("multipart result %+v", res)
+	}
+}
+
+func TestUploadEmpty(t *testing.T) {
+	s, f := newTestStorage(t)
+
+	if err := s.Upload("empty.sql", strings.NewReader("")); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(f.calls("PutObject")); n != 1 {
+		t.Errorf("%d put requests, want 1", n)
+	}
+
+	if n := len(f.calls("CreateMultipartUpload")); n != 0 {
+		t.Errorf("%d multipart uploads of empty object", n)
+	}
+
+	fi, err := s.Stat("empty.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fi.Size() != 0 || fi.IsDir() {
+		t.Errorf("stat size %d, directory %v", fi.Size(), fi.IsDir())
+	}
+
+	var buf bytes.Buffer
+	if err := s.Download("empty.sql", &buf); err != nil || buf.Len() != 0 {
+		t.Errorf("downloaded %d bytes, %v", buf.Len(), err)
+	}
+}
+
+func TestCopyPrefix(t *testing.T) {
+	s, f := newTestStorage(t, WithConcurrency(3))
+	for _, key := range []string{"backups/daily/a.sql", "backups/daily/nested/b.sql", "backups/daily/big.sql", "backups/daily2/c.sql", "other/daily/d.sql"} {
+		f.put(key, []byte(key))
+	}
+	// big object is copied in parts
+	f.setHook(largeObject("backups/daily/big.sql", maxPartSize+1))
+
+	if err := s.CopyPrefix("daily/", "archive/2021/"); err != nil {
+		t.Fatal(err)
+	}
+
+	sources := make(map[string]string)
+	for _, c := range append(f.calls("CopyObject"), f.calls("UploadPartCopy")...) {
+		src, _ := url.PathUnescape(c.header.Get("X-Amz-Copy-Source"))
+		if prev, ok := sources[c.key]; ok && prev != src {
+			t.Errorf("%s copied from %s and %s", c.key, prev, src)
+		}
+		sources[c.key] = src
+	}
+
+	want := map[string]string{
+		"backups/archive/2021/a.sql":        testBucket + "/backups/daily/a.sql",
+		"backups/archive/2021/nested/b.sql": testBucket + "/backups/daily/nested/b.sql",
+		"backups/archive/2021/big.sql":      testBucket + "/backups/daily/big.sql",
+	}
+	if len(sources) != len(want) {
+		t.Errorf("copied %v, want %v", sources, want)
+	}
+
+	for dst, src := range want {
+		if sources[dst] != src {
+			t.Errorf("%s copied from %q, want %s", dst, sources[dst], src)
+		}
+	}
+
+	if got := f.get("backups/archive/2021/nested/b.sql"); string(got) != "backups/daily/nested/b.sql" {
+		t.Errorf("copied %q", got)
+	}
+
+	if n := len(f.calls("CompleteMultipartUpload")); n != 1 {
+		t.Errorf("%d multipart copies, want 1", n)
+	}
+}
+
+func TestCopyPrefixFailure(t *testing.T) {
+	s, f := newTestStorage(t)
+	f.put("backups/daily/a.sql", []byte("a"))
+	f.setHook(failOp("CopyObject"))
+
+	if err := s.CopyPrefix("daily/", "archive/"); err == nil || !strings.Contains(err.Error(), "copy daily/a.sql") {
+		t.Errorf("failed copy reported as %v", err)
+	}
+}
+
+func TestCopyPrefixTraversal(t *testing.T) {
+	s, f := newTestStorage(t)
+	f.put("backups/daily/a.sql", []byte("a"))
+	f.reset()
+
+	for _, tc := range []struct{ src, dst string }{
+		{"daily/", "../other/"},
+		{"daily/", "archive/../../other/"},
+		{"../backups/daily/", "archive/"},
+		{"daily/", ".."},
+	} {
+		if err := s.CopyPrefix(tc.src, tc.dst); !errors.Is(err, storage.ErrInvalidName) {
+			t.Errorf("copy %s to %s: got %v, want %v", tc.src, tc.dst, err, storage.ErrInvalidName)
+		}
+	}
+
+	if n := len(f.calls("CopyObject")); n != 0 {
+		t.Errorf("%d objects copied", n)
+	}
+
+	// leading slash is dropped like by other methods
+	if err := s.CopyPrefix("daily/", "/archive/"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := f.get("backups/archive/a.sql"); string(got) != "a" {
+		t.Errorf("copied %q to backups/archive/a.sql", got)
+	}
+}
+
+func TestSub(t *testing.T) {
+	s, f := newTestStorage(t)
+	f.put("backups/other.sql", []byte("other"))
+	f.put("backups/dbx/a.sql", []byte("dbx"))
+
+	st, err := s.Sub("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := st.Upload("a.sql", strings.NewReader("a")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := f.get("backups/db/a.sql"); string(got) != "a" {
+		t.Errorf("stored %q at backups/db/a.sql", got)
+	}
+
+	nested, err := st.(*S3).Sub("nested/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := nested.Upload("b.sql", strings.NewReader("b")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := f.get("backups/db/nested/b.sql"); string(got) != "b" {
+		t.Errorf("stored %q at backups/db/nested/b.sql", got)
+	}
+
+	// sibling sharing the name as prefix is not listed
+	fi, err := st.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(names(fi), " "); got != "nested/b.sql nested/ a.sql" {
+		t.Errorf("listed %s", got)
+	}
+
+	if err := st.Delete("nested"); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := s.Exists("db/nested/b.sql"); err != nil || ok {
+		t.Errorf("deleted object exists %v, %v", ok, err)
+	}
+
+	// parent storage is not changed
+	if ok, err := s.Exists("other.sql"); err != nil || !ok {
+		t.Errorf("parent object exists %v, %v", ok, err)
+	}
+
+	for _, prefix := range []string{"", "..", "../other", "db/../.."} {
+		if _, err := s.Sub(prefix); err == nil {
+			t.Errorf("sub storage of %q", prefix)
+		}
+	}
+}
+
+func TestNameTraversal(t *testing.T) {
+	s, f := newTestStorage(t)
+	f.put("other/x", []byte("other"))
+	f.reset()
+
+	for _, name := range []string{"../other/x", "db/../../other/x", ".."} {
+		if err := s.Upload(name, strings.NewReader("evil")); !errors.Is(err, storage.ErrInvalidName) {
+			t.Errorf("upload %s: %v", name, err)
+		}
+
+		if err := s.Download(name, &bytes.Buffer{}); !errors.Is(err, storage.ErrInvalidName) {
+			t.Errorf("download %s: %v", name, err)
+		}
+
+		if err := s.Delete(name); !errors.Is(err, storage.ErrInvalidName) {
+			t.Errorf("delete %s: %v", name, err)
+		}
+	}
+
+	// allowed names stay under the prefix
+	if err := s.Upload("/db/../x", strings.NewReader("x")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range f.calls("PutObject") {
+		if c.key != "backups/x" {
+			t.Errorf("put %s", c.key)
+		}
+	}
+
+	if got := f.get("other/x"); string(got) != "other" {
+		t.Errorf("object outside prefix is %q", got)
+	}
+}
+
+func TestSpecialCharacterNames(t *testing.T) {
+	s, f := newTestStorage(t)
+	specials := []string{
+		"db/with space.sql",
+		"db/a+b.sql",
+		"db/100%.sql",
+		"db/hash#1.sql",
+		"db/query?x=1&y.sql",
+		"db/юникод/💾.sql",
+		"db/tilde~and'quote.sql",
+	}
+
+	for _, name := range specials {
+		if err := s.Upload(name, strings.NewReader(name)); err != nil {
+			t.Fatalf("upload %s: %v", name, err)
+		}
+
+		var buf bytes.Buffer
+		if err := s.Download(name, &buf); err != nil || buf.String() != name {
+			t.Errorf("download %s: %q, %v", name, buf.String(), err)
+		}
+
+		if fi, err := s.Stat(name); err != nil || fi.Name() != name {
+			t.Errorf("stat %s: %v, %v", name, fi, err)
+		}
+
+		if got := f.get("backups/" + name); string(got) != name {
+			t.Errorf("stored %s as %q", name, got)
+		}
+
+		if err := s.Copy(name, name+".copy"); err != nil {
+			t.Errorf("copy %s: %v", name, err)
+		}
+
+		// presigned urls are fetched by the exact name too
+		u, err := s.PresignDownload(name, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := f.client.Get(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK || string(b) != name {
+			t.Errorf("presigned download %s: %s %q", name, resp.Status, b)
+		}
+	}
+
+	fi, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listed := make(map[string]bool)
+	for _, f := range fi {
+		listed[f.Name()] = true
+	}
+
+	for _, name := range specials {
+		if !listed[name] || !listed[name+".copy"] {
+			t.Errorf("%s or its copy is not listed", name)
+		}
+
+		if err := s.Delete(name + ".copy"); err != nil {
+			t.Errorf("delete %s: %v", name, err)
+		}
+
+		if ok, err := s.Exists(name + ".copy"); err != nil || ok {
+			t.Errorf("deleted %s exists %v, %v", name, ok, err)
+		}
+	}
+
+	if err := s.DeleteBatch(specials); err != nil {
+		t.Fatal(err)
+	}
+
+	if fi, err := s.List(); err != nil || len(fi) != 0 {
+		t.Errorf("left %v, %v", names(fi), err)
+	}
+}
+
+func TestUploadEntityTooSmall(t *testing.T) {
+	s, f := newTestStorage(t, WithPartSize(minPartSize))
+	f.setHook(replyError("CompleteMultipartUpload", http.StatusBadRequest, "EntityTooSmall"))
+
+	err := s.Upload("db.sql", bytes.NewReader(randomBytes(t, 2*minPartSize)))
+	if err == nil {
+		t.Fatal("rejected upload succeeded")
+	}
+
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) || aerr.Code() != "EntityTooSmall" {
+		t.Errorf("error %v does not wrap the s3 one", err)
+	}
+
+	if !strings.Contains(err.Error(), "backups/db.sql: part other than the last one is smaller") {
+		t.Errorf("error %q does not explain the reason", err)
+	}
+}
+
+func TestUploadGrowingStream(t *testing.T) {
+	s, f := newTestStorage(t, WithPartSize(minPartSize))
+
+	// seekable stream reporting less than is read, like a file growing
+	// while it is uploaded
+	data := randomBytes(t, 3*minPartSize+10)
+	r := &growingReader{bytes.NewReader(data), minPartSize / 2}
+	if err := s.Upload("db.sql", r); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := f.get("backups/db.sql"); !bytes.Equal(got, data) {
+		t.Fatalf("uploaded %d bytes, want %d", len(got), len(data))
+	}
+
+	parts := f.calls("UploadPart")
+	for _, p := range parts[:len(parts)-1] {
+		if p.size != minPartSize {
+			t.Errorf("non-final part of %d bytes, want %d", p.size, minPartSize)
+		}
+	}
+}
+
+// growingReader reports size smaller by missing than its reader has.
+type growingReader struct {
+	*bytes.Reader
+	missing int64
+}
+
+func (r *growingReader) Seek(offset int64, whence int) (int64, error) {
+	n, err := r.Reader.Seek(offset, whence)
+	if whence == io.SeekEnd {
+		n -= r.missing
+		if _, err := r.Reader.Seek(n, io.SeekStart); err != nil {
+			return 0, err
+		}
+	}
+
+	return n, err
+}
+
+// notModifiedSince answers conditional GetObject with 304 unless its
+// If-Modified-Since is before mtime.
+func notModifiedSince(mtime time.Time) func(w http.ResponseWriter, r *http.Request, op string) bool {
+	return func(w http.ResponseWriter, r *http.Request, op string) bool {
+		since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+		if op != "GetObject" || err != nil || since.Before(mtime) {
+			return false
+		}
+
+		w.WriteHeader(http.StatusNotModified)
+
+		return true
+	}
+}
+
+func TestDownloadIfModified(t *testing.T) {
+	s, f := newTestStorage(t)
+	f.put("backups/db.sql", []byte("data"))
+	mtime := time.Date(2021, 10, 10, 3, 0, 0, 0, time.UTC)
+	f.setHook(notModifiedSince(mtime))
+
+	var buf bytes.Buffer
+	ok, err := s.DownloadIfModified("db.sql", mtime.Add(time.Hour), &buf)
+	if err != nil || ok || buf.Len() != 0 {
+		t.Errorf("not modified: downloaded %v, %q, %v", ok, buf.String(), err)
+	}
+
+	ok, err = s.DownloadIfModified("db.sql", mtime.Add(-time.Hour), &buf)
+	if err != nil || !ok || buf.String() != "data" {
+		t.Errorf("modified: downloaded %v, %q, %v", ok, buf.String(), err)
+	}
+
+	for _, c := range f.calls("GetObject") {
+		if c.header.Get("If-Modified-Since") == "" {
+			t.Error("download without If-Modified-Since")
+		}
+	}
+
+	if _, err := s.DownloadIfModified("missing", mtime.Add(-time.Hour), &buf); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("download of missing object: %v", err)
+	}
+}
+
+func TestUploadETag(t *testing.T) {
+	s, f := newTestStorage(t, WithPartSize(minPartSize))
+
+	data := randomBytes(t, 2*minPartSize+10)
+	res, err := s.UploadWithResult("db.sql", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// md5 of concatenated md5 sums of parts
+	h := md5.New()
+	for off := int64(0); off < int64(len(data)); off += minPartSize {
+		sum := md5.Sum(data[off:min(off+minPartSize, int64(len(data)))])
+		h.Write(sum[:])
+	}
+	want := fmt.Sprintf(`"%x-3"`, h.Sum(nil))
+
+	if res.ETag != want {
+		t.Errorf("etag %s, want %s", res.ETag, want)
+	}
+
+	// etag not returned by server is computed
+	f.setHook(func(w http.ResponseWriter, r *http.Request, op string) bool {
+		if op != "CompleteMultipartUpload" {
+			return false
+		}
+
+		fmt.Fprint(w, `<CompleteMultipartUploadResult></CompleteMultipartUploadResult>`)
+
+		return true
+	})
+
+	res, err = s.UploadWithResult("db.sql", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.ETag != want {
+		t.Errorf("computed etag %s, want %s", res.ETag, want)
+	}
+}
+
+func TestListPage(t *testing.T) {
+	s, f := newTestStorage(t)
+	var want []string
+	for _, name := range []string{"a/1.sql", "a/2.sql", "a/3.sql", "b/4.sql", "b/5.sql", "c/6.sql", "c/7.sql"} {
+		f.put("backups/"+name, []byte(name))
+		want = append(want, name)
+	}
+	f.put("other/8.sql", nil)
+
+	var got, pages []string
+	token := ""
+	for i := 0; ; i++ {
+		if i > 10 {
+			t.Fatal("listing does not end")
+		}
+
+		fi, next, err := s.ListPage("", token, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// directories are of the page objects only
+		var objects []string
+		dirs := make(map[string]bool)
+		for _, f := range fi {
+			if f.IsDir() {
+				dirs[f.Name()] = true
+			} else {
+				objects = append(objects, f.Name())
+			}
+		}
+
+		for _, name := range objects {
+			delete(dirs, name[:strings.IndexByte(name, '/')+1])
+		}
+		if len(dirs) != 0 {
+			t.Errorf("page %d: directories %v without objects", i, dirs)
+		}
+
+		sort.Strings(objects)
+		got = append(got, objects...)
+		pages = append(pages, strings.Join(names(fi), " "))
+
+		if next == "" {
+			break
+		}
+		token = next
+	}
+
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("paged %v, want %v", got, want)
+	}
+
+	if len(pages) != 3 || pages[0] != "a/3.sql a/2.sql a/1.sql a/" {
+		t.Errorf("pages %q", pages)
+	}
+
+	if fi, next, err := s.ListPage("b/", "", 0); err != nil || next != "" || strings.Join(names(fi), " ") != "b/5.sql b/4.sql b/" {
+		t.Errorf("listed %v, next %q, %v", names(fi), next, err)
+	}
+}
+
+// exercise runs requests of every kind the storage sends to the fake.
+func exercise(t *testing.T, s *S3) {
+	t.Helper()
+
+	if err := s.Upload("small.sql", strings.NewReader("data")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Upload("large.sql", bytes.NewReader(randomBytes(t, minPartSize+1))); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Download("small.sql", &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Stat("small.sql"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.List(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Copy("small.sql", "copy.sql"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Delete("copy.sql"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.DeleteBatch([]string{"small.sql", "large.sql"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRequesterPays(t *testing.T) {
+	s, f := newTestStorage(t, WithPartSize(minPartSize), WithRequesterPays())
+	exercise(t, s)
+
+	ops := make(map[string]bool)
+	for _, r := range f.calls("") {
+		ops[r.op] = true
+		if got := r.header.Get("X-Amz-Request-Payer"); got != "requester" {
+			t.Errorf("%s %s: request payer %q", r.op, r.key, got)
+		}
+	}
+
+	for _, op := range []string{"PutObject", "CreateMultipartUpload", "UploadPart", "CompleteMultipartUpload", "GetObject", "HeadObject", "ListObjects", "CopyObject", "DeleteObjects"} {
+		if !ops[op] {
+			t.Errorf("no %s request", op)
+		}
+	}
+
+	// presigned url requires the header from its clients
+	u, err := s.PresignDownload("small.sql", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pu, _ := url.Parse(u)
+	if signed := pu.Query().Get("X-Amz-SignedHeaders"); !strings.Contains(signed, "x-amz-request-payer") {
+		t.Errorf("signed headers %s", signed)
+	}
+
+	f.reset()
+	exercise(t, f.storage(WithPartSize(minPartSize)))
+	for _, r := range f.calls("") {
+		if got := r.header.Get("X-Amz-Request-Payer"); got != "" {
+			t.Errorf("%s: request payer %q by default", r.op, got)
+		}
+	}
+}
+
+func TestExpectedBucketOwner(t *testing.T) {
+	s, f := newTestStorage(t, WithPartSize(minPartSize), WithExpectedBucketOwner("111122223333"))
+	exercise(t, s)
+
+	for _, r := range f.calls("") {
+		if got := r.header.Get("X-Amz-Expected-Bucket-Owner"); got != "111122223333" {
+			t.Errorf("%s %s: expected owner %q", r.op, r.key, got)
+		}
+
+		source := r.header.Get("X-Amz-Source-Expected-Bucket-Owner")
+		if copied := r.op == "CopyObject" || r.op == "UploadPartCopy"; copied != (source == "111122223333") {
+			t.Errorf("%s %s: expected source owner %q", r.op, r.key, source)
+		}
+	}
+
+	if n := len(f.calls("CopyObject")); n != 1 {
+		t.Errorf("%d copy requests, want 1", n)
+	}
+
+	// bucket of another account is reported as access denied
+	f.setHook(replyError("HeadObject", http.StatusForbidden, "AccessDenied"))
+	if _, err := s.Stat("small.sql"); err == nil || errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("stat in bucket of another owner: %v", err)
+	}
+
+	for _, id := range []string{"", "12345", "1111222233334", "11112222333a"} {
+		if _, err := NewStorage(f.sess, testBucket, "", WithExpectedBucketOwner(id)); err == nil {
+			t.Errorf("account id %q accepted", id)
+		}
+	}
+}
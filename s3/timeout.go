@@ -0,0 +1,45 @@
+package s3
+
+import (
+	"context"
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// cancelBody releases context of request once its response body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	defer b.cancel()
+
+	return b.ReadCloser.Close()
+}
+
+// setTimeout limits request along with sdk retries of it to the operation
+// timeout. Every call makes a request of its own, so parts of multipart
+// uploads and attempts of WithRetry are limited separately. Presigned
+// requests are never sent nor completed, so they are left as they are.
+func (s *S3) setTimeout(r *request.Request) {
+	if s.opTimeout <= 0 || r.IsPresigned() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.opTimeout)
+	r.SetContext(ctx)
+
+	r.Handlers.Complete.PushBack(func(r *request.Request) {
+		// object body is read after the request completes
+		if o, ok := r.Data.(*s3.GetObjectOutput); ok && r.Error == nil && o.Body != nil {
+			o.Body = &cancelBody{o.Body, cancel}
+
+			return
+		}
+
+		cancel()
+	})
+}
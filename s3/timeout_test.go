@@ -0,0 +1,118 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// stall holds requests of the operation for d, GetObject ones after
+// sending headers, so the body stalls.
+func stall(op string, d time.Duration) func(w http.ResponseWriter, r *http.Request, op string) bool {
+	return func(w http.ResponseWriter, r *http.Request, o string) bool {
+		if o != op {
+			return false
+		}
+
+		if op == "GetObject" {
+			w.Header().Set("Content-Length", "4")
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+		}
+
+		select {
+		case <-time.After(d):
+		case <-r.Context().Done():
+		}
+
+		return true
+	}
+}
+
+func TestOperationTimeout(t *testing.T) {
+	for _, op := range []string{"PutObject", "UploadPart", "GetObject", "HeadObject"} {
+		s, f := newTestStorage(t, WithPartSize(minPartSize), WithOperationTimeout(100*time.Millisecond))
+		f.put("backups/existing", []byte("data"))
+		f.setHook(stall(op, 2*time.Second))
+
+		start := time.Now()
+		var err error
+		switch op {
+		case "PutObject":
+			err = s.Upload("a", strings.NewReader("data"))
+		case "UploadPart":
+			err = s.Upload("a", bytes.NewReader(randomBytes(t, 2*minPartSize)))
+		case "GetObject":
+			err = s.Download("existing", &bytes.Buffer{})
+		case "HeadObject":
+			_, err = s.Stat("existing")
+		}
+
+		// sdk wraps the context error as a string
+		if err == nil || !errors.Is(err, context.DeadlineExceeded) && !strings.Contains(err.Error(), "deadline exceeded") {
+			t.Errorf("%s: got %v, want timeout", op, err)
+		}
+
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("%s: timed out in %s", op, d)
+		}
+	}
+}
+
+func TestOperationTimeoutPerPart(t *testing.T) {
+	// every part takes most of the timeout, the whole upload exceeds it
+	s, f := newTestStorage(t, WithPartSize(minPartSize), WithConcurrency(1), WithOperationTimeout(time.Second))
+	f.setHook(func(w http.ResponseWriter, r *http.Request, op string) bool {
+		if op == "UploadPart" {
+			time.Sleep(400 * time.Millisecond)
+		}
+
+		return false
+	})
+
+	data := randomBytes(t, 4*minPartSize)
+	if err := s.Upload("a", bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := f.get("backups/a"); !bytes.Equal(got, data) {
+		t.Errorf("uploaded %d bytes, want %d", len(got), len(data))
+	}
+
+	if _, err := NewStorage(f.sess, testBucket, "", WithOperationTimeout(0)); err == nil {
+		t.Error("zero timeout accepted")
+	}
+}
+
+func TestOperationTimeoutPresign(t *testing.T) {
+	s, _ := newTestStorage(t, WithOperationTimeout(time.Minute))
+
+	// presigned requests are never completed to release the timeout
+	var limited []string
+	s.c.Handlers.Validate.PushBack(func(r *request.Request) {
+		if _, ok := r.Context().Deadline(); ok {
+			limited = append(limited, fmt.Sprintf("%s presigned %v", r.Operation.Name, r.IsPresigned()))
+		}
+	})
+
+	for _, presign := range []func(string, time.Duration) (string, error){s.PresignDownload, s.PresignUpload} {
+		if _, err := presign("a", time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.Upload("a", strings.NewReader("data")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(limited, ", "); got != "PutObject presigned false" {
+		t.Errorf("limited %s, want PutObject presigned false", got)
+	}
+}
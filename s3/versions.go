@@ -0,0 +1,109 @@
+package s3
+
+import (
+	"context"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/sputnik-systems/backups-storage"
+)
+
+type ObjectVersion struct {
+	Name           string
+	VersionID      string
+	Size           int64
+	ModTime        time.Time
+	IsLatest       bool
+	IsDeleteMarker bool
+}
+
+// ListVersions returns versions and delete markers of objects with the name
+// prefix in versioned bucket, newest versions of object go first.
+func (s *S3) ListVersions(prefix string) ([]ObjectVersion, error) {
+	in := &s3.ListObjectVersionsInput{
+		Bucket:       aws.String(s.bucket),
+		Prefix:       aws.String(s.key(prefix)),
+		EncodingType: aws.String(s3.EncodingTypeUrl),
+	}
+
+	ov := make([]ObjectVersion, 0)
+	err := s.c.ListObjectVersionsPagesWithContext(context.Background(), in, func(page *s3.ListObjectVersionsOutput, last bool) bool {
+		for _, v := range page.Versions {
+			ov = append(ov, ObjectVersion{
+				Name:      s.relName(listedKey(page.EncodingType, v.Key)),
+				VersionID: aws.StringValue(v.VersionId),
+				Size:      aws.Int64Value(v.Size),
+				ModTime:   aws.TimeValue(v.LastModified),
+				IsLatest:  aws.BoolValue(v.IsLatest),
+			})
+		}
+
+		for _, m := range page.DeleteMarkers {
+			ov = append(ov, ObjectVersion{
+				Name:           s.relName(listedKey(page.EncodingType, m.Key)),
+				VersionID:      aws.StringValue(m.VersionId),
+				ModTime:        aws.TimeValue(m.LastModified),
+				IsLatest:       aws.BoolValue(m.IsLatest),
+				IsDeleteMarker: true,
+			})
+		}
+
+		return !last
+	})
+	if err != nil {
+		return ov, err
+	}
+
+	// versions and delete markers come in separate lists
+	sort.SliceStable(ov, func(i, j int) bool {
+		if ov[i].Name != ov[j].Name {
+			return ov[i].Name < ov[j].Name
+		}
+
+		return ov[i].ModTime.After(ov[j].ModTime)
+	})
+
+	return ov, nil
+}
+
+// DownloadVersion downloads the version of object.
+func (s *S3) DownloadVersion(name, versionID string, buf io.Writer) error {
+	key, err := storage.ObjectKey(s.prefix, name)
+	if err != nil {
+		return err
+	}
+
+	in := s.getObjectInput(key)
+	in.VersionId = aws.String(versionID)
+
+	return s.download(context.Background(), in, buf)
+}
+
+// DeleteVersion permanently deletes the version or delete marker of object.
+func (s *S3) DeleteVersion(name, versionID string) error {
+	key, err := storage.ObjectKey(s.prefix, name)
+	if err != nil {
+		return err
+	}
+
+	if s.dryRun {
+		s.log.Infof("dry run, not deleting %s version %s", key, versionID)
+
+		return nil
+	}
+
+	in := &s3.DeleteObjectInput{
+		Bucket:    aws.String(s.bucket),
+		Key:       aws.String(key),
+		VersionId: aws.String(versionID),
+	}
+
+	return s.retry(context.Background(), func() error {
+		_, err := s.c.DeleteObjectWithContext(context.Background(), in)
+
+		return err
+	})
+}
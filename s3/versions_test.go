@@ -0,0 +1,128 @@
+package s3
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// versionPages answers ListObjectVersions with two pages of versions and
+// delete markers of backups/db.sql and backups/old.sql.
+func versionPages(w http.ResponseWriter, r *http.Request, op string) bool {
+	if op != "ListObjectVersions" {
+		return false
+	}
+
+	page := `<IsTruncated>true</IsTruncated><NextKeyMarker>backups/db.sql</NextKeyMarker>` +
+		`<NextVersionIdMarker>v2</NextVersionIdMarker>` +
+		`<Version><Key>backups/db.sql</Key><VersionId>v3</VersionId><IsLatest>true</IsLatest>` +
+		`<LastModified>2021-10-03T00:00:00.000Z</LastModified><Size>3</Size></Version>` +
+		`<Version><Key>backups/db.sql</Key><VersionId>v2</VersionId><IsLatest>false</IsLatest>` +
+		`<LastModified>2021-10-02T00:00:00.000Z</LastModified><Size>2</Size></Version>`
+	if r.URL.Query().Get("key-marker") != "" {
+		page = `<IsTruncated>false</IsTruncated>` +
+			`<Version><Key>backups/db.sql</Key><VersionId>v1</VersionId><IsLatest>false</IsLatest>` +
+			`<LastModified>2021-10-01T00:00:00.000Z</LastModified><Size>1</Size></Version>` +
+			`<Version><Key>backups/old%20dump.sql</Key><VersionId>o1</VersionId><IsLatest>false</IsLatest>` +
+			`<LastModified>2021-09-01T00:00:00.000Z</LastModified><Size>5</Size></Version>` +
+			`<DeleteMarker><Key>backups/old%20dump.sql</Key><VersionId>o2</VersionId><IsLatest>true</IsLatest>` +
+			`<LastModified>2021-09-02T00:00:00.000Z</LastModified></DeleteMarker>`
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>`+
+		`<ListVersionsResult><Name>%s</Name><EncodingType>url</EncodingType>%s</ListVersionsResult>`, testBucket, page)
+
+	return true
+}
+
+func TestListVersions(t *testing.T) {
+	s, f := newTestStorage(t)
+	f.setHook(versionPages)
+
+	ov, err := s.ListVersions("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		name, id     string
+		size         int64
+		latest, mark bool
+	}{
+		{"db.sql", "v3", 3, true, false},
+		{"db.sql", "v2", 2, false, false},
+		{"db.sql", "v1", 1, false, false},
+		{"old dump.sql", "o2", 0, true, true},
+		{"old dump.sql", "o1", 5, false, false},
+	}
+
+	if len(ov) != len(want) {
+		t.Fatalf("listed %d versions, want %d", len(ov), len(want))
+	}
+
+	for i, w := range want {
+		v := ov[i]
+		if v.Name != w.name || v.VersionID != w.id || v.Size != w.size || v.IsLatest != w.latest || v.IsDeleteMarker != w.mark {
+			t.Errorf("version %d: %+v, want %+v", i, v, w)
+		}
+	}
+
+	if n := len(f.calls("ListObjectVersions")); n != 2 {
+		t.Errorf("%d list requests, want 2", n)
+	}
+}
+
+func TestDownloadVersion(t *testing.T) {
+	s, f := newTestStorage(t)
+	f.put("backups/db.sql", []byte("data"))
+	// the fake keeps no versions, the old one is answered by hook
+	f.setHook(func(w http.ResponseWriter, r *http.Request, op string) bool {
+		if op != "GetObject" || r.URL.Query().Get("versionId") != "v2" {
+			return false
+		}
+
+		w.Header().Set("Content-Length", "3")
+		w.Header().Set("X-Amz-Version-Id", "v2")
+		w.Write([]byte("old"))
+
+		return true
+	})
+
+	var buf bytes.Buffer
+	if err := s.DownloadVersion("db.sql", "v2", &buf); err != nil || buf.String() != "old" {
+		t.Errorf("downloaded %q, %v, want old", buf.String(), err)
+	}
+
+	buf.Reset()
+	if err := s.Download("db.sql", &buf); err != nil || buf.String() != "data" {
+		t.Errorf("downloaded latest %q, %v, want data", buf.String(), err)
+	}
+
+	if err := s.DownloadVersion("../db.sql", "v2", &buf); err == nil {
+		t.Error("downloaded version of name outside the prefix")
+	}
+}
+
+func TestDeleteVersion(t *testing.T) {
+	s, f := newTestStorage(t)
+	f.put("backups/db.sql", []byte("data"))
+
+	if err := s.DeleteVersion("db.sql", "v2"); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := f.calls("DeleteObject")
+	if len(calls) != 1 {
+		t.Fatalf("%d delete requests, want 1", len(calls))
+	}
+
+	if c := calls[0]; c.key != "backups/db.sql" || c.query.Get("versionId") != "v2" {
+		t.Errorf("deleted %s version %q, want backups/db.sql version v2", c.key, c.query.Get("versionId"))
+	}
+
+	if err := s.DeleteVersion("../db.sql", "v2"); err == nil {
+		t.Error("deleted version of name outside the prefix")
+	}
+}
@@ -0,0 +1,52 @@
+package s3
+
+import (
+	"context"
+	"io"
+)
+
+// Writer uploads data written into it as object, see Create.
+type Writer struct {
+	pw   *io.PipeWriter
+	done chan error
+	err  error
+}
+
+// Create returns writer uploading data as name in background, upload is
+// finished by Close and aborted by CloseWithError.
+func (s *S3) Create(name string) (*Writer, error) {
+	pr, pw := io.Pipe()
+	w := &Writer{
+		pw:   pw,
+		done: make(chan error, 1),
+	}
+
+	go func() {
+		err := s.UploadContext(context.Background(), name, pr)
+		// unblock writes if upload stopped early
+		pr.CloseWithError(err)
+		w.done <- err
+	}()
+
+	return w, nil
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	return w.pw.Write(p)
+}
+
+// Close completes upload and returns its error.
+func (w *Writer) Close() error {
+	return w.CloseWithError(nil)
+}
+
+// CloseWithError aborts upload with err, nil err completes it like Close.
+func (w *Writer) CloseWithError(err error) error {
+	if w.done != nil {
+		w.pw.CloseWithError(err)
+		w.err = <-w.done
+		w.done = nil
+	}
+
+	return w.err
+}
@@ -0,0 +1,83 @@
+package s3
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestCreate(t *testing.T) {
+	s, f := newTestStorage(t, WithPartSize(minPartSize))
+
+	// short last part
+	data := randomBytes(t, 2*minPartSize+12345)
+
+	w, err := s.Create("db.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for p := data; len(p) > 0; {
+		n := min(len(p), 1000)
+		if _, err := w.Write(p[:n]); err != nil {
+			t.Fatal(err)
+		}
+		p = p[n:]
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(f.get("backups/db.sql"), data) {
+		t.Error("assembled object differs from written data")
+	}
+
+	parts := f.calls("UploadPart")
+	if len(parts) != 3 || parts[2].size != 12345 {
+		t.Errorf("uploaded %d parts", len(parts))
+	}
+
+	// repeated close returns the same result
+	if err := w.Close(); err != nil {
+		t.Errorf("second close: %v", err)
+	}
+}
+
+func TestCreateAbort(t *testing.T) {
+	s, f := newTestStorage(t, WithPartSize(minPartSize))
+
+	w, err := s.Create("db.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := w.Write(randomBytes(t, minPartSize+1)); err != nil {
+		t.Fatal(err)
+	}
+
+	abort := errors.New("dump failed")
+	if err := w.CloseWithError(abort); !errors.Is(err, abort) {
+		t.Errorf("got %v, want %v", err, abort)
+	}
+
+	if len(f.calls("AbortMultipartUpload")) != 1 || len(f.calls("CompleteMultipartUpload")) != 0 {
+		t.Error("aborted upload is not aborted")
+	}
+
+	if ok, err := s.Exists("db.sql"); err != nil || ok {
+		t.Errorf("aborted object exists %v, %v", ok, err)
+	}
+
+	// failed upload is reported by close
+	f.setHook(failOp("PutObject"))
+	w, err = s.Create("small.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.Write([]byte("data"))
+	if err := w.Close(); err == nil {
+		t.Error("failed upload closed without error")
+	}
+}
@@ -0,0 +1,338 @@
+package sftp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/sputnik-systems/backups-storage"
+)
+
+type SFTP struct {
+	c    *sftp.Client
+	root string
+}
+
+type FileInfo struct {
+	name  string
+	size  int64
+	mtime time.Time
+	isdir bool
+}
+
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// NewStorage returns storage in root directory of the server, connection
+// stays owned by caller.
+func NewStorage(conn *ssh.Client, root string) (*SFTP, error) {
+	c, err := sftp.NewClient(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SFTP{
+		c:    c,
+		root: path.Clean(root),
+	}, nil
+}
+
+// Close closes the sftp session.
+func (s *SFTP) Close() error {
+	return s.c.Close()
+}
+
+func (s *SFTP) List() ([]storage.FileInfo, error) {
+	return s.ListContext(context.Background())
+}
+
+func (s *SFTP) ListContext(ctx context.Context) ([]storage.FileInfo, error) {
+	fi := make([]storage.FileInfo, 0)
+	err := s.walk(ctx, "", func(name string, info os.FileInfo) error {
+		if info.IsDir() {
+			fi = append(fi, &FileInfo{name + "/", int64(0), info.ModTime(), true})
+		} else {
+			fi = append(fi, &FileInfo{name, info.Size(), info.ModTime(), false})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fi, err
+	}
+
+	sort.Slice(fi, func(i, j int) bool {
+		return fi[i].Name() > fi[j].Name()
+	})
+
+	return fi, nil
+}
+
+func (s *SFTP) ListFunc(prefix string, fn func(storage.FileInfo) error) error {
+	return s.walk(context.Background(), prefix, func(name string, info os.FileInfo) error {
+		if info.IsDir() || !strings.HasPrefix(name, prefix) {
+			return nil
+		}
+
+		return fn(&FileInfo{name, info.Size(), info.ModTime(), false})
+	})
+}
+
+// walk calls fn for files and directories of root which may contain names
+// with the prefix.
+func (s *SFTP) walk(ctx context.Context, prefix string, fn func(string, os.FileInfo) error) error {
+	w := s.c.Walk(s.root)
+	for w.Step() {
+		if err := w.Err(); err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if w.Path() == s.root {
+			continue
+		}
+
+		name := strings.TrimPrefix(strings.TrimPrefix(w.Path(), s.root), "/")
+		info := w.Stat()
+
+		// skip directories which can not contain matching files
+		if info.IsDir() && !strings.HasPrefix(name+"/", prefix) && !strings.HasPrefix(prefix, name+"/") {
+			w.SkipDir()
+			continue
+		}
+
+		// skip temporary files of uploads in progress
+		if strings.HasPrefix(path.Base(name), ".") && strings.Contains(path.Base(name), ".upload-") {
+			continue
+		}
+
+		if err := fn(name, info); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *SFTP) Delete(name string) error {
+	return s.DeleteContext(context.Background(), name)
+}
+
+func (s *SFTP) DeleteContext(ctx context.Context, name string) error {
+	p, err := s.path(name)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	err = s.c.RemoveAll(p)
+	if err != nil && os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+func (s *SFTP) DeleteBatch(names []string) error {
+	for _, name := range names {
+		p, err := s.path(name)
+		if err != nil {
+			return err
+		}
+
+		if err := s.c.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *SFTP) Upload(name string, buf io.Reader) error {
+	return s.UploadContext(context.Background(), name, buf)
+}
+
+func (s *SFTP) UploadContext(ctx context.Context, name string, buf io.Reader) (err error) {
+	p, err := s.path(name)
+	if err != nil {
+		return err
+	}
+
+	if err := s.c.MkdirAll(path.Dir(p)); err != nil {
+		return err
+	}
+
+	// write into temporary file, so partial uploads are never visible under name
+	tmp := path.Join(path.Dir(p), fmt.Sprintf(".%s.upload-%d", path.Base(p), time.Now().UnixNano()))
+	f, err := s.c.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			s.c.Remove(tmp)
+		}
+	}()
+
+	if _, err = io.Copy(f, &ctxReader{ctx, buf}); err != nil {
+		return err
+	}
+
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	return s.c.PosixRename(tmp, p)
+}
+
+func (s *SFTP) Download(name string, buf io.Writer) error {
+	return s.DownloadContext(context.Background(), name, buf)
+}
+
+func (s *SFTP) DownloadContext(ctx context.Context, name string, buf io.Writer) error {
+	p, err := s.path(name)
+	if err != nil {
+		return err
+	}
+
+	f, err := s.c.Open(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%s: %w", name, storage.ErrNotFound)
+		}
+
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(buf, &ctxReader{ctx, f})
+
+	return err
+}
+
+func (s *SFTP) Exists(name string) (bool, error) {
+	p, err := s.path(name)
+	if err != nil {
+		return false, err
+	}
+
+	info, err := s.c.Stat(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return !info.IsDir(), nil
+}
+
+func (s *SFTP) Stat(name string) (storage.FileInfo, error) {
+	p, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := s.c.Stat(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%s: %w", name, storage.ErrNotFound)
+		}
+
+		return nil, err
+	}
+
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s: %w", name, storage.ErrNotFound)
+	}
+
+	return &FileInfo{name, info.Size(), info.ModTime(), false}, nil
+}
+
+func (s *SFTP) Copy(src, dst string) error {
+	p, err := s.path(src)
+	if err != nil {
+		return err
+	}
+
+	f, err := s.c.Open(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%s: %w", src, storage.ErrNotFound)
+		}
+
+		return err
+	}
+	defer f.Close()
+
+	return s.Upload(dst, f)
+}
+
+func (s *SFTP) Move(src, dst string) error {
+	sp, err := s.path(src)
+	if err != nil {
+		return err
+	}
+
+	dp, err := s.path(dst)
+	if err != nil {
+		return err
+	}
+
+	if err := s.c.MkdirAll(path.Dir(dp)); err != nil {
+		return err
+	}
+
+	if err := s.c.PosixRename(sp, dp); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%s: %w", src, storage.ErrNotFound)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// path returns remote path of the name, names escaping root are rejected.
+func (s *SFTP) path(name string) (string, error) {
+	if err := storage.ValidateName(name); err != nil {
+		return "", err
+	}
+
+	return path.Join(s.root, name), nil
+}
+
+func (r *ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return r.r.Read(p)
+}
+
+func (f *FileInfo) Name() string { return f.name }
+
+func (f *FileInfo) Size() int64 { return f.size }
+
+func (f *FileInfo) ModTime() time.Time { return f.mtime }
+
+func (f *FileInfo) IsDir() bool { return f.isdir }
@@ -0,0 +1,230 @@
+package sftp
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/sputnik-systems/backups-storage"
+)
+
+// newTestStorage returns storage in a temporary directory served by an
+// in-process ssh server with sftp subsystem.
+func newTestStorage(t *testing.T) (*SFTP, string) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn, config)
+		}
+	}()
+
+	nc, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, chans, reqs, err := ssh.NewClientConn(nc, l.Addr().String(), &ssh.ClientConfig{
+		User:            "test",
+		HostKeyCallback: ssh.FixedHostKey(signer.PublicKey()),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := ssh.NewClient(conn, chans, reqs)
+	t.Cleanup(func() { c.Close() })
+
+	root := t.TempDir()
+	s, err := NewStorage(c, filepath.Join(root, "backups"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	return s, root
+}
+
+func serve(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for nc := range chans {
+		ch, reqs, err := nc.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			for req := range reqs {
+				// subsystem request payload is length prefixed name
+				ok := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+
+				if ok {
+					if srv, err := sftp.NewServer(ch); err == nil {
+						srv.Serve()
+						srv.Close()
+					}
+				}
+			}
+		}()
+	}
+}
+
+// names returns names of listed entries in order.
+func names(fi []storage.FileInfo) []string {
+	n := make([]string, 0, len(fi))
+	for _, f := range fi {
+		n = append(n, f.Name())
+	}
+
+	return n
+}
+
+func TestRoundTrip(t *testing.T) {
+	s, root := newTestStorage(t)
+
+	data := bytes.Repeat([]byte("0123456789"), 100000)
+	if err := s.Upload("db/dump.sql", bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := s.Download("db/dump.sql", &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("downloaded %d bytes, want %d", buf.Len(), len(data))
+	}
+
+	if got, err := os.ReadFile(filepath.Join(root, "backups", "db", "dump.sql")); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("stored %d bytes, %v", len(got), err)
+	}
+
+	fi, err := s.Stat("db/dump.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fi.Name() != "db/dump.sql" || fi.Size() != int64(len(data)) || fi.IsDir() {
+		t.Errorf("stat %s of %d bytes, directory %v", fi.Name(), fi.Size(), fi.IsDir())
+	}
+
+	if err := s.Download("missing", &buf); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("download of missing object: %v", err)
+	}
+
+	if ok, err := s.Exists("missing"); err != nil || ok {
+		t.Errorf("missing object exists %v, %v", ok, err)
+	}
+}
+
+func TestListAndDelete(t *testing.T) {
+	s, _ := newTestStorage(t)
+
+	for _, name := range []string{"a/b/c.sql", "a/d.sql", "e.sql"} {
+		if err := s.Upload(name, strings.NewReader(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fi, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := strings.Join(names(fi), " "), "e.sql a/d.sql a/b/c.sql a/b/ a/"; got != want {
+		t.Errorf("listed %s, want %s", got, want)
+	}
+
+	for _, f := range fi {
+		if f.IsDir() != strings.HasSuffix(f.Name(), "/") {
+			t.Errorf("%s: directory %v", f.Name(), f.IsDir())
+		}
+	}
+
+	// whole subtree
+	if err := s.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if fi, err = s.List(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(names(fi), " "); got != "e.sql" {
+		t.Errorf("left %s, want e.sql", got)
+	}
+}
+
+func TestCopyAndMove(t *testing.T) {
+	s, _ := newTestStorage(t)
+
+	if err := s.Upload("a.sql", strings.NewReader("data")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Copy("a.sql", "b/c.sql"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Move("b/c.sql", "d.sql"); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := s.Download("d.sql", &buf); err != nil || buf.String() != "data" {
+		t.Errorf("downloaded %q, %v", buf.String(), err)
+	}
+
+	if ok, err := s.Exists("b/c.sql"); err != nil || ok {
+		t.Errorf("moved object exists %v, %v", ok, err)
+	}
+}
+
+func TestNameOutsideRoot(t *testing.T) {
+	s, root := newTestStorage(t)
+
+	if err := s.Upload("../escaped", strings.NewReader("x")); err == nil {
+		t.Error("upload outside root succeeded")
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "escaped")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("stored outside root: %v", err)
+	}
+}
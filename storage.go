@@ -1,15 +1,40 @@
 package storage
 
 import (
+	"context"
+	"errors"
 	"io"
 	"time"
 )
 
+var (
+	ErrNotFound         = errors.New("object not found")
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	ErrAlreadyExists    = errors.New("object already exists")
+	ErrObjectArchived   = errors.New("object is archived")
+	ErrInvalidName      = errors.New("invalid object name")
+)
+
 type Storage interface {
+	// List returns objects and synthesized directories, names are relative
+	// to the storage prefix so they can be passed to other methods.
 	List() ([]FileInfo, error)
 	Delete(string) error
 	Upload(string, io.Reader) error
 	Download(string, io.Writer) error
+	Exists(string) (bool, error)
+	Stat(string) (FileInfo, error)
+	Copy(string, string) error
+	Move(string, string) error
+	ListFunc(string, func(FileInfo) error) error
+	// DeleteBatch deletes exactly the named objects, unlike Delete it does
+	// not treat names as prefixes.
+	DeleteBatch([]string) error
+
+	ListContext(context.Context) ([]FileInfo, error)
+	DeleteContext(context.Context, string) error
+	UploadContext(context.Context, string, io.Reader) error
+	DownloadContext(context.Context, string, io.Writer) error
 }
 
 type FileInfo interface {
@@ -18,3 +43,9 @@ type FileInfo interface {
 	ModTime() time.Time
 	IsDir() bool
 }
+
+// Checksummer is implemented by FileInfo of storages which keep hex SHA-256
+// of objects, empty checksum is unknown.
+type Checksummer interface {
+	Checksum() string
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// SummaryInfo describes objects with a name prefix, times are zero when
+// there are no objects.
+type SummaryInfo struct {
+	Count  int
+	Size   int64
+	Newest time.Time
+	Oldest time.Time
+}
+
+// TotalSize returns total size of objects with the name prefix.
+func TotalSize(ctx context.Context, s Storage, prefix string) (int64, error) {
+	sum, err := Summary(ctx, s, prefix)
+
+	return sum.Size, err
+}
+
+// Summary returns number, total size and modification time range of
+// objects with the name prefix, e.g. to alert on stale backups.
+func Summary(ctx context.Context, s Storage, prefix string) (SummaryInfo, error) {
+	var sum SummaryInfo
+	err := s.ListFunc(prefix, func(f FileInfo) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// directories of backends listing them are not objects
+		if f.IsDir() {
+			return nil
+		}
+
+		sum.Count++
+		sum.Size += f.Size()
+
+		if sum.Newest.IsZero() || f.ModTime().After(sum.Newest) {
+			sum.Newest = f.ModTime()
+		}
+
+		if sum.Oldest.IsZero() || f.ModTime().Before(sum.Oldest) {
+			sum.Oldest = f.ModTime()
+		}
+
+		return nil
+	})
+
+	return sum, err
+}
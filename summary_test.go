@@ -0,0 +1,84 @@
+package storage_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sputnik-systems/backups-storage"
+	"github.com/sputnik-systems/backups-storage/memory"
+)
+
+// listingDirs lists synthesized directories along with objects.
+type listingDirs struct {
+	*memory.Memory
+}
+
+func (s listingDirs) ListFunc(prefix string, fn func(storage.FileInfo) error) error {
+	fi, err := s.List()
+	if err != nil {
+		return err
+	}
+
+	for _, f := range fi {
+		if strings.HasPrefix(f.Name(), prefix) {
+			if err := fn(f); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func TestTotalSize(t *testing.T) {
+	m := memory.NewStorage()
+	m.Seed("db/a.sql", make([]byte, 100), time.Now())
+	m.Seed("db/nested/b.sql", make([]byte, 20), time.Now())
+	m.Seed("db/c.sql", nil, time.Now())
+	m.Seed("other.sql", make([]byte, 1000), time.Now())
+
+	for _, s := range []storage.Storage{m, listingDirs{m}} {
+		size, err := storage.TotalSize(context.Background(), s, "db/")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if size != 120 {
+			t.Errorf("%T: total size %d, want 120", s, size)
+		}
+	}
+}
+
+func TestSummary(t *testing.T) {
+	oldest := time.Date(2021, 9, 1, 3, 0, 0, 0, time.UTC)
+	newest := time.Date(2021, 10, 10, 3, 0, 0, 0, time.UTC)
+
+	m := memory.NewStorage()
+	m.Seed("db/b.sql", make([]byte, 10), newest)
+	m.Seed("db/a.sql", make([]byte, 20), oldest)
+	m.Seed("db/nested/c.sql", make([]byte, 30), oldest.Add(24*time.Hour))
+	m.Seed("other.sql", nil, newest.Add(time.Hour))
+
+	for _, s := range []storage.Storage{m, listingDirs{m}} {
+		sum, err := storage.Summary(context.Background(), s, "db/")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := storage.SummaryInfo{Count: 3, Size: 60, Newest: newest, Oldest: oldest}
+		if sum != want {
+			t.Errorf("%T: summary %+v, want %+v", s, sum, want)
+		}
+	}
+
+	sum, err := storage.Summary(context.Background(), m, "missing/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sum != (storage.SummaryInfo{}) {
+		t.Errorf("summary of empty prefix %+v", sum)
+	}
+}
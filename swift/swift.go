@@ -0,0 +1,459 @@
+package swift
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ncw/swift"
+
+	"github.com/sputnik-systems/backups-storage"
+)
+
+// Client does not support contexts, so they are checked only between
+// requests.
+
+type Swift struct {
+	c                 *swift.Connection
+	container, prefix string
+	partSize          int64
+}
+
+type FileInfo struct {
+	name     string
+	size     int64
+	mtime    time.Time
+	isdir    bool
+	metadata map[string]string
+}
+
+// NewStorage returns storage in container of conn, which is authenticated
+// unless it already is, so bad credentials fail early.
+func NewStorage(conn *swift.Connection, container, prefix string) (storage.Storage, error) {
+	partSize := int64(100 * 1024 * 1024)
+
+	if !conn.Authenticated() {
+		if err := conn.Authenticate(); err != nil {
+			return nil, fmt.Errorf("swift auth: %w", err)
+		}
+	}
+
+	return &Swift{
+		c:         conn,
+		container: container,
+		prefix:    prefix,
+		partSize:  partSize,
+	}, nil
+}
+
+func (s *Swift) List() ([]storage.FileInfo, error) {
+	return s.ListContext(context.Background())
+}
+
+func (s *Swift) ListContext(ctx context.Context) ([]storage.FileInfo, error) {
+	return s.list(ctx, s.key(""))
+}
+
+func (s *Swift) ListFunc(prefix string, fn func(storage.FileInfo) error) error {
+	return s.listFunc(context.Background(), s.key(prefix), fn)
+}
+
+func (s *Swift) listFunc(ctx context.Context, prefix string, fn func(storage.FileInfo) error) error {
+	return s.c.ObjectsWalk(s.container, &swift.ObjectsOpts{Prefix: prefix}, func(opts *swift.ObjectsOpts) (interface{}, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		objects, err := s.c.Objects(s.container, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, o := range objects {
+			if err := fn(&FileInfo{s.relName(o.Name), o.Bytes, o.LastModified, false, nil}); err != nil {
+				return nil, err
+			}
+		}
+
+		return objects, nil
+	})
+}
+
+func (s *Swift) list(ctx context.Context, prefix string) ([]storage.FileInfo, error) {
+	fi := make([]storage.FileInfo, 0)
+	di := make(map[string]*FileInfo)
+	err := s.listFunc(ctx, prefix, func(f storage.FileInfo) error {
+		fi = append(fi, f)
+
+		// calc pseudo directories
+		dir := path.Dir(f.Name()) + "/"
+		if dir == "./" {
+			return nil
+		}
+
+		if d, ok := di[dir]; !ok || d.mtime.Before(f.ModTime()) {
+			di[dir] = &FileInfo{dir, int64(0), f.ModTime(), true, nil}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fi, err
+	}
+
+	for _, d := range di {
+		fi = append(fi, d)
+	}
+
+	sort.Slice(fi, func(i, j int) bool {
+		return fi[i].Name() > fi[j].Name()
+	})
+
+	return fi, nil
+}
+
+func (s *Swift) Delete(name string) error {
+	return s.DeleteContext(context.Background(), name)
+}
+
+func (s *Swift) DeleteContext(ctx context.Context, name string) error {
+	if err := storage.ValidateName(name); err != nil {
+		return err
+	}
+
+	prefix := s.key(strings.TrimSuffix(name, "/"))
+	fi, err := s.list(ctx, prefix)
+	if err != nil {
+		return err
+	}
+
+	for _, o := range fi {
+		if o.IsDir() {
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := s.deleteObject(s.key(o.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Swift) DeleteBatch(names []string) error {
+	for _, name := range names {
+		key, err := storage.ObjectKey(s.prefix, name)
+		if err != nil {
+			return err
+		}
+
+		if err := s.deleteObject(key); err != nil {
+			return fmt.Errorf("delete %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// deleteObject deletes object along with segments of large objects.
+func (s *Swift) deleteObject(key string) error {
+	err := s.c.LargeObjectDelete(s.container, key)
+	if err != nil && !errors.Is(err, swift.ObjectNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Swift) Upload(name string, buf io.Reader) error {
+	return s.UploadContext(context.Background(), name, buf)
+}
+
+func (s *Swift) UploadContext(ctx context.Context, name string, buf io.Reader) (err error) {
+	key, err := storage.ObjectKey(s.prefix, name)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// objects up to part size are put at once
+	segments := s.container + "_segments"
+	r := &ctxReader{ctx, buf}
+	b, rerr := s.readPart(r, nil)
+	if rerr != nil && rerr != io.EOF {
+		return rerr
+	}
+
+	if rerr != nil {
+		if _, err := s.c.ObjectPut(s.container, key, bytes.NewReader(b), false, "", "", nil); err != nil {
+			return err
+		}
+
+		// the object may replace a large one
+		return s.deleteStaleSegments(segments, key, "")
+	}
+
+	// larger ones are uploaded as static large object of part size
+	// segments, which are kept in container of the conventional name
+	if err := s.c.ContainerCreate(segments, nil); err != nil {
+		return fmt.Errorf("create segments container %s: %w", segments, err)
+	}
+
+	// segments of each upload get own prefix and the manifest is put only
+	// once all of them are uploaded, so existing object stays intact until
+	// the new one replaces it
+	prefix := fmt.Sprintf("%s/%d/", key, time.Now().UnixNano())
+	manifest := make([]sloSegment, 0)
+	defer func() {
+		// do not leave segments of a failed upload behind
+		if err != nil {
+			for _, m := range manifest {
+				s.c.ObjectDelete(segments, strings.TrimPrefix(m.Path, segments+"/"))
+			}
+		}
+	}()
+
+	for i := 1; len(b) > 0; i++ {
+		name := fmt.Sprintf("%s%016d", prefix, i)
+		sum := md5.Sum(b)
+		if _, err := s.c.ObjectPut(segments, name, bytes.NewReader(b), true, hex.EncodeToString(sum[:]), "", nil); err != nil {
+			return fmt.Errorf("upload segment %d: %w", i, err)
+		}
+		manifest = append(manifest, sloSegment{segments + "/" + name, hex.EncodeToString(sum[:]), int64(len(b))})
+
+		b, err = s.readPart(r, b)
+		if err != nil && err != io.EOF {
+			return err
+		}
+	}
+
+	body, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = s.c.Call(s.c.StorageUrl, swift.RequestOpts{
+		Container:  s.container,
+		ObjectName: key,
+		Operation:  "PUT",
+		Parameters: url.Values{"multipart-manifest": {"put"}},
+		Body:       bytes.NewReader(body),
+		NoResponse: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.deleteStaleSegments(segments, key, prefix)
+}
+
+// readPart reads up to part size bytes of r into b, which grows only as
+// data comes, so small objects do not take part size of memory. Parts
+// shorter than part size are returned with io.EOF.
+func (s *Swift) readPart(r io.Reader, b []byte) ([]byte, error) {
+	b = b[:0]
+	for int64(len(b)) < s.partSize {
+		if len(b) == cap(b) {
+			size := min(max(2*int64(cap(b)), 64<<10), s.partSize)
+			b = append(make([]byte, 0, size), b...)
+		}
+
+		n, err := r.Read(b[len(b):cap(b)])
+		b = b[:len(b)+n]
+		if err != nil {
+			return b, err
+		}
+	}
+
+	return b, nil
+}
+
+// sloSegment is entry of static large object manifest.
+type sloSegment struct {
+	Path string `json:"path"`
+	Etag string `json:"etag"`
+	Size int64  `json:"size_bytes"`
+}
+
+// deleteStaleSegments deletes segments of earlier uploads of key, which
+// are no longer referenced once manifest of upload with prefix is put, or
+// the object is put at once with empty prefix.
+func (s *Swift) deleteStaleSegments(segments, key, prefix string) error {
+	names, err := s.c.ObjectNamesAll(segments, &swift.ObjectsOpts{Prefix: key + "/"})
+	if err != nil {
+		// no large object was ever uploaded
+		if errors.Is(err, swift.ContainerNotFound) {
+			return nil
+		}
+
+		return err
+	}
+
+	for _, name := range names {
+		// segments of nested objects share the prefix
+		rest := strings.Split(strings.TrimPrefix(name, key+"/"), "/")
+		if len(rest) != 2 || (prefix != "" && strings.HasPrefix(name, prefix)) {
+			continue
+		}
+
+		if err := s.c.ObjectDelete(segments, name); err != nil && !errors.Is(err, swift.ObjectNotFound) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Swift) Download(name string, buf io.Writer) error {
+	return s.DownloadContext(context.Background(), name, buf)
+}
+
+func (s *Swift) DownloadContext(ctx context.Context, name string, buf io.Writer) error {
+	key, err := storage.ObjectKey(s.prefix, name)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// etag of large objects is not md5 of content, so it is not checked
+	f, _, err := s.c.ObjectOpen(s.container, key, false, nil)
+	if err != nil {
+		if errors.Is(err, swift.ObjectNotFound) {
+			return fmt.Errorf("%s: %w", key, storage.ErrNotFound)
+		}
+
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(buf, &ctxReader{ctx, f})
+
+	return err
+}
+
+func (s *Swift) Exists(name string) (bool, error) {
+	if _, err := s.Stat(name); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *Swift) Stat(name string) (storage.FileInfo, error) {
+	key, err := storage.ObjectKey(s.prefix, name)
+	if err != nil {
+		return nil, err
+	}
+
+	o, h, err := s.c.Object(s.container, key)
+	if err != nil {
+		if errors.Is(err, swift.ObjectNotFound) {
+			return nil, fmt.Errorf("%s: %w", key, storage.ErrNotFound)
+		}
+
+		return nil, err
+	}
+
+	return &FileInfo{s.relName(key), o.Bytes, o.LastModified, false, h.ObjectMetadata()}, nil
+}
+
+// Copy copies object server side, large objects are copied as a single
+// object, which swift limits to 5 GiB.
+func (s *Swift) Copy(src, dst string) error {
+	srcKey, err := storage.ObjectKey(s.prefix, src)
+	if err != nil {
+		return err
+	}
+
+	dstKey, err := storage.ObjectKey(s.prefix, dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.c.ObjectCopy(s.container, srcKey, s.container, dstKey, nil); err != nil {
+		if errors.Is(err, swift.ObjectNotFound) {
+			return fmt.Errorf("%s: %w", srcKey, storage.ErrNotFound)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (s *Swift) Move(src, dst string) error {
+	if err := s.Copy(src, dst); err != nil {
+		return fmt.Errorf("move %s: copy: %w", src, err)
+	}
+
+	if err := s.deleteObject(path.Join(s.prefix, src)); err != nil {
+		return fmt.Errorf("move %s: delete source: %w", src, err)
+	}
+
+	return nil
+}
+
+// key joins name with the prefix keeping trailing slash, so it can be used
+// as list prefix.
+func (s *Swift) key(name string) string {
+	if s.prefix == "" {
+		return name
+	}
+
+	return strings.TrimSuffix(s.prefix, "/") + "/" + name
+}
+
+// relName strips the prefix from object key.
+func (s *Swift) relName(key string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(key, strings.TrimSuffix(s.prefix, "/")), "/")
+}
+
+// ctxReader stops reading once context is done.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return r.r.Read(p)
+}
+
+func (f *FileInfo) Name() string { return f.name }
+
+func (f *FileInfo) Size() int64 { return f.size }
+
+func (f *FileInfo) ModTime() time.Time { return f.mtime }
+
+func (f *FileInfo) IsDir() bool { return f.isdir }
+
+// Metadata returns object metadata (X-Object-Meta-*) with lower cased keys,
+// it is available only for objects returned by Stat.
+func (f *FileInfo) Metadata() map[string]string { return f.metadata }
@@ -0,0 +1,111 @@
+//go:build integration
+
+package swift
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ncw/swift"
+)
+
+// Run against a Swift cluster, e.g. the all in one docker image
+// docker run -p 8080:8080 openstackswift/saio and
+// SWIFT_AUTH_URL=http://127.0.0.1:8080/auth/v1.0 SWIFT_USER=test:tester
+// SWIFT_KEY=testing go test -tags integration ./swift.
+
+func newSwiftStorage(t *testing.T) (*Swift, *swift.Connection) {
+	authURL := os.Getenv("SWIFT_AUTH_URL")
+	if authURL == "" {
+		t.Skip("SWIFT_AUTH_URL is not set")
+	}
+
+	conn := &swift.Connection{
+		UserName: os.Getenv("SWIFT_USER"),
+		ApiKey:   os.Getenv("SWIFT_KEY"),
+		AuthUrl:  authURL,
+	}
+
+	container := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	s, err := NewStorage(conn, container, "backups")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := conn.ContainerCreate(container, nil); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		for _, ct := range []string{container, container + "_segments"} {
+			names, _ := conn.ObjectNamesAll(ct, nil)
+			for _, name := range names {
+				conn.ObjectDelete(ct, name)
+			}
+			conn.ContainerDelete(ct)
+		}
+	})
+
+	return s.(*Swift), conn
+}
+
+func TestSwiftRoundTrip(t *testing.T) {
+	s, _ := newSwiftStorage(t)
+	// slo segments may be as small as 1 MiB
+	s.partSize = 1 << 20
+
+	data := bytes.Repeat([]byte("0123456789"), 300000)
+	if err := s.Upload("db/dump.sql", bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := s.Download("db/dump.sql", &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Fatalf("downloaded %d bytes, want %d", buf.Len(), len(data))
+	}
+
+	fi, err := s.Stat("db/dump.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fi.Size() != int64(len(data)) {
+		t.Errorf("stat size %d, want %d", fi.Size(), len(data))
+	}
+
+	list, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := names(list); got != "db/dump.sql db/" {
+		t.Errorf("listed %s, want db/dump.sql db/", got)
+	}
+}
+
+func TestSwiftDeleteLargeObject(t *testing.T) {
+	s, conn := newSwiftStorage(t)
+	s.partSize = 1 << 20
+
+	if err := s.Upload("db/dump.sql", bytes.NewReader(make([]byte, 3<<20))); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Delete("db/"); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := s.Exists("db/dump.sql"); err != nil || ok {
+		t.Errorf("deleted object exists %v, %v", ok, err)
+	}
+
+	if segments, err := conn.ObjectNamesAll(s.container+"_segments", nil); err != nil || len(segments) != 0 {
+		t.Errorf("left segments %v, %v", segments, err)
+	}
+}
@@ -0,0 +1,318 @@
+package swift
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ncw/swift"
+	"github.com/ncw/swift/swifttest"
+
+	"github.com/sputnik-systems/backups-storage"
+)
+
+func newTestStorage(t *testing.T, prefix string) (*Swift, *swift.Connection) {
+	srv, err := swifttest.NewSwiftServer("localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+
+	conn := &swift.Connection{
+		UserName: swifttest.TEST_ACCOUNT,
+		ApiKey:   swifttest.TEST_ACCOUNT,
+		AuthUrl:  srv.AuthURL,
+	}
+
+	s, err := NewStorage(conn, "ct", prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := conn.ContainerCreate("ct", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	return s.(*Swift), conn
+}
+
+// names returns names of listed entries in order.
+func names(fi []storage.FileInfo) string {
+	n := make([]string, 0, len(fi))
+	for _, f := range fi {
+		n = append(n, f.Name())
+	}
+
+	return strings.Join(n, " ")
+}
+
+func TestKey(t *testing.T) {
+	for _, tc := range []struct {
+		prefix, name, key string
+	}{
+		{"", "db.sql", "db.sql"},
+		{"", "", ""},
+		{"backups", "db.sql", "backups/db.sql"},
+		{"backups/", "db.sql", "backups/db.sql"},
+		{"backups", "", "backups/"},
+		{"backups", "db/", "backups/db/"},
+		{"a/b", "c/d.sql", "a/b/c/d.sql"},
+	} {
+		s := &Swift{prefix: tc.prefix}
+		if got := s.key(tc.name); got != tc.key {
+			t.Errorf("prefix %q: key of %q is %q, want %q", tc.prefix, tc.name, got, tc.key)
+		}
+
+		if got := s.relName(tc.key); got != tc.name {
+			t.Errorf("prefix %q: name of %q is %q, want %q", tc.prefix, tc.key, got, tc.name)
+		}
+	}
+}
+
+func TestBadCredentials(t *testing.T) {
+	srv, err := swifttest.NewSwiftServer("localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	conn := &swift.Connection{UserName: swifttest.TEST_ACCOUNT, ApiKey: "wrong", AuthUrl: srv.AuthURL}
+	if _, err := NewStorage(conn, "ct", ""); err == nil {
+		t.Error("storage with bad credentials")
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	s, conn := newTestStorage(t, "backups")
+	// small segments make large objects of several of them
+	s.partSize = 5
+
+	for name, data := range map[string]string{"small.sql": "data", "db/large.sql": "0123456789"} {
+		if err := s.Upload(name, strings.NewReader(data)); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		var buf bytes.Buffer
+		if err := s.Download(name, &buf); err != nil || buf.String() != data {
+			t.Errorf("%s: downloaded %q, %v, want %q", name, buf.String(), err, data)
+		}
+
+		fi, err := s.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if fi.Name() != name || fi.Size() != int64(len(data)) {
+			t.Errorf("%s: stat %s of %d bytes", name, fi.Name(), fi.Size())
+		}
+	}
+
+	segments, err := conn.ObjectNamesAll("ct_segments", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(segments) != 2 {
+		t.Errorf("segments %v, want 2", segments)
+	}
+
+	// replaced large object leaves no segments of the previous upload
+	if err := s.Upload("db/large.sql", strings.NewReader("abcdefghi")); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := s.Download("db/large.sql", &buf); err != nil || buf.String() != "abcdefghi" {
+		t.Errorf("downloaded replaced %q, %v", buf.String(), err)
+	}
+
+	segments, err = conn.ObjectNamesAll("ct_segments", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(segments) != 2 {
+		t.Errorf("segments %v after replace, want 2", segments)
+	}
+
+	if err := s.Download("missing", &buf); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("download of missing object: %v", err)
+	}
+
+	if _, err := s.Stat("missing"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("stat of missing object: %v", err)
+	}
+}
+
+func TestReplaceLargeWithSmall(t *testing.T) {
+	s, conn := newTestStorage(t, "backups")
+	s.partSize = 5
+
+	// segments of nested large object share prefix of the replaced one
+	for _, name := range []string{"db/large.sql", "db/large.sql/nested.sql"} {
+		if err := s.Upload(name, strings.NewReader("0123456789")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.Upload("db/large.sql", strings.NewReader("abc")); err != nil {
+		t.Fatal(err)
+	}
+
+	// swifttest keeps large object flag of the replaced object, which
+	// swift does not, so its data is read as is
+	resp, _, err := conn.Call(conn.StorageUrl, swift.RequestOpts{
+		Container:  "ct",
+		ObjectName: "backups/db/large.sql",
+		Operation:  "GET",
+		Parameters: url.Values{"multipart-manifest": {"get"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if data, err := io.ReadAll(resp.Body); err != nil || string(data) != "abc" {
+		t.Errorf("replaced with %q, %v", data, err)
+	}
+
+	segments, err := conn.ObjectNamesAll("ct_segments", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(segments) != 2 || !strings.HasPrefix(segments[0], "backups/db/large.sql/nested.sql/") {
+		t.Errorf("segments %v, want 2 of nested object", segments)
+	}
+}
+
+func TestReadPart(t *testing.T) {
+	s, _ := newTestStorage(t, "")
+
+	// small object does not take part size of memory
+	b, err := s.readPart(strings.NewReader("data"), nil)
+	if err != io.EOF || string(b) != "data" {
+		t.Fatalf("read %q, %v", b, err)
+	}
+
+	if int64(cap(b)) >= s.partSize {
+		t.Errorf("buffer of %d bytes for small object", cap(b))
+	}
+
+	s.partSize = 100 << 10
+	data := bytes.Repeat([]byte("x"), int(s.partSize)+10)
+	r := bytes.NewReader(data)
+
+	b, err = s.readPart(r, nil)
+	if err != nil || int64(len(b)) != s.partSize || int64(cap(b)) != s.partSize {
+		t.Fatalf("read %d bytes of %d byte buffer, %v", len(b), cap(b), err)
+	}
+
+	// buffer is reused for the following parts
+	next, err := s.readPart(r, b)
+	if err != io.EOF || len(next) != 10 || &next[0] != &b[0] {
+		t.Errorf("read %d bytes, %v, reused %v", len(next), err, &next[0] == &b[0])
+	}
+}
+
+func TestListAndDelete(t *testing.T) {
+	s, conn := newTestStorage(t, "backups")
+	// a/b/c.sql and large/f.sql are large objects
+	s.partSize = 8
+
+	for _, name := range []string{"a/b/c.sql", "a/d.sql", "e.sql", "large/f.sql"} {
+		if err := s.Upload(name, strings.NewReader(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// objects outside the prefix are not listed
+	if _, err := conn.ObjectPut("ct", "other.sql", strings.NewReader("x"), false, "", "", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := names(fi); got != "large/f.sql large/ e.sql a/d.sql a/b/c.sql a/b/ a/" {
+		t.Errorf("listed %s", got)
+	}
+
+	var listed []string
+	err = s.ListFunc("a/", func(f storage.FileInfo) error {
+		listed = append(listed, f.Name())
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(listed, " "); got != "a/b/c.sql a/d.sql" {
+		t.Errorf("listed %s under a/", got)
+	}
+
+	if err := s.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	// large object is deleted with its segments
+	if err := s.Delete("large/f.sql"); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err = s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := names(fi); got != "e.sql" {
+		t.Errorf("left %s, want e.sql", got)
+	}
+
+	if segments, err := conn.ObjectNamesAll("ct_segments", nil); err != nil || len(segments) != 0 {
+		t.Errorf("left segments %v, %v", segments, err)
+	}
+
+	if err := s.DeleteBatch([]string{"e.sql", "missing"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := s.Exists("e.sql"); err != nil || ok {
+		t.Errorf("deleted object exists %v, %v", ok, err)
+	}
+}
+
+func TestCopyAndMove(t *testing.T) {
+	s, _ := newTestStorage(t, "")
+
+	if err := s.Upload("a", strings.NewReader("data")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Copy("a", "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Move("b", "c"); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := s.Exists("b"); err != nil || ok {
+		t.Errorf("moved source exists %v, %v", ok, err)
+	}
+
+	var buf bytes.Buffer
+	if err := s.Download("c", &buf); err != nil || buf.String() != "data" {
+		t.Errorf("downloaded copy %q, %v", buf.String(), err)
+	}
+
+	if err := s.Copy("missing", "d"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("copy of missing object: %v", err)
+	}
+}
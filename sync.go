@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+type syncOptions struct {
+	delete   bool
+	modTime  bool
+	checksum bool
+}
+
+type SyncOption func(*syncOptions)
+
+// WithDelete removes destination objects which are not present in source.
+func WithDelete() SyncOption {
+	return func(o *syncOptions) {
+		o.delete = true
+	}
+}
+
+// WithModTime also uploads objects which are newer in source than in
+// destination, by default only name and size are compared.
+func WithModTime() SyncOption {
+	return func(o *syncOptions) {
+		o.modTime = true
+	}
+}
+
+// WithChecksum also uploads objects of the same size whose stored checksums
+// differ, see Checksummer. Objects without checksums are compared by
+// modification time like with WithModTime.
+func WithChecksum() SyncOption {
+	return func(o *syncOptions) {
+		o.checksum = true
+	}
+}
+
+// Sync mirrors objects of src into dst, uploading missing and changed ones.
+func Sync(ctx context.Context, src, dst Storage, opts ...SyncOption) error {
+	var o syncOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	sfi, err := listObjects(ctx, src)
+	if err != nil {
+		return fmt.Errorf("list source: %w", err)
+	}
+
+	dfi, err := listObjects(ctx, dst)
+	if err != nil {
+		return fmt.Errorf("list destination: %w", err)
+	}
+
+	if o.delete {
+		// exact names only, prefix based Delete would also remove
+		// objects sharing the name as prefix
+		extra := make([]string, 0)
+		for name := range dfi {
+			if _, ok := sfi[name]; !ok {
+				extra = append(extra, name)
+			}
+		}
+
+		if len(extra) > 0 {
+			sort.Strings(extra)
+			if err := dst.DeleteBatch(extra); err != nil {
+				return fmt.Errorf("delete: %w", err)
+			}
+		}
+	}
+
+	for name, s := range sfi {
+		if d, ok := dfi[name]; ok && d.Size() == s.Size() {
+			changed, err := o.changed(src, dst, name, s, d)
+			if err != nil {
+				return fmt.Errorf("sync %s: %w", name, err)
+			}
+
+			if !changed {
+				continue
+			}
+		}
+
+		if err := CopyObject(ctx, src, name, dst, name); err != nil {
+			return fmt.Errorf("sync %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// changed reports whether object of the same size in src and dst differs.
+func (o *syncOptions) changed(src, dst Storage, name string, s, d FileInfo) (bool, error) {
+	newer := d.ModTime().Before(s.ModTime())
+
+	if !o.checksum {
+		return o.modTime && newer, nil
+	}
+
+	// listed objects may lack checksums, which are returned by Stat
+	ssum, err := checksum(src, name)
+	if err != nil {
+		return false, err
+	}
+
+	dsum, err := checksum(dst, name)
+	if err != nil {
+		return false, err
+	}
+
+	if ssum == "" || dsum == "" {
+		return newer, nil
+	}
+
+	return ssum != dsum, nil
+}
+
+func checksum(s Storage, name string) (string, error) {
+	f, err := s.Stat(name)
+	if err != nil {
+		return "", err
+	}
+
+	if c, ok := f.(Checksummer); ok {
+		return c.Checksum(), nil
+	}
+
+	return "", nil
+}
+
+// listObjects returns objects of s by name, without directories.
+func listObjects(ctx context.Context, s Storage) (map[string]FileInfo, error) {
+	fi, err := s.ListContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make(map[string]FileInfo, len(fi))
+	for _, f := range fi {
+		if !f.IsDir() {
+			objects[f.Name()] = f
+		}
+	}
+
+	return objects, nil
+}
@@ -0,0 +1,171 @@
+package storage_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sputnik-systems/backups-storage"
+	"github.com/sputnik-systems/backups-storage/memory"
+)
+
+// contents returns objects of s by name.
+func contents(t *testing.T, s *memory.Memory) map[string]string {
+	t.Helper()
+
+	fi, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := make(map[string]string)
+	for _, f := range fi {
+		if !f.IsDir() {
+			data, _ := s.Bytes(f.Name())
+			c[f.Name()] = string(data)
+		}
+	}
+
+	return c
+}
+
+func TestSync(t *testing.T) {
+	old := time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+	now := old.Add(time.Hour)
+
+	for _, tc := range []struct {
+		name string
+		opts []storage.SyncOption
+		want map[string]string
+	}{
+		{"default", nil, map[string]string{
+			"added.sql": "new", "resized.sql": "longer", "touched.sql": "old", "same.sql": "same", "extra.sql": "extra",
+		}},
+		{"mtime", []storage.SyncOption{storage.WithModTime()}, map[string]string{
+			"added.sql": "new", "resized.sql": "longer", "touched.sql": "new", "same.sql": "same", "extra.sql": "extra",
+		}},
+		{"delete", []storage.SyncOption{storage.WithDelete()}, map[string]string{
+			"added.sql": "new", "resized.sql": "longer", "touched.sql": "old", "same.sql": "same",
+		}},
+	} {
+		src, dst := memory.NewStorage(), memory.NewStorage()
+		src.Seed("added.sql", []byte("new"), now)
+		src.Seed("resized.sql", []byte("longer"), now)
+		src.Seed("touched.sql", []byte("new"), now)
+		src.Seed("same.sql", []byte("same"), old)
+
+		dst.Seed("resized.sql", []byte("short"), old)
+		dst.Seed("touched.sql", []byte("old"), old)
+		dst.Seed("same.sql", []byte("same"), old)
+		dst.Seed("extra.sql", []byte("extra"), old)
+
+		if err := storage.Sync(context.Background(), src, dst, tc.opts...); err != nil {
+			t.Fatal(err)
+		}
+
+		got := contents(t, dst)
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: synced %v, want %v", tc.name, got, tc.want)
+		}
+
+		for name, data := range tc.want {
+			if got[name] != data {
+				t.Errorf("%s: %s is %q, want %q", tc.name, name, got[name], data)
+			}
+		}
+	}
+}
+
+func TestSyncNested(t *testing.T) {
+	src, dst := memory.NewStorage(), memory.NewStorage()
+	src.Seed("db/a.sql", []byte("a"), time.Now())
+	dst.Seed("db/a.sql", []byte("a"), time.Now())
+	dst.Seed("db/a", []byte("b"), time.Now())
+
+	if err := storage.Sync(context.Background(), src, dst, storage.WithDelete()); err != nil {
+		t.Fatal(err)
+	}
+
+	// extraneous object, which name is prefix of synced one, is removed alone
+	if got := contents(t, dst); len(got) != 1 || got["db/a.sql"] != "a" {
+		t.Errorf("synced %v", got)
+	}
+}
+
+// checksummed reports sha256 checksums of objects in Stat, like s3 does
+// for objects uploaded by it.
+type checksummed struct {
+	*memory.Memory
+}
+
+type summedInfo struct {
+	storage.FileInfo
+	sum string
+}
+
+func (f summedInfo) Checksum() string { return f.sum }
+
+func (s checksummed) Stat(name string) (storage.FileInfo, error) {
+	f, err := s.Memory.Stat(name)
+	if err != nil {
+		return f, err
+	}
+
+	data, _ := s.Bytes(name)
+
+	return summedInfo{f, fmt.Sprintf("%x", sha256.Sum256(data))}, nil
+}
+
+func TestSyncChecksum(t *testing.T) {
+	old := time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	src, dst := memory.NewStorage(), memory.NewStorage()
+	// equal sizes and destination newer, only checksums differ
+	src.Seed("changed.sql", []byte("new"), old)
+	src.Seed("same.sql", []byte("same"), old)
+	dst.Seed("changed.sql", []byte("old"), old.Add(time.Hour))
+	dst.Seed("same.sql", []byte("same"), old.Add(time.Hour))
+
+	if err := storage.Sync(context.Background(), checksummed{src}, checksummed{dst}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := contents(t, dst)["changed.sql"]; got != "old" {
+		t.Errorf("synced without checksums %q, want old", got)
+	}
+
+	if err := storage.Sync(context.Background(), checksummed{src}, checksummed{dst}, storage.WithChecksum()); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := contents(t, dst)["changed.sql"]; got != "new" {
+		t.Errorf("synced with checksums %q, want new", got)
+	}
+
+	// same content is not uploaded again
+	if f, err := dst.Stat("same.sql"); err != nil || !f.ModTime().Equal(old.Add(time.Hour)) {
+		t.Errorf("same object uploaded again: %v", err)
+	}
+}
+
+func TestSyncChecksumFallback(t *testing.T) {
+	old := time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	src, dst := memory.NewStorage(), memory.NewStorage()
+	src.Seed("newer.sql", []byte("new"), old.Add(time.Hour))
+	src.Seed("older.sql", []byte("new"), old)
+	dst.Seed("newer.sql", []byte("old"), old)
+	dst.Seed("older.sql", []byte("old"), old.Add(time.Hour))
+
+	// destination has no checksums, modification times are compared
+	if err := storage.Sync(context.Background(), checksummed{src}, dst, storage.WithChecksum()); err != nil {
+		t.Fatal(err)
+	}
+
+	got := contents(t, dst)
+	if got["newer.sql"] != "new" || got["older.sql"] != "old" {
+		t.Errorf("synced %v", got)
+	}
+}
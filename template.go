@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// KeyFields are inputs of KeyTemplate. Time defaults to the current time
+// and is rendered in UTC by Date and Timestamp.
+type KeyFields struct {
+	Host string
+	Job  string
+	Name string
+	Time time.Time
+	Meta map[string]string
+}
+
+// Date returns time as 2006-01-02.
+func (f KeyFields) Date() string { return f.time().Format("2006-01-02") }
+
+// Timestamp returns time as 2006-01-02T15:04:05Z.
+func (f KeyFields) Timestamp() string { return f.time().Format("2006-01-02T15:04:05Z") }
+
+func (f KeyFields) time() time.Time {
+	if f.Time.IsZero() {
+		return time.Now().UTC()
+	}
+
+	return f.Time.UTC()
+}
+
+// KeyTemplate renders object names from KeyFields, so names are consistent
+// across backup jobs, e.g. "{{.Host}}/{{.Date}}/{{.Name}}".
+type KeyTemplate struct {
+	t *template.Template
+}
+
+// ParseKeyTemplate parses text/template of object names. Unknown fields
+// and missing Meta keys fail rendering.
+func ParseKeyTemplate(text string) (*KeyTemplate, error) {
+	t, err := template.New("key").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyTemplate{t}, nil
+}
+
+// Key renders object name, names with empty path segments, e.g. of empty
+// fields, are rejected.
+func (t *KeyTemplate) Key(f KeyFields) (string, error) {
+	// render every field with the same time
+	f.Time = f.time()
+
+	var b strings.Builder
+	if err := t.t.Execute(&b, f); err != nil {
+		return "", err
+	}
+
+	key := b.String()
+	for _, seg := range strings.Split(key, "/") {
+		if seg == "" {
+			return "", fmt.Errorf("key %q has empty path segment", key)
+		}
+	}
+
+	return key, nil
+}
+
+// UploadWithTemplate uploads buf as the name rendered from f and returns
+// the name.
+func UploadWithTemplate(ctx context.Context, s Storage, t *KeyTemplate, f KeyFields, buf io.Reader) (string, error) {
+	name, err := t.Key(f)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.UploadContext(ctx, name, buf); err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
@@ -0,0 +1,98 @@
+package storage_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sputnik-systems/backups-storage"
+	"github.com/sputnik-systems/backups-storage/memory"
+)
+
+func TestKeyTemplate(t *testing.T) {
+	f := storage.KeyFields{
+		Host: "db1",
+		Job:  "mysql",
+		Name: "full.sql.gz",
+		Time: time.Date(2021, 10, 10, 6, 0, 0, 0, time.FixedZone("MSK", 3*3600)),
+		Meta: map[string]string{"env": "prod"},
+	}
+
+	for _, tc := range []struct {
+		text, want string
+	}{
+		{"{{.Host}}/{{.Date}}/{{.Name}}", "db1/2021-10-10/full.sql.gz"},
+		{"{{.Job}}/{{.Timestamp}}/{{.Name}}", "mysql/2021-10-10T03:00:00Z/full.sql.gz"},
+		{`{{index .Meta "env"}}/{{.Host}}/{{.Name}}`, "prod/db1/full.sql.gz"},
+	} {
+		kt, err := storage.ParseKeyTemplate(tc.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got, err := kt.Key(f); err != nil || got != tc.want {
+			t.Errorf("%s: rendered %q, %v, want %q", tc.text, got, err, tc.want)
+		}
+	}
+
+	// date of zero time is the current one
+	kt, _ := storage.ParseKeyTemplate("{{.Date}}/{{.Timestamp}}")
+	before := time.Now().UTC()
+	got, err := kt.Key(storage.KeyFields{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if date, _, _ := strings.Cut(got, "/"); date != before.Format("2006-01-02") && date != time.Now().UTC().Format("2006-01-02") {
+		t.Errorf("rendered date %s at %s", date, before)
+	}
+}
+
+func TestKeyTemplateMissingFields(t *testing.T) {
+	// empty job, missing meta key and unknown field
+	for _, text := range []string{
+		"{{.Host}}/{{.Job}}/{{.Name}}",
+		`{{.Meta.region}}/{{.Name}}`,
+		"{{.Owner}}/{{.Name}}",
+	} {
+		kt, err := storage.ParseKeyTemplate(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got, err := kt.Key(storage.KeyFields{Host: "db1", Name: "full.sql", Meta: map[string]string{}}); err == nil {
+			t.Errorf("%s: rendered %q with missing field", text, got)
+		}
+	}
+
+	if _, err := storage.ParseKeyTemplate("{{.Host"); err == nil {
+		t.Error("invalid template parsed")
+	}
+}
+
+func TestUploadWithTemplate(t *testing.T) {
+	s := memory.NewStorage()
+	kt, err := storage.ParseKeyTemplate("{{.Host}}/{{.Date}}/{{.Name}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := storage.KeyFields{Host: "db1", Name: "full.sql", Time: day}
+	name, err := storage.UploadWithTemplate(context.Background(), s, kt, f, strings.NewReader("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name != "db1/2021-10-10/full.sql" {
+		t.Errorf("uploaded as %s", name)
+	}
+
+	if got, ok := s.Bytes(name); !ok || string(got) != "data" {
+		t.Errorf("stored %q", got)
+	}
+
+	if _, err := storage.UploadWithTemplate(context.Background(), s, kt, storage.KeyFields{Name: "full.sql"}, strings.NewReader("data")); err == nil {
+		t.Error("uploaded with empty host")
+	}
+}
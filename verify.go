@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+type DiscrepancyKind string
+
+const (
+	DiscrepancyMissing  DiscrepancyKind = "missing"
+	DiscrepancyExtra    DiscrepancyKind = "extra"
+	DiscrepancySize     DiscrepancyKind = "size mismatch"
+	DiscrepancyChecksum DiscrepancyKind = "checksum mismatch"
+)
+
+// Discrepancy describes object which differs from its manifest entry.
+type Discrepancy struct {
+	Name string
+	Kind DiscrepancyKind
+}
+
+func (d Discrepancy) String() string {
+	return fmt.Sprintf("%s: %s", d.Name, d.Kind)
+}
+
+// Verify compares objects with manifest stored by WriteManifest. Objects
+// with checksums in manifest are downloaded to compute their SHA-256, so
+// corruption at rest is detected too.
+func Verify(ctx context.Context, s Storage, manifestName string) ([]Discrepancy, error) {
+	m, err := ReadManifest(ctx, s, manifestName)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := listPrefix(ctx, s, m.Prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	current := make(map[string]FileInfo, len(fi))
+	for _, f := range fi {
+		current[f.Name()] = f
+	}
+
+	ds := make([]Discrepancy, 0)
+	listed := make(map[string]bool, len(m.Objects))
+	for _, e := range m.Objects {
+		listed[e.Name] = true
+
+		f, ok := current[e.Name]
+		switch {
+		case !ok:
+			ds = append(ds, Discrepancy{e.Name, DiscrepancyMissing})
+
+			continue
+		case f.Size() != e.Size:
+			ds = append(ds, Discrepancy{e.Name, DiscrepancySize})
+
+			continue
+		case e.Checksum == "":
+			continue
+		}
+
+		// storages verifying checksums on download fail on corrupted objects
+		h := sha256.New()
+		err := s.DownloadContext(ctx, e.Name, h)
+		if err != nil && !errors.Is(err, ErrChecksumMismatch) {
+			return ds, fmt.Errorf("download %s: %w", e.Name, err)
+		}
+
+		if err != nil || hex.EncodeToString(h.Sum(nil)) != e.Checksum {
+			ds = append(ds, Discrepancy{e.Name, DiscrepancyChecksum})
+		}
+	}
+
+	for _, f := range fi {
+		if !listed[f.Name()] && f.Name() != manifestName {
+			ds = append(ds, Discrepancy{f.Name(), DiscrepancyExtra})
+		}
+	}
+
+	return ds, nil
+}
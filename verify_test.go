@@ -0,0 +1,78 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sputnik-systems/backups-storage"
+	"github.com/sputnik-systems/backups-storage/memory"
+)
+
+func TestVerify(t *testing.T) {
+	m := memory.NewStorage()
+	m.Seed("db/intact.sql", []byte("intact"), day)
+	m.Seed("db/corrupted.sql", []byte("good"), day)
+	m.Seed("db/truncated.sql", []byte("complete"), day)
+	m.Seed("db/deleted.sql", []byte("deleted"), day)
+	s := checksummed{m}
+
+	if err := storage.WriteManifest(context.Background(), s, "db/", "db/manifest.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	ds, err := storage.Verify(context.Background(), s, "db/manifest.json")
+	if err != nil || len(ds) != 0 {
+		t.Fatalf("intact backup: %v, %v", ds, err)
+	}
+
+	// corrupted object keeps its size
+	m.Seed("db/corrupted.sql", []byte("evil"), day)
+	m.Seed("db/truncated.sql", []byte("comp"), day)
+	m.Seed("db/added.sql", []byte("added"), day.Add(time.Hour))
+	if err := m.Delete("db/deleted.sql"); err != nil {
+		t.Fatal(err)
+	}
+
+	ds, err = storage.Verify(context.Background(), s, "db/manifest.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]storage.DiscrepancyKind{
+		"db/corrupted.sql": storage.DiscrepancyChecksum,
+		"db/truncated.sql": storage.DiscrepancySize,
+		"db/deleted.sql":   storage.DiscrepancyMissing,
+		"db/added.sql":     storage.DiscrepancyExtra,
+	}
+	if len(ds) != len(want) {
+		t.Errorf("discrepancies %v, want %v", ds, want)
+	}
+
+	for _, d := range ds {
+		if want[d.Name] != d.Kind {
+			t.Errorf("%s, want %s", d, want[d.Name])
+		}
+	}
+}
+
+func TestVerifyWithoutChecksums(t *testing.T) {
+	m := memory.NewStorage()
+	m.Seed("db/a.sql", []byte("good"), day)
+
+	if err := storage.WriteManifest(context.Background(), m, "db/", "manifest.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	// only sizes are compared
+	m.Seed("db/a.sql", []byte("evil"), day)
+
+	ds, err := storage.Verify(context.Background(), m, "manifest.json")
+	if err != nil || len(ds) != 0 {
+		t.Errorf("discrepancies %v, %v", ds, err)
+	}
+
+	if _, err := storage.Verify(context.Background(), m, "missing.json"); err == nil {
+		t.Error("verified missing manifest")
+	}
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"context"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SkipDir returned by Walk callback for a directory skips its objects, for
+// an object skips the rest of its directory. It is fs.SkipDir, so either
+// may be returned.
+var SkipDir = fs.SkipDir
+
+// Walk calls fn for objects with the name prefix and their directories like
+// filepath.WalkDir does: directory first, then its entries in lexical order.
+// Directories are synthesized like in List, their names end with a slash.
+func Walk(ctx context.Context, s Storage, prefix string, fn func(FileInfo) error) error {
+	objects := make([]FileInfo, 0)
+	dirs := make(map[string]time.Time)
+	err := s.ListFunc(prefix, func(f FileInfo) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		objects = append(objects, f)
+		for _, d := range parentDirs(f.Name()) {
+			if t, ok := dirs[d]; !ok || t.Before(f.ModTime()) {
+				dirs[d] = f.ModTime()
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// segment wise order keeps directory entries together, e.g. "a/b"
+	// goes before "a-b" unlike in plain string order
+	sort.Slice(objects, func(i, j int) bool {
+		return pathLess(objects[i].Name(), objects[j].Name())
+	})
+
+	visited := make(map[string]bool)
+	skip := ""
+	for _, f := range objects {
+		if skip != "" && strings.HasPrefix(f.Name(), skip) {
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		skipped := false
+		for _, d := range parentDirs(f.Name()) {
+			if visited[d] {
+				continue
+			}
+			visited[d] = true
+
+			if err := fn(dirInfo{d, dirs[d]}); err != nil {
+				if err != SkipDir {
+					return err
+				}
+
+				skip, skipped = d, true
+
+				break
+			}
+		}
+
+		// directory marker objects are visited as their directories
+		if skipped || strings.HasSuffix(f.Name(), "/") {
+			continue
+		}
+
+		if err := fn(f); err != nil {
+			if err != SkipDir {
+				return err
+			}
+
+			// the rest of top level objects is the rest of walk
+			dir := parentDir(f.Name())
+			if dir == "" {
+				return nil
+			}
+			skip = dir
+		}
+	}
+
+	return nil
+}
+
+// parentDirs returns directories of name from the top one, e.g. "a/" and
+// "a/b/" for "a/b/c".
+func parentDirs(name string) []string {
+	dirs := make([]string, 0)
+	for i := 0; i < len(name); i++ {
+		if name[i] == '/' {
+			dirs = append(dirs, name[:i+1])
+		}
+	}
+
+	return dirs
+}
+
+// parentDir returns directory of name with trailing slash, or empty string
+// for top level names.
+func parentDir(name string) string {
+	return name[:strings.LastIndex(name, "/")+1]
+}
+
+func pathLess(a, b string) bool {
+	as, bs := strings.Split(a, "/"), strings.Split(b, "/")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] != bs[i] {
+			return as[i] < bs[i]
+		}
+	}
+
+	return len(as) < len(bs)
+}
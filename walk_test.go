@@ -0,0 +1,120 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sputnik-systems/backups-storage"
+	"github.com/sputnik-systems/backups-storage/memory"
+)
+
+// walked returns names visited by Walk with fn deciding on each of them.
+func walked(t *testing.T, s storage.Storage, fn func(storage.FileInfo) error) string {
+	t.Helper()
+
+	var names []string
+	err := storage.Walk(context.Background(), s, "", func(f storage.FileInfo) error {
+		names = append(names, f.Name())
+
+		return fn(f)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return strings.Join(names, " ")
+}
+
+func newWalkStorage() *memory.Memory {
+	s := memory.NewStorage()
+	s.Seed("f.sql", nil, day)
+	s.Seed("a-b.sql", nil, day)
+	s.Seed("a/e.sql", nil, day)
+	s.Seed("a/b/d.sql", nil, day.Add(time.Hour))
+	s.Seed("a/b/c.sql", nil, day)
+
+	return s
+}
+
+func TestWalk(t *testing.T) {
+	s := newWalkStorage()
+
+	var dirs []storage.FileInfo
+	got := walked(t, s, func(f storage.FileInfo) error {
+		if f.IsDir() {
+			dirs = append(dirs, f)
+		}
+
+		return nil
+	})
+
+	// directory entries go before "a-b.sql" although '-' < '/'
+	want := "a/ a/b/ a/b/c.sql a/b/d.sql a/e.sql a-b.sql f.sql"
+	if got != want {
+		t.Errorf("walked %s, want %s", got, want)
+	}
+
+	for _, d := range dirs {
+		if !d.ModTime().Equal(day.Add(time.Hour)) {
+			t.Errorf("%s: modified %s, want the latest object time", d.Name(), d.ModTime())
+		}
+	}
+}
+
+func TestWalkSkipDir(t *testing.T) {
+	s := newWalkStorage()
+
+	for _, tc := range []struct {
+		skip, want string
+	}{
+		// directory skips its subtree
+		{"a/b/", "a/ a/b/ a/e.sql a-b.sql f.sql"},
+		{"a/", "a/ a-b.sql f.sql"},
+		// object skips the rest of its directory
+		{"a/b/c.sql", "a/ a/b/ a/b/c.sql a/e.sql a-b.sql f.sql"},
+		// top level object stops the walk
+		{"a-b.sql", "a/ a/b/ a/b/c.sql a/b/d.sql a/e.sql a-b.sql"},
+	} {
+		got := walked(t, s, func(f storage.FileInfo) error {
+			if f.Name() == tc.skip {
+				return storage.SkipDir
+			}
+
+			return nil
+		})
+
+		if got != tc.want {
+			t.Errorf("skip %s: walked %s, want %s", tc.skip, got, tc.want)
+		}
+	}
+}
+
+func TestWalkError(t *testing.T) {
+	s := newWalkStorage()
+
+	errStop := errors.New("stop")
+	var n int
+	err := storage.Walk(context.Background(), s, "", func(f storage.FileInfo) error {
+		n++
+		if f.Name() == "a/b/c.sql" {
+			return errStop
+		}
+
+		return nil
+	})
+
+	if !errors.Is(err, errStop) || n != 3 {
+		t.Errorf("got %v after %d entries, want %v after 3", err, n, errStop)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = storage.Walk(ctx, s, "", func(storage.FileInfo) error { return nil })
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("walk with canceled context: %v", err)
+	}
+}
@@ -0,0 +1,481 @@
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sputnik-systems/backups-storage"
+)
+
+type WebDAV struct {
+	c              *http.Client
+	base           *url.URL
+	user, password string
+	root           string
+}
+
+type FileInfo struct {
+	name  string
+	size  int64
+	mtime time.Time
+	isdir bool
+}
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:">
+  <d:prop>
+    <d:resourcetype/>
+    <d:getcontentlength/>
+    <d:getlastmodified/>
+  </d:prop>
+</d:propfind>`
+
+type multistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Status string `xml:"status"`
+			Prop   struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+				ContentLength string `xml:"getcontentlength"`
+				LastModified  string `xml:"getlastmodified"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+// NewStorage returns storage in root collection of the server at baseURL,
+// empty user disables authentication.
+func NewStorage(baseURL, user, password, root string) (storage.Storage, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base url %q: %w", baseURL, err)
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid base url %q, must be http(s)://host[:port][/path]", baseURL)
+	}
+
+	return &WebDAV{
+		c:        defaultHTTPClient(),
+		base:     u,
+		user:     user,
+		password: password,
+		root:     strings.Trim(root, "/"),
+	}, nil
+}
+
+// defaultHTTPClient returns client which does not hang on unreachable or
+// stalled servers. There is no overall timeout, as transfers of large
+// objects may take long.
+func defaultHTTPClient() *http.Client {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	t.ResponseHeaderTimeout = time.Minute
+
+	return &http.Client{Transport: t}
+}
+
+func (s *WebDAV) List() ([]storage.FileInfo, error) {
+	return s.ListContext(context.Background())
+}
+
+func (s *WebDAV) ListContext(ctx context.Context) ([]storage.FileInfo, error) {
+	fi := make([]storage.FileInfo, 0)
+	err := s.walk(ctx, "", func(f *FileInfo) error {
+		fi = append(fi, f)
+
+		return nil
+	})
+	if err != nil {
+		return fi, err
+	}
+
+	sort.Slice(fi, func(i, j int) bool {
+		return fi[i].Name() > fi[j].Name()
+	})
+
+	return fi, nil
+}
+
+func (s *WebDAV) ListFunc(prefix string, fn func(storage.FileInfo) error) error {
+	return s.walk(context.Background(), prefix, func(f *FileInfo) error {
+		if f.isdir || !strings.HasPrefix(f.name, prefix) {
+			return nil
+		}
+
+		return fn(f)
+	})
+}
+
+// walk lists collections one level at a time, as many servers forbid
+// infinite depth, skipping those which can not contain the prefix.
+func (s *WebDAV) walk(ctx context.Context, prefix string, fn func(*FileInfo) error) error {
+	dirs := []string{""}
+	for len(dirs) > 0 {
+		dir := dirs[0]
+		dirs = dirs[1:]
+
+		fi, err := s.propfind(ctx, dir, "1")
+		if err != nil {
+			// root collection is created by the first upload
+			if dir == "" && err == storage.ErrNotFound {
+				return nil
+			}
+
+			return err
+		}
+
+		for _, f := range fi {
+			// response contains the collection itself
+			if f.name == "" || f.name == dir {
+				continue
+			}
+
+			if f.isdir {
+				if !strings.HasPrefix(f.name, prefix) && !strings.HasPrefix(prefix, f.name) {
+					continue
+				}
+
+				dirs = append(dirs, f.name)
+			}
+
+			if err := fn(f); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *WebDAV) propfind(ctx context.Context, name, depth string) ([]*FileInfo, error) {
+	// empty name stands for the root collection
+	u := s.baseURL(s.root)
+	if name != "" {
+		var err error
+		if u, err = s.url(name); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := s.do(ctx, "PROPFIND", u, map[string]string{
+		"Depth":        depth,
+		"Content-Type": "application/xml",
+	}, strings.NewReader(propfindBody))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, storage.ErrNotFound
+	}
+
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, statusError("PROPFIND", name, resp)
+	}
+
+	var ms multistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("PROPFIND %s: %w", name, err)
+	}
+
+	fi := make([]*FileInfo, 0, len(ms.Responses))
+	for _, r := range ms.Responses {
+		name, err := s.name(r.Href)
+		if err != nil {
+			return nil, err
+		}
+
+		f := &FileInfo{name: name}
+		for _, ps := range r.Propstat {
+			if !strings.Contains(ps.Status, " 200 ") {
+				continue
+			}
+
+			f.isdir = ps.Prop.ResourceType.Collection != nil
+			f.size, _ = strconv.ParseInt(ps.Prop.ContentLength, 10, 64)
+			f.mtime, _ = http.ParseTime(ps.Prop.LastModified)
+		}
+
+		if f.isdir {
+			f.size = 0
+			if f.name != "" && !strings.HasSuffix(f.name, "/") {
+				f.name += "/"
+			}
+		}
+
+		fi = append(fi, f)
+	}
+
+	return fi, nil
+}
+
+func (s *WebDAV) Delete(name string) error {
+	return s.DeleteContext(context.Background(), name)
+}
+
+func (s *WebDAV) DeleteContext(ctx context.Context, name string) error {
+	u, err := s.url(name)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(ctx, http.MethodDelete, u, map[string]string{"Depth": "infinity"}, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		return statusError(http.MethodDelete, name, resp)
+	}
+
+	return nil
+}
+
+func (s *WebDAV) DeleteBatch(names []string) error {
+	for _, name := range names {
+		if err := s.Delete(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *WebDAV) Upload(name string, buf io.Reader) error {
+	return s.UploadContext(context.Background(), name, buf)
+}
+
+func (s *WebDAV) UploadContext(ctx context.Context, name string, buf io.Reader) error {
+	u, err := s.url(name)
+	if err != nil {
+		return err
+	}
+
+	if err := s.mkdirAll(ctx, path.Dir(name)); err != nil {
+		return err
+	}
+
+	resp, err := s.do(ctx, http.MethodPut, u, nil, buf)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return statusError(http.MethodPut, name, resp)
+	}
+
+	return nil
+}
+
+// mkdirAll creates collection with parents, the root ones included.
+func (s *WebDAV) mkdirAll(ctx context.Context, dir string) error {
+	full := strings.Trim(path.Join(s.root, dir), "/")
+	if full == "" || full == "." {
+		return nil
+	}
+
+	parts := strings.Split(full, "/")
+	for i := range parts {
+		name := strings.Join(parts[:i+1], "/")
+		resp, err := s.do(ctx, "MKCOL", s.baseURL(name+"/"), nil, nil)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		// existing collections are reported as not allowed
+		if resp.StatusCode >= 300 && resp.StatusCode != http.StatusMethodNotAllowed {
+			return statusError("MKCOL", name, resp)
+		}
+	}
+
+	return nil
+}
+
+func (s *WebDAV) Download(name string, buf io.Writer) error {
+	return s.DownloadContext(context.Background(), name, buf)
+}
+
+func (s *WebDAV) DownloadContext(ctx context.Context, name string, buf io.Writer) error {
+	u, err := s.url(name)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(ctx, http.MethodGet, u, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s: %w", name, storage.ErrNotFound)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return statusError(http.MethodGet, name, resp)
+	}
+
+	_, err = io.Copy(buf, resp.Body)
+
+	return err
+}
+
+func (s *WebDAV) Exists(name string) (bool, error) {
+	_, err := s.Stat(name)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *WebDAV) Stat(name string) (storage.FileInfo, error) {
+	fi, err := s.propfind(context.Background(), name, "0")
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return nil, fmt.Errorf("%s: %w", name, storage.ErrNotFound)
+		}
+
+		return nil, err
+	}
+
+	if len(fi) == 0 || fi[0].isdir {
+		return nil, fmt.Errorf("%s: %w", name, storage.ErrNotFound)
+	}
+
+	return &FileInfo{name, fi[0].size, fi[0].mtime, false}, nil
+}
+
+func (s *WebDAV) Copy(src, dst string) error {
+	return s.transfer("COPY", src, dst)
+}
+
+func (s *WebDAV) Move(src, dst string) error {
+	return s.transfer("MOVE", src, dst)
+}
+
+func (s *WebDAV) transfer(method, src, dst string) error {
+	su, err := s.url(src)
+	if err != nil {
+		return err
+	}
+
+	du, err := s.url(dst)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if err := s.mkdirAll(ctx, path.Dir(dst)); err != nil {
+		return err
+	}
+
+	resp, err := s.do(ctx, method, su, map[string]string{
+		"Destination": du,
+		"Overwrite":   "T",
+	}, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s: %w", src, storage.ErrNotFound)
+	}
+
+	if resp.StatusCode >= 300 {
+		return statusError(method, src, resp)
+	}
+
+	return nil
+}
+
+func (s *WebDAV) do(ctx context.Context, method, u string, headers map[string]string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	if s.user != "" {
+		req.SetBasicAuth(s.user, s.password)
+	}
+
+	return s.c.Do(req)
+}
+
+// url joins name with the root the same way as s3 joins keys with prefix,
+// names escaping the root are rejected.
+func (s *WebDAV) url(name string) (string, error) {
+	if err := storage.ValidateName(name); err != nil {
+		return "", err
+	}
+
+	p := path.Join(s.root, name)
+	if strings.HasSuffix(name, "/") {
+		p += "/"
+	}
+
+	return s.baseURL(p), nil
+}
+
+func (s *WebDAV) baseURL(p string) string {
+	u := *s.base
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + strings.TrimPrefix(p, "/")
+
+	return u.String()
+}
+
+// name converts response href into name relative to the root.
+func (s *WebDAV) name(href string) (string, error) {
+	u, err := url.Parse(href)
+	if err != nil {
+		return "", fmt.Errorf("invalid href %q: %w", href, err)
+	}
+
+	root := strings.TrimSuffix(s.base.Path, "/") + "/" + s.root
+	name := strings.TrimPrefix(u.Path, strings.TrimSuffix(root, "/"))
+
+	return strings.TrimPrefix(name, "/"), nil
+}
+
+func statusError(method, name string, resp *http.Response) error {
+	return fmt.Errorf("%s %s: %s", method, name, resp.Status)
+}
+
+func (f *FileInfo) Name() string { return f.name }
+
+func (f *FileInfo) Size() int64 { return f.size }
+
+func (f *FileInfo) ModTime() time.Time { return f.mtime }
+
+func (f *FileInfo) IsDir() bool { return f.isdir }
@@ -0,0 +1,163 @@
+package webdav
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/webdav"
+
+	"github.com/sputnik-systems/backups-storage"
+)
+
+// newTestStorage returns storage in root collection "backups" of in-memory
+// server at /dav, which requires basic auth of user "user" with password "secret".
+func newTestStorage(t *testing.T) *WebDAV {
+	h := &webdav.Handler{
+		Prefix:     "/dav",
+		FileSystem: webdav.NewMemFS(),
+		LockSystem: webdav.NewMemLS(),
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != "user" || p != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	s, err := NewStorage(srv.URL+"/dav/", "user", "secret", "backups")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s.(*WebDAV)
+}
+
+// names returns names of listed entries in order.
+func names(fi []storage.FileInfo) []string {
+	n := make([]string, 0, len(fi))
+	for _, f := range fi {
+		n = append(n, f.Name())
+	}
+
+	return n
+}
+
+func TestRoundTrip(t *testing.T) {
+	s := newTestStorage(t)
+
+	data := bytes.Repeat([]byte("0123456789"), 100000)
+	if err := s.Upload("db/dump.sql", bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := s.Download("db/dump.sql", &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("downloaded %d bytes, want %d", buf.Len(), len(data))
+	}
+
+	fi, err := s.Stat("db/dump.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fi.Name() != "db/dump.sql" || fi.Size() != int64(len(data)) || fi.IsDir() {
+		t.Errorf("stat %s of %d bytes, directory %v", fi.Name(), fi.Size(), fi.IsDir())
+	}
+
+	if err := s.Download("missing", &buf); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("download of missing object: %v", err)
+	}
+
+	if ok, err := s.Exists("missing"); err != nil || ok {
+		t.Errorf("missing object exists %v, %v", ok, err)
+	}
+}
+
+func TestListAndDelete(t *testing.T) {
+	s := newTestStorage(t)
+
+	for _, name := range []string{"a/b/c.sql", "a/d.sql", "e.sql", "f g.sql"} {
+		if err := s.Upload(name, strings.NewReader(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fi, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := strings.Join(names(fi), " "), "f g.sql e.sql a/d.sql a/b/c.sql a/b/ a/"; got != want {
+		t.Errorf("listed %s, want %s", got, want)
+	}
+
+	for _, f := range fi {
+		if f.IsDir() != strings.HasSuffix(f.Name(), "/") {
+			t.Errorf("%s: directory %v", f.Name(), f.IsDir())
+		}
+
+		if !f.IsDir() && f.Size() != int64(len(f.Name())) {
+			t.Errorf("%s: size %d, want %d", f.Name(), f.Size(), len(f.Name()))
+		}
+	}
+
+	// collection with its members
+	if err := s.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if fi, err = s.List(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(names(fi), " "); got != "f g.sql e.sql" {
+		t.Errorf("left %s, want f g.sql e.sql", got)
+	}
+}
+
+func TestCopyAndMove(t *testing.T) {
+	s := newTestStorage(t)
+
+	if err := s.Upload("a.sql", strings.NewReader("data")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Copy("a.sql", "b/c.sql"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Move("b/c.sql", "d.sql"); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := s.Download("d.sql", &buf); err != nil || buf.String() != "data" {
+		t.Errorf("downloaded %q, %v", buf.String(), err)
+	}
+
+	if ok, err := s.Exists("b/c.sql"); err != nil || ok {
+		t.Errorf("moved object exists %v, %v", ok, err)
+	}
+}
+
+func TestUnauthorized(t *testing.T) {
+	s := newTestStorage(t)
+	s.password = "wrong"
+
+	if err := s.Upload("a.sql", strings.NewReader("data")); err == nil {
+		t.Error("upload with wrong password succeeded")
+	}
+}